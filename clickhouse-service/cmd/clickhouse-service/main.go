@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/health"
+	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/lib/tracing"
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/listener"
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/storage/clickhouse"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/config"
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/lib/logger/handlers/slogpretty"
@@ -27,6 +33,12 @@ func main() {
 	log.Info("starting up the application", slog.String("env", cfg.Env))
 	log.Debug("debug messages are enabled")
 
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Error("failed to set up tracing", sl.Err(err))
+		os.Exit(1)
+	}
+
 	clh, err := clickhouse.New(cfg.ClickHouseStorage)
 	if err != nil {
 		log.Error("failed to create clickhouse", sl.Err(err))
@@ -48,6 +60,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	probes := health.New(log, map[string]health.Pinger{
+		"nats":       lis,
+		"clickhouse": clh,
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", probes.Live)
+	mux.HandleFunc("GET /readyz", probes.Ready)
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	srv := &http.Server{
+		Addr:         cfg.HTTPServer.Address,
+		Handler:      mux,
+		ReadTimeout:  cfg.HTTPServer.Timeout,
+		WriteTimeout: cfg.HTTPServer.Timeout,
+		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("failed to start http server", sl.Err(err))
+		}
+	}()
+
+	log.Info("http server started", slog.String("address", cfg.HTTPServer.Address))
+
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
@@ -56,8 +94,22 @@ func main() {
 	<-done
 	log.Info("stopping server")
 
+	probes.Shutdown()
+
 	lis.Close()
 
+	//nolint: mnd
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error("failed to stop http server", sl.Err(err))
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error("failed to flush traces", sl.Err(err))
+	}
+
 	log.Debug("closing storage")
 
 	log.Info("server stopped")
@@ -14,6 +14,33 @@ type Config struct {
 
 	ClickHouseStorage ClickHouseStorage `yaml:"clickhouse"`
 	Nats              Nats              `yaml:"nats"`
+	HTTPServer        HTTPServer        `yaml:"http_server"`
+	Tracing           Tracing           `yaml:"tracing"`
+}
+
+// Trace exporters supported by Tracing.Exporter.
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterOTLP   = "otlp"
+)
+
+type Tracing struct {
+	// Exporter is none, stdout or otlp (OTLP over HTTP).
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	// Endpoint is the host:port of the OTLP collector.
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4318"`
+	Insecure bool   `yaml:"insecure" env:"TRACING_INSECURE" env-default:"true"`
+	// SampleRatio is the share of new traces that are recorded. Messages
+	// with a sampled parent are always recorded.
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+}
+
+// HTTPServer serves health checks and metrics.
+type HTTPServer struct {
+	Address     string        `yaml:"address" env:"ADDRESS" env-default:"localhost:8081"`
+	Timeout     time.Duration `yaml:"timeout" env:"TIMEOUT" env-default:"4s"`
+	IdleTimeout time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT" env-default:"60s"`
 }
 
 type Nats struct {
@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/lib/logger/sl"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+
+	checkTimeout = 2 * time.Second
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Health serves liveness and readiness probes. Readiness pings every
+// dependency and fails once shutdown has started.
+type Health struct {
+	log      *slog.Logger
+	deps     map[string]Pinger
+	shutdown atomic.Bool
+}
+
+func New(log *slog.Logger, deps map[string]Pinger) *Health {
+	return &Health{
+		log:  log.With(slog.String("component", "health")),
+		deps: deps,
+	}
+}
+
+// Shutdown makes readiness fail, so no more traffic is routed here.
+func (h *Health) Shutdown() {
+	h.shutdown.Store(true)
+}
+
+// Live reports that the process is up and serving.
+func (h *Health) Live(w http.ResponseWriter, _ *http.Request) {
+	write(w, http.StatusOK, Response{Status: StatusOK})
+}
+
+// Ready pings all dependencies concurrently and reports each of them.
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.shutdown.Load() {
+		write(w, http.StatusServiceUnavailable, Response{Status: StatusUnavailable})
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	res := Response{Status: StatusOK, Checks: make(map[string]string, len(h.deps))}
+
+	for name, dep := range h.deps {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			status := StatusOK
+			if err := dep.Ping(ctx); err != nil {
+				h.log.Warn("dependency is unavailable",
+					slog.String("dependency", name),
+					sl.Err(err),
+				)
+
+				status = StatusUnavailable
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			res.Checks[name] = status
+			if status != StatusOK {
+				res.Status = StatusUnavailable
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	code := http.StatusOK
+	if res.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	write(w, code, res)
+}
+
+func write(w http.ResponseWriter, code int, res Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	//nolint: errcheck
+	json.NewEncoder(w).Encode(res)
+}
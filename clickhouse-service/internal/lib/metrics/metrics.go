@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "clickhouse_service"
+
+// Label values of Messages.
+const (
+	MessageFetched = "fetched"
+	MessageAcked   = "acked"
+	// MessageDropped is a message left unacked because the batch channel
+	// was full.
+	MessageDropped = "dropped"
+	// MessageFailed is a message that could not be decoded or acked.
+	MessageFailed = "failed"
+)
+
+// Label values of Flushes.
+const (
+	FlushSuccess = "success"
+	FlushFailure = "failure"
+)
+
+var (
+	Messages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "listener",
+		Name:      "messages_total",
+		Help:      "NATS messages by outcome: fetched, acked, dropped or failed.",
+	}, []string{"outcome"})
+
+	ConsumerLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "listener",
+		Name:      "consumer_lag",
+		Help:      "Messages pending for the consumer as of the last fetch.",
+	})
+
+	BatchChannelLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "listener",
+		Name:      "batch_channel_length",
+		Help:      "Messages waiting in the batch channel.",
+	})
+
+	BatchChannelCapacity = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "listener",
+		Name:      "batch_channel_capacity",
+		Help:      "Capacity of the batch channel.",
+	})
+
+	Flushes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "clickhouse",
+		Name:      "flushes_total",
+		Help:      "Batch flushes to ClickHouse by result.",
+	}, []string{"result"})
+
+	BatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "clickhouse",
+		Name:      "batch_size",
+		Help:      "Goods per flushed batch.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10), //nolint: mnd
+	})
+
+	FlushDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "clickhouse",
+		Name:      "flush_duration_seconds",
+		Help:      "Duration of batch flushes to ClickHouse.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
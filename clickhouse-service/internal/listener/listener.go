@@ -9,11 +9,18 @@ import (
 
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/config"
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/lib/metrics"
+	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/lib/tracing"
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/models"
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/storage/clickhouse"
 	"log/slog"
 
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Listener struct {
@@ -26,10 +33,17 @@ type Listener struct {
 	cfg config.Nats
 
 	goodsBatch []models.Good
-	batchCh    chan models.Good
+	// batchLinks point to the traces of the messages in goodsBatch.
+	batchLinks []trace.Link
+	batchCh    chan batchItem
 	batchTimer time.Duration
 }
 
+type batchItem struct {
+	good models.Good
+	link trace.Link
+}
+
 func New(log *slog.Logger, cfg config.Nats, clh *clickhouse.ClickHouseStorage) (*Listener, error) {
 	nc, err := nats.Connect(cfg.URL())
 	if err != nil {
@@ -42,6 +56,8 @@ func New(log *slog.Logger, cfg config.Nats, clh *clickhouse.ClickHouseStorage) (
 		return nil, fmt.Errorf("create JetStream context: %w", err)
 	}
 
+	metrics.BatchChannelCapacity.Set(float64(cfg.BatchSize * 2)) //nolint: mnd
+
 	return &Listener{
 		js:         js,
 		nc:         nc,
@@ -50,10 +66,20 @@ func New(log *slog.Logger, cfg config.Nats, clh *clickhouse.ClickHouseStorage) (
 		log:        log,
 		batchTimer: clh.BatchTimer,
 		goodsBatch: make([]models.Good, 0, cfg.BatchSize),
-		batchCh:    make(chan models.Good, cfg.BatchSize*2), //nolint: mnd
+		batchLinks: make([]trace.Link, 0, cfg.BatchSize),
+		batchCh:    make(chan batchItem, cfg.BatchSize*2), //nolint: mnd
 	}, nil
 }
 
+// Ping reports whether the NATS connection is up.
+func (l *Listener) Ping(_ context.Context) error {
+	if !l.nc.IsConnected() {
+		return fmt.Errorf("nats connection is %s", l.nc.Status()) //nolint: err113
+	}
+
+	return nil
+}
+
 func (l *Listener) Close() {
 	if l.nc != nil {
 		l.nc.Close()
@@ -103,6 +129,7 @@ func (l *Listener) listen(ctx context.Context, sub *nats.Subscription) {
 			msgs, err := sub.Fetch(l.cfg.BatchSize, nats.MaxWait(2*time.Second)) //nolint: mnd
 			if err != nil {
 				if errors.Is(err, nats.ErrTimeout) {
+					metrics.ConsumerLag.Set(0)
 					continue
 				}
 				l.log.Warn("Error fetching messages", sl.Err(err))
@@ -110,42 +137,77 @@ func (l *Listener) listen(ctx context.Context, sub *nats.Subscription) {
 				continue
 			}
 
+			metrics.Messages.WithLabelValues(metrics.MessageFetched).Add(float64(len(msgs)))
+
+			if len(msgs) > 0 {
+				if meta, err := msgs[len(msgs)-1].Metadata(); err == nil {
+					metrics.ConsumerLag.Set(float64(meta.NumPending))
+				}
+			}
+
 			processedMsgs := make([]*nats.Msg, 0, len(msgs))
 			for _, msg := range msgs {
-				good, err := l.processMessage(msg)
+				item, err := l.processMessage(ctx, msg)
 				if err != nil {
+					metrics.Messages.WithLabelValues(metrics.MessageFailed).Inc()
 					l.log.Warn("Error processing message", sl.Err(err))
 					continue
 				}
 
 				select {
-				case l.batchCh <- *good:
+				case l.batchCh <- *item:
+					metrics.BatchChannelLength.Set(float64(len(l.batchCh)))
 					processedMsgs = append(processedMsgs, msg)
 				case <-ctx.Done():
 					return
 				default:
+					metrics.Messages.WithLabelValues(metrics.MessageDropped).Inc()
 					l.log.Warn("Batch channel full, dropping message")
 				}
 			}
 
 			for _, msg := range processedMsgs {
 				if err := msg.Ack(); err != nil {
+					metrics.Messages.WithLabelValues(metrics.MessageFailed).Inc()
 					l.log.Warn("Error acknowledging message", sl.Err(err))
+					continue
 				}
+
+				metrics.Messages.WithLabelValues(metrics.MessageAcked).Inc()
 			}
 		}
 	}
 }
 
-func (l *Listener) processMessage(msg *nats.Msg) (*models.Good, error) {
+// processMessage decodes a message in a span that continues the trace core
+// sent in the message headers.
+func (l *Listener) processMessage(ctx context.Context, msg *nats.Msg) (*batchItem, error) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, tracing.NATSHeaderCarrier(msg.Header))
+
+	_, span := tracing.Tracer().Start(ctx, l.cfg.Subject+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("nats"),
+			semconv.MessagingDestinationName(l.cfg.Subject),
+			semconv.MessagingOperationTypeDeliver,
+		),
+	)
+	defer span.End()
+
 	l.log.Debug("Processing message", slog.String("data", string(msg.Data)))
 
 	var good models.Good
 	if err := json.Unmarshal(msg.Data, &good); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unmarshal failed")
+
 		return nil, fmt.Errorf("unmarshal message: %w", err)
 	}
 
-	return &good, nil
+	return &batchItem{
+		good: good,
+		link: trace.Link{SpanContext: span.SpanContext()},
+	}, nil
 }
 
 func (l *Listener) batchProcessor(ctx context.Context) {
@@ -158,12 +220,17 @@ func (l *Listener) batchProcessor(ctx context.Context) {
 			l.flushBatch(ctx)
 			return
 
-		case good, ok := <-l.batchCh:
+		case item, ok := <-l.batchCh:
 			if !ok {
 				return
 			}
 
-			l.goodsBatch = append(l.goodsBatch, good)
+			metrics.BatchChannelLength.Set(float64(len(l.batchCh)))
+
+			l.goodsBatch = append(l.goodsBatch, item.good)
+			if item.link.SpanContext.IsValid() {
+				l.batchLinks = append(l.batchLinks, item.link)
+			}
 
 			if len(l.goodsBatch) >= l.cfg.BatchSize {
 				l.flushBatch(ctx)
@@ -184,20 +251,36 @@ func (l *Listener) flushBatch(ctx context.Context) {
 		return
 	}
 
+	// A batch mixes messages of many traces, so it is linked to them rather
+	// than being a child of any.
+	ctx, span := tracing.Tracer().Start(ctx, "flush batch",
+		trace.WithLinks(l.batchLinks...),
+		trace.WithAttributes(attribute.Int("batch_size", len(l.goodsBatch))),
+	)
+	defer span.End()
+
 	start := time.Now()
 	err := l.clh.LogGoods(ctx, l.goodsBatch)
 	duration := time.Since(start)
 
+	metrics.BatchSize.Observe(float64(len(l.goodsBatch)))
+	metrics.FlushDuration.Observe(duration.Seconds())
+
 	if err != nil {
+		metrics.Flushes.WithLabelValues(metrics.FlushFailure).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "flush failed")
 		l.log.Error("Failed to flush batch to ClickHouse",
 			sl.Err(err),
 			slog.Int("batch_size", len(l.goodsBatch)),
 			slog.Duration("duration", duration))
 	} else {
+		metrics.Flushes.WithLabelValues(metrics.FlushSuccess).Inc()
 		l.log.Info("Successfully flushed batch to ClickHouse",
 			slog.Int("batch_size", len(l.goodsBatch)),
 			slog.Duration("duration", duration))
 	}
 
 	l.goodsBatch = l.goodsBatch[:0]
+	l.batchLinks = l.batchLinks[:0]
 }
@@ -10,4 +10,8 @@ type Good struct {
 	Priority    int       `json:"priority"`
 	Removed     bool      `json:"removed"`
 	CreatedAt   time.Time `json:"createdAt"`
+	Actor       string    `json:"actor"`
+	Type        string    `json:"type"`
+	// FromProjectID is set on moved events to the project the good left.
+	FromProjectID int `json:"fromProjectId"`
 }
@@ -7,7 +7,10 @@ import (
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/config"
+	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/lib/tracing"
 	"github.com/Gonnekone/hezzl-test/clickhouse-service/internal/models"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"time"
 )
 
@@ -59,6 +62,16 @@ func New(cfg config.ClickHouseStorage) (*ClickHouseStorage, error) {
 	return &ClickHouseStorage{db: conn}, nil
 }
 
+func (s *ClickHouseStorage) Ping(ctx context.Context) error {
+	const op = "storage.clickhouse.Ping"
+
+	if err := s.db.Ping(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
 func (s *ClickHouseStorage) LogGoods(
 	ctx context.Context,
 	goods []models.Good,
@@ -69,8 +82,21 @@ func (s *ClickHouseStorage) LogGoods(
 		return nil
 	}
 
+	ctx, span := tracing.Tracer().Start(ctx, "INSERT hezzl.goods",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemClickhouse,
+			semconv.DBNamespace("hezzl"),
+			semconv.DBOperationName("INSERT"),
+		),
+	)
+	defer span.End()
+
+	// Passes the trace to ClickHouse, so it shows up in its query log.
+	ctx = clickhouse.Context(ctx, clickhouse.WithSpan(span.SpanContext()))
+
 	batch, err := s.db.PrepareBatch(ctx, `
-		INSERT INTO hezzl.goods (Id, ProjectId, Name, Description, Priority, Removed)
+		INSERT INTO hezzl.goods (Id, ProjectId, Name, Description, Priority, Removed, Actor, Type, FromProjectId)
 	`)
 	if err != nil {
 		return fmt.Errorf("%s: prepare batch: %w", op, err)
@@ -89,6 +115,9 @@ func (s *ClickHouseStorage) LogGoods(
 			good.Description,
 			good.Priority,
 			removed,
+			good.Actor,
+			good.Type,
+			good.FromProjectID,
 		)
 		if err != nil {
 			return fmt.Errorf("%s: append item %d to batch: %w", op, i, err)
@@ -0,0 +1,2 @@
+ALTER TABLE hezzl.goods
+    DROP COLUMN IF EXISTS Actor;
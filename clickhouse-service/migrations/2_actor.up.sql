@@ -0,0 +1,2 @@
+ALTER TABLE hezzl.goods
+    ADD COLUMN IF NOT EXISTS Actor String DEFAULT '' AFTER Removed;
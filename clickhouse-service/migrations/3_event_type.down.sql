@@ -0,0 +1,5 @@
+ALTER TABLE hezzl.goods
+    DROP COLUMN IF EXISTS FromProjectId;
+
+ALTER TABLE hezzl.goods
+    DROP COLUMN IF EXISTS Type;
@@ -0,0 +1,5 @@
+ALTER TABLE hezzl.goods
+    ADD COLUMN IF NOT EXISTS Type LowCardinality(String) DEFAULT '' AFTER Actor;
+
+ALTER TABLE hezzl.goods
+    ADD COLUMN IF NOT EXISTS FromProjectId UInt32 DEFAULT 0 AFTER Type;
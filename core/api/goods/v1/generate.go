@@ -0,0 +1,4 @@
+// Package goodsv1 contains the generated code of the goods gRPC API.
+package goodsv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative goods/v1/goods.proto
@@ -0,0 +1,900 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: goods/v1/goods.proto
+
+package goodsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Good struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id          int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ProjectId   int64                  `protobuf:"varint,2,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	Name        string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Description string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	// Position of the good in its project, starting at 1.
+	Priority  int64                  `protobuf:"varint,5,opt,name=priority,proto3" json:"priority,omitempty"`
+	Removed   bool                   `protobuf:"varint,6,opt,name=removed,proto3" json:"removed,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Grows by one on every change of the good. Moving other goods shifts
+	// its priority but not its version.
+	Version       int64 `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Good) Reset() {
+	*x = Good{}
+	mi := &file_goods_v1_goods_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Good) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Good) ProtoMessage() {}
+
+func (x *Good) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Good.ProtoReflect.Descriptor instead.
+func (*Good) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Good) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Good) GetProjectId() int64 {
+	if x != nil {
+		return x.ProjectId
+	}
+	return 0
+}
+
+func (x *Good) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Good) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Good) GetPriority() int64 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *Good) GetRemoved() bool {
+	if x != nil {
+		return x.Removed
+	}
+	return false
+}
+
+func (x *Good) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Good) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type CreateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProjectId     int64                  `protobuf:"varint,1,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRequest) Reset() {
+	*x = CreateRequest{}
+	mi := &file_goods_v1_goods_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRequest) ProtoMessage() {}
+
+func (x *CreateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRequest.ProtoReflect.Descriptor instead.
+func (*CreateRequest) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateRequest) GetProjectId() int64 {
+	if x != nil {
+		return x.ProjectId
+	}
+	return 0
+}
+
+func (x *CreateRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type GetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProjectId     int64                  `protobuf:"varint,1,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	Id            int64                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequest) Reset() {
+	*x = GetRequest{}
+	mi := &file_goods_v1_goods_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequest) ProtoMessage() {}
+
+func (x *GetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetRequest) GetProjectId() int64 {
+	if x != nil {
+		return x.ProjectId
+	}
+	return 0
+}
+
+func (x *GetRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Goods of every project are listed when project_id is 0, admins only.
+	ProjectId int64 `protobuf:"varint,1,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	// Defaults to 10.
+	Limit int64 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	// Defaults to 1 when unset, like the offset of the HTTP list.
+	Offset        *int64 `protobuf:"varint,3,opt,name=offset,proto3,oneof" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_goods_v1_goods_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListRequest) GetProjectId() int64 {
+	if x != nil {
+		return x.ProjectId
+	}
+	return 0
+}
+
+func (x *ListRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListRequest) GetOffset() int64 {
+	if x != nil && x.Offset != nil {
+		return *x.Offset
+	}
+	return 0
+}
+
+type ListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *ListResponse_Meta     `protobuf:"bytes,1,opt,name=meta,proto3" json:"meta,omitempty"`
+	Goods         []*Good                `protobuf:"bytes,2,rep,name=goods,proto3" json:"goods,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListResponse) Reset() {
+	*x = ListResponse{}
+	mi := &file_goods_v1_goods_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListResponse) ProtoMessage() {}
+
+func (x *ListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListResponse.ProtoReflect.Descriptor instead.
+func (*ListResponse) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListResponse) GetMeta() *ListResponse_Meta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *ListResponse) GetGoods() []*Good {
+	if x != nil {
+		return x.Goods
+	}
+	return nil
+}
+
+type UpdateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProjectId     int64                  `protobuf:"varint,1,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	Id            int64                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateRequest) Reset() {
+	*x = UpdateRequest{}
+	mi := &file_goods_v1_goods_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRequest) ProtoMessage() {}
+
+func (x *UpdateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRequest.ProtoReflect.Descriptor instead.
+func (*UpdateRequest) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdateRequest) GetProjectId() int64 {
+	if x != nil {
+		return x.ProjectId
+	}
+	return 0
+}
+
+func (x *UpdateRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *UpdateRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UpdateRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type RemoveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProjectId     int64                  `protobuf:"varint,1,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	Id            int64                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveRequest) Reset() {
+	*x = RemoveRequest{}
+	mi := &file_goods_v1_goods_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveRequest) ProtoMessage() {}
+
+func (x *RemoveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveRequest.ProtoReflect.Descriptor instead.
+func (*RemoveRequest) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RemoveRequest) GetProjectId() int64 {
+	if x != nil {
+		return x.ProjectId
+	}
+	return 0
+}
+
+func (x *RemoveRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type RemoveResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ProjectId     int64                  `protobuf:"varint,2,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	Removed       bool                   `protobuf:"varint,3,opt,name=removed,proto3" json:"removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveResponse) Reset() {
+	*x = RemoveResponse{}
+	mi := &file_goods_v1_goods_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveResponse) ProtoMessage() {}
+
+func (x *RemoveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveResponse.ProtoReflect.Descriptor instead.
+func (*RemoveResponse) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RemoveResponse) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *RemoveResponse) GetProjectId() int64 {
+	if x != nil {
+		return x.ProjectId
+	}
+	return 0
+}
+
+func (x *RemoveResponse) GetRemoved() bool {
+	if x != nil {
+		return x.Removed
+	}
+	return false
+}
+
+type ReprioritizeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProjectId     int64                  `protobuf:"varint,1,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	Id            int64                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	NewPriority   int64                  `protobuf:"varint,3,opt,name=new_priority,json=newPriority,proto3" json:"new_priority,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReprioritizeRequest) Reset() {
+	*x = ReprioritizeRequest{}
+	mi := &file_goods_v1_goods_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReprioritizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReprioritizeRequest) ProtoMessage() {}
+
+func (x *ReprioritizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReprioritizeRequest.ProtoReflect.Descriptor instead.
+func (*ReprioritizeRequest) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ReprioritizeRequest) GetProjectId() int64 {
+	if x != nil {
+		return x.ProjectId
+	}
+	return 0
+}
+
+func (x *ReprioritizeRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ReprioritizeRequest) GetNewPriority() int64 {
+	if x != nil {
+		return x.NewPriority
+	}
+	return 0
+}
+
+type ReprioritizeResponse struct {
+	state         protoimpl.MessageState           `protogen:"open.v1"`
+	Priorities    []*ReprioritizeResponse_Priority `protobuf:"bytes,1,rep,name=priorities,proto3" json:"priorities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReprioritizeResponse) Reset() {
+	*x = ReprioritizeResponse{}
+	mi := &file_goods_v1_goods_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReprioritizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReprioritizeResponse) ProtoMessage() {}
+
+func (x *ReprioritizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReprioritizeResponse.ProtoReflect.Descriptor instead.
+func (*ReprioritizeResponse) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ReprioritizeResponse) GetPriorities() []*ReprioritizeResponse_Priority {
+	if x != nil {
+		return x.Priorities
+	}
+	return nil
+}
+
+type ListResponse_Meta struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int64                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Removed       int64                  `protobuf:"varint,2,opt,name=removed,proto3" json:"removed,omitempty"`
+	Limit         int64                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset        int64                  `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListResponse_Meta) Reset() {
+	*x = ListResponse_Meta{}
+	mi := &file_goods_v1_goods_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListResponse_Meta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListResponse_Meta) ProtoMessage() {}
+
+func (x *ListResponse_Meta) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListResponse_Meta.ProtoReflect.Descriptor instead.
+func (*ListResponse_Meta) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{4, 0}
+}
+
+func (x *ListResponse_Meta) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ListResponse_Meta) GetRemoved() int64 {
+	if x != nil {
+		return x.Removed
+	}
+	return 0
+}
+
+func (x *ListResponse_Meta) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListResponse_Meta) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type ReprioritizeResponse_Priority struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Priority      int64                  `protobuf:"varint,2,opt,name=priority,proto3" json:"priority,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReprioritizeResponse_Priority) Reset() {
+	*x = ReprioritizeResponse_Priority{}
+	mi := &file_goods_v1_goods_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReprioritizeResponse_Priority) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReprioritizeResponse_Priority) ProtoMessage() {}
+
+func (x *ReprioritizeResponse_Priority) ProtoReflect() protoreflect.Message {
+	mi := &file_goods_v1_goods_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReprioritizeResponse_Priority.ProtoReflect.Descriptor instead.
+func (*ReprioritizeResponse_Priority) Descriptor() ([]byte, []int) {
+	return file_goods_v1_goods_proto_rawDescGZIP(), []int{9, 0}
+}
+
+func (x *ReprioritizeResponse_Priority) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ReprioritizeResponse_Priority) GetPriority() int64 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+var File_goods_v1_goods_proto protoreflect.FileDescriptor
+
+const file_goods_v1_goods_proto_rawDesc = "" +
+	"\n" +
+	"\x14goods/v1/goods.proto\x12\bgoods.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf6\x01\n" +
+	"\x04Good\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x02 \x01(\x03R\tprojectId\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12\x1a\n" +
+	"\bpriority\x18\x05 \x01(\x03R\bpriority\x12\x18\n" +
+	"\aremoved\x18\x06 \x01(\bR\aremoved\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x18\n" +
+	"\aversion\x18\b \x01(\x03R\aversion\"B\n" +
+	"\rCreateRequest\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x01 \x01(\x03R\tprojectId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\";\n" +
+	"\n" +
+	"GetRequest\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x01 \x01(\x03R\tprojectId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x03R\x02id\"j\n" +
+	"\vListRequest\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x01 \x01(\x03R\tprojectId\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x03R\x05limit\x12\x1b\n" +
+	"\x06offset\x18\x03 \x01(\x03H\x00R\x06offset\x88\x01\x01B\t\n" +
+	"\a_offset\"\xcb\x01\n" +
+	"\fListResponse\x12/\n" +
+	"\x04meta\x18\x01 \x01(\v2\x1b.goods.v1.ListResponse.MetaR\x04meta\x12$\n" +
+	"\x05goods\x18\x02 \x03(\v2\x0e.goods.v1.GoodR\x05goods\x1ad\n" +
+	"\x04Meta\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x03R\x05total\x12\x18\n" +
+	"\aremoved\x18\x02 \x01(\x03R\aremoved\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x03R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x03R\x06offset\"t\n" +
+	"\rUpdateRequest\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x01 \x01(\x03R\tprojectId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\">\n" +
+	"\rRemoveRequest\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x01 \x01(\x03R\tprojectId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x03R\x02id\"Y\n" +
+	"\x0eRemoveResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x02 \x01(\x03R\tprojectId\x12\x18\n" +
+	"\aremoved\x18\x03 \x01(\bR\aremoved\"g\n" +
+	"\x13ReprioritizeRequest\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x01 \x01(\x03R\tprojectId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x03R\x02id\x12!\n" +
+	"\fnew_priority\x18\x03 \x01(\x03R\vnewPriority\"\x97\x01\n" +
+	"\x14ReprioritizeResponse\x12G\n" +
+	"\n" +
+	"priorities\x18\x01 \x03(\v2'.goods.v1.ReprioritizeResponse.PriorityR\n" +
+	"priorities\x1a6\n" +
+	"\bPriority\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1a\n" +
+	"\bpriority\x18\x02 \x01(\x03R\bpriority2\xe4\x02\n" +
+	"\fGoodsService\x121\n" +
+	"\x06Create\x12\x17.goods.v1.CreateRequest\x1a\x0e.goods.v1.Good\x12+\n" +
+	"\x03Get\x12\x14.goods.v1.GetRequest\x1a\x0e.goods.v1.Good\x125\n" +
+	"\x04List\x12\x15.goods.v1.ListRequest\x1a\x16.goods.v1.ListResponse\x121\n" +
+	"\x06Update\x12\x17.goods.v1.UpdateRequest\x1a\x0e.goods.v1.Good\x12;\n" +
+	"\x06Remove\x12\x17.goods.v1.RemoveRequest\x1a\x18.goods.v1.RemoveResponse\x12M\n" +
+	"\fReprioritize\x12\x1d.goods.v1.ReprioritizeRequest\x1a\x1e.goods.v1.ReprioritizeResponseB;Z9github.com/Gonnekone/hezzl-test/core/api/goods/v1;goodsv1b\x06proto3"
+
+var (
+	file_goods_v1_goods_proto_rawDescOnce sync.Once
+	file_goods_v1_goods_proto_rawDescData []byte
+)
+
+func file_goods_v1_goods_proto_rawDescGZIP() []byte {
+	file_goods_v1_goods_proto_rawDescOnce.Do(func() {
+		file_goods_v1_goods_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_goods_v1_goods_proto_rawDesc), len(file_goods_v1_goods_proto_rawDesc)))
+	})
+	return file_goods_v1_goods_proto_rawDescData
+}
+
+var file_goods_v1_goods_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_goods_v1_goods_proto_goTypes = []any{
+	(*Good)(nil),                          // 0: goods.v1.Good
+	(*CreateRequest)(nil),                 // 1: goods.v1.CreateRequest
+	(*GetRequest)(nil),                    // 2: goods.v1.GetRequest
+	(*ListRequest)(nil),                   // 3: goods.v1.ListRequest
+	(*ListResponse)(nil),                  // 4: goods.v1.ListResponse
+	(*UpdateRequest)(nil),                 // 5: goods.v1.UpdateRequest
+	(*RemoveRequest)(nil),                 // 6: goods.v1.RemoveRequest
+	(*RemoveResponse)(nil),                // 7: goods.v1.RemoveResponse
+	(*ReprioritizeRequest)(nil),           // 8: goods.v1.ReprioritizeRequest
+	(*ReprioritizeResponse)(nil),          // 9: goods.v1.ReprioritizeResponse
+	(*ListResponse_Meta)(nil),             // 10: goods.v1.ListResponse.Meta
+	(*ReprioritizeResponse_Priority)(nil), // 11: goods.v1.ReprioritizeResponse.Priority
+	(*timestamppb.Timestamp)(nil),         // 12: google.protobuf.Timestamp
+}
+var file_goods_v1_goods_proto_depIdxs = []int32{
+	12, // 0: goods.v1.Good.created_at:type_name -> google.protobuf.Timestamp
+	10, // 1: goods.v1.ListResponse.meta:type_name -> goods.v1.ListResponse.Meta
+	0,  // 2: goods.v1.ListResponse.goods:type_name -> goods.v1.Good
+	11, // 3: goods.v1.ReprioritizeResponse.priorities:type_name -> goods.v1.ReprioritizeResponse.Priority
+	1,  // 4: goods.v1.GoodsService.Create:input_type -> goods.v1.CreateRequest
+	2,  // 5: goods.v1.GoodsService.Get:input_type -> goods.v1.GetRequest
+	3,  // 6: goods.v1.GoodsService.List:input_type -> goods.v1.ListRequest
+	5,  // 7: goods.v1.GoodsService.Update:input_type -> goods.v1.UpdateRequest
+	6,  // 8: goods.v1.GoodsService.Remove:input_type -> goods.v1.RemoveRequest
+	8,  // 9: goods.v1.GoodsService.Reprioritize:input_type -> goods.v1.ReprioritizeRequest
+	0,  // 10: goods.v1.GoodsService.Create:output_type -> goods.v1.Good
+	0,  // 11: goods.v1.GoodsService.Get:output_type -> goods.v1.Good
+	4,  // 12: goods.v1.GoodsService.List:output_type -> goods.v1.ListResponse
+	0,  // 13: goods.v1.GoodsService.Update:output_type -> goods.v1.Good
+	7,  // 14: goods.v1.GoodsService.Remove:output_type -> goods.v1.RemoveResponse
+	9,  // 15: goods.v1.GoodsService.Reprioritize:output_type -> goods.v1.ReprioritizeResponse
+	10, // [10:16] is the sub-list for method output_type
+	4,  // [4:10] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_goods_v1_goods_proto_init() }
+func file_goods_v1_goods_proto_init() {
+	if File_goods_v1_goods_proto != nil {
+		return
+	}
+	file_goods_v1_goods_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_goods_v1_goods_proto_rawDesc), len(file_goods_v1_goods_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_goods_v1_goods_proto_goTypes,
+		DependencyIndexes: file_goods_v1_goods_proto_depIdxs,
+		MessageInfos:      file_goods_v1_goods_proto_msgTypes,
+	}.Build()
+	File_goods_v1_goods_proto = out.File
+	file_goods_v1_goods_proto_goTypes = nil
+	file_goods_v1_goods_proto_depIdxs = nil
+}
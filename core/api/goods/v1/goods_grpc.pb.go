@@ -0,0 +1,319 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: goods/v1/goods.proto
+
+package goodsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	GoodsService_Create_FullMethodName       = "/goods.v1.GoodsService/Create"
+	GoodsService_Get_FullMethodName          = "/goods.v1.GoodsService/Get"
+	GoodsService_List_FullMethodName         = "/goods.v1.GoodsService/List"
+	GoodsService_Update_FullMethodName       = "/goods.v1.GoodsService/Update"
+	GoodsService_Remove_FullMethodName       = "/goods.v1.GoodsService/Remove"
+	GoodsService_Reprioritize_FullMethodName = "/goods.v1.GoodsService/Reprioritize"
+)
+
+// GoodsServiceClient is the client API for GoodsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// GoodsService mirrors the goods routes of the HTTP API. Errors carry a
+// google.rpc.ErrorInfo detail whose reason is the problem code of the HTTP
+// API, e.g. "not_found" or "validation_failed".
+type GoodsServiceClient interface {
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*Good, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Good, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Good, error)
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error)
+	Reprioritize(ctx context.Context, in *ReprioritizeRequest, opts ...grpc.CallOption) (*ReprioritizeResponse, error)
+}
+
+type goodsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGoodsServiceClient(cc grpc.ClientConnInterface) GoodsServiceClient {
+	return &goodsServiceClient{cc}
+}
+
+func (c *goodsServiceClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*Good, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Good)
+	err := c.cc.Invoke(ctx, GoodsService_Create_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *goodsServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Good, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Good)
+	err := c.cc.Invoke(ctx, GoodsService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *goodsServiceClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, GoodsService_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *goodsServiceClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Good, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Good)
+	err := c.cc.Invoke(ctx, GoodsService_Update_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *goodsServiceClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveResponse)
+	err := c.cc.Invoke(ctx, GoodsService_Remove_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *goodsServiceClient) Reprioritize(ctx context.Context, in *ReprioritizeRequest, opts ...grpc.CallOption) (*ReprioritizeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReprioritizeResponse)
+	err := c.cc.Invoke(ctx, GoodsService_Reprioritize_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GoodsServiceServer is the server API for GoodsService service.
+// All implementations must embed UnimplementedGoodsServiceServer
+// for forward compatibility.
+//
+// GoodsService mirrors the goods routes of the HTTP API. Errors carry a
+// google.rpc.ErrorInfo detail whose reason is the problem code of the HTTP
+// API, e.g. "not_found" or "validation_failed".
+type GoodsServiceServer interface {
+	Create(context.Context, *CreateRequest) (*Good, error)
+	Get(context.Context, *GetRequest) (*Good, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Update(context.Context, *UpdateRequest) (*Good, error)
+	Remove(context.Context, *RemoveRequest) (*RemoveResponse, error)
+	Reprioritize(context.Context, *ReprioritizeRequest) (*ReprioritizeResponse, error)
+	mustEmbedUnimplementedGoodsServiceServer()
+}
+
+// UnimplementedGoodsServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGoodsServiceServer struct{}
+
+func (UnimplementedGoodsServiceServer) Create(context.Context, *CreateRequest) (*Good, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedGoodsServiceServer) Get(context.Context, *GetRequest) (*Good, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedGoodsServiceServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedGoodsServiceServer) Update(context.Context, *UpdateRequest) (*Good, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedGoodsServiceServer) Remove(context.Context, *RemoveRequest) (*RemoveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedGoodsServiceServer) Reprioritize(context.Context, *ReprioritizeRequest) (*ReprioritizeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reprioritize not implemented")
+}
+func (UnimplementedGoodsServiceServer) mustEmbedUnimplementedGoodsServiceServer() {}
+func (UnimplementedGoodsServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeGoodsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GoodsServiceServer will
+// result in compilation errors.
+type UnsafeGoodsServiceServer interface {
+	mustEmbedUnimplementedGoodsServiceServer()
+}
+
+func RegisterGoodsServiceServer(s grpc.ServiceRegistrar, srv GoodsServiceServer) {
+	// If the following call pancis, it indicates UnimplementedGoodsServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&GoodsService_ServiceDesc, srv)
+}
+
+func _GoodsService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoodsServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GoodsService_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoodsServiceServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GoodsService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoodsServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GoodsService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoodsServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GoodsService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoodsServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GoodsService_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoodsServiceServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GoodsService_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoodsServiceServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GoodsService_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoodsServiceServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GoodsService_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoodsServiceServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GoodsService_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoodsServiceServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GoodsService_Reprioritize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReprioritizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoodsServiceServer).Reprioritize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GoodsService_Reprioritize_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoodsServiceServer).Reprioritize(ctx, req.(*ReprioritizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// GoodsService_ServiceDesc is the grpc.ServiceDesc for GoodsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GoodsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "goods.v1.GoodsService",
+	HandlerType: (*GoodsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _GoodsService_Create_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _GoodsService_Get_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _GoodsService_List_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _GoodsService_Update_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _GoodsService_Remove_Handler,
+		},
+		{
+			MethodName: "Reprioritize",
+			Handler:    _GoodsService_Reprioritize_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "goods/v1/goods.proto",
+}
@@ -3,15 +3,23 @@ package main
 import (
 	"context"
 	"errors"
-	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/create"
-	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
-	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/remove"
-	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
-	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/update"
+	"github.com/Gonnekone/hezzl-test/core/internal/compaction"
+	"github.com/Gonnekone/hezzl-test/core/internal/events"
+	grpcServer "github.com/Gonnekone/hezzl-test/core/internal/grpc-server/server"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/graphql"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/health"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/openapi"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/router"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/tracing"
 	"github.com/Gonnekone/hezzl-test/core/internal/producer"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
 	"github.com/Gonnekone/hezzl-test/core/internal/storage"
-	"github.com/go-chi/chi/v5"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/clickhouse"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/redis"
+	"google.golang.org/grpc"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -19,10 +27,8 @@ import (
 	"time"
 
 	"github.com/Gonnekone/hezzl-test/core/internal/config"
-	mwLogger "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/logger"
 	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogpretty"
 	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
-	"github.com/go-chi/chi/v5/middleware"
 )
 
 const (
@@ -38,17 +44,39 @@ func main() {
 	log.Info("starting up the application", slog.String("env", cfg.Env))
 	log.Debug("debug messages are enabled")
 
-	superStorage, err := storage.New(cfg.PostgresStorage, cfg.RedisStorage)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Error("failed to set up tracing", sl.Err(err))
+		os.Exit(1)
+	}
+
+	superStorage, err := storage.New(log, cfg.PostgresStorage, cfg.RedisStorage)
 	if err != nil {
 		log.Error("failed to create storage", sl.Err(err))
 		os.Exit(1)
 	}
 
-	router := chi.NewRouter()
-	router.Use(middleware.RequestID)
-	router.Use(mwLogger.New(log))
-	router.Use(middleware.Recoverer)
-	router.Use(middleware.URLFormat)
+	// Rate limits and idempotency keys must be shared by all core instances,
+	// so they live in Redis even when the cache does not. The client connects lazily.
+	sharedRedis, ok := superStorage.Cache.(*redis.RedisStorage)
+	if !ok {
+		sharedRedis = redis.New(cfg.RedisStorage)
+	}
+
+	// History is only read by GraphQL, so ClickHouse being down does not
+	// make core unready. The client connects lazily.
+	var history graphql.HistoryReader
+
+	if cfg.History.Enabled {
+		historyStorage, err := clickhouse.New(cfg.History)
+		if err != nil {
+			log.Error("failed to create history storage", sl.Err(err))
+			os.Exit(1)
+		}
+		defer historyStorage.Close() //nolint: errcheck
+
+		history = historyStorage
+	}
 
 	producer, err := producer.New(log, cfg.Nats)
 	if err != nil {
@@ -56,12 +84,47 @@ func main() {
 		os.Exit(1)
 	}
 
-	router.Post("/good/create", create.New(log, superStorage, producer))
-	router.Patch("/good/update", update.New(log, superStorage, producer))
-	router.Delete("/good/remove", remove.New(log, superStorage, producer))
-	router.Patch("/good/reprioritize", reprioritize.New(log, superStorage, producer))
+	jwtVerifier, err := auth.NewJWTVerifier(cfg.Auth.JWT)
+	if err != nil && !errors.Is(err, auth.ErrNoVerificationKey) {
+		log.Error("failed to create jwt verifier", sl.Err(err))
+		os.Exit(1)
+	}
 
-	router.Get("/goods/list", list.New(log, superStorage))
+	// Without an admin key or admin tokens nobody could issue the first API key.
+	if cfg.Auth.Enabled && cfg.Auth.AdminKey == "" && jwtVerifier == nil {
+		log.Error("auth is enabled, but neither admin key nor jwt is configured")
+		os.Exit(1)
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Error("failed to load openapi spec", sl.Err(err))
+		os.Exit(1)
+	}
+
+	deps := map[string]health.Pinger{
+		"postgres": superStorage.PostgresStorage,
+		"nats":     producer,
+	}
+	if cfg.RedisStorage.Mode == config.CacheModeRedis || cfg.RateLimit.Enabled || cfg.Idempotency.Enabled {
+		deps["redis"] = sharedRedis
+	}
+
+	probes := health.New(log, deps)
+
+	goods := goodsService.New(log, superStorage, producer)
+	compactor := compaction.New(log, superStorage, goods)
+
+	router := router.New(log, cfg, router.Deps{
+		Storage:     superStorage,
+		Goods:       goods,
+		History:     history,
+		SharedState: sharedRedis,
+		Events:      events.NewJetStream(log, producer.JetStream(), cfg.Nats.Subject),
+		JWTVerifier: jwtVerifier,
+		Probes:      probes,
+		Spec:        spec,
+	})
 
 	log.Info("starting server", slog.String("address", cfg.HTTPServer.Address))
 
@@ -82,15 +145,56 @@ func main() {
 		}
 	}()
 
+	var grpcSrv *grpc.Server
+
+	if cfg.GRPCServer.Address != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCServer.Address)
+		if err != nil {
+			log.Error("failed to listen for grpc", sl.Err(err))
+			os.Exit(1)
+		}
+
+		grpcSrv = grpcServer.New(log, cfg, grpcServer.Deps{
+			Storage:     superStorage,
+			Goods:       goods,
+			SharedState: sharedRedis,
+			JWTVerifier: jwtVerifier,
+		})
+
+		log.Info("starting grpc server", slog.String("address", cfg.GRPCServer.Address))
+
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Error("failed to start grpc server", sl.Err(err))
+			}
+		}()
+	}
+
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	if cfg.Compaction.Interval > 0 {
+		go compactor.Run(jobCtx, cfg.Compaction.Interval)
+	}
+
 	log.Info("server started")
 
 	<-done
 	log.Info("stopping server")
 
+	stopJobs()
+
+	probes.Shutdown()
+	time.Sleep(cfg.HTTPServer.ShutdownDelay)
+
 	//nolint: mnd
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if grpcSrv != nil {
+		stopGRPC(ctx, grpcSrv)
+	}
+
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("failed to stop server", sl.Err(err))
 
@@ -100,13 +204,40 @@ func main() {
 	log.Debug("closing storage")
 
 	superStorage.PostgresStorage.Close()
-	superStorage.RedisStorage.Close()
+	if err := superStorage.Cache.Close(); err != nil {
+		log.Error("failed to close cache", sl.Err(err))
+	}
+
+	if superStorage.Cache != storage.Cache(sharedRedis) {
+		sharedRedis.Close() //nolint: errcheck
+	}
 
 	producer.Close()
 
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("failed to flush traces", sl.Err(err))
+	}
+
 	log.Info("server stopped")
 }
 
+// stopGRPC waits for running calls to finish until ctx is done, then
+// cancels them.
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
+
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
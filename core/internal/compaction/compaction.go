@@ -0,0 +1,83 @@
+// Package compaction spreads the ranks of a project evenly again once
+// moves have made them long. A project is compacted on demand by an admin
+// or by the job, which looks for projects to compact on every tick.
+package compaction
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+)
+
+// ActorJob is the actor of the events the job publishes.
+const ActorJob = "system:compaction"
+
+type Storage interface {
+	ListProjectsToCompact(ctx context.Context) ([]int, error)
+}
+
+// Goods is implemented by goods.Service.
+type Goods interface {
+	Compact(ctx context.Context, projectID string) ([]models.Good, error)
+}
+
+type Compactor struct {
+	log     *slog.Logger
+	storage Storage
+	goods   Goods
+}
+
+func New(log *slog.Logger, storage Storage, goods Goods) *Compactor {
+	return &Compactor{
+		log:     log.With(slog.String("component", "compaction")),
+		storage: storage,
+		goods:   goods,
+	}
+}
+
+// Run compacts the projects that need it every interval until ctx is done.
+// Every core instance may run it, the project lock makes a second run
+// over the same project a no-op.
+func (c *Compactor) Run(ctx context.Context, interval time.Duration) {
+	c.log.Info("compaction job started", slog.Duration("interval", interval))
+
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{Actor: ActorJob, Admin: true})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.log.Info("compaction job stopped")
+
+			return
+		case <-ticker.C:
+			c.compactPending(ctx)
+		}
+	}
+}
+
+func (c *Compactor) compactPending(ctx context.Context) {
+	ids, err := c.storage.ListProjectsToCompact(ctx)
+	if err != nil {
+		c.log.Error("failed to list projects to compact", sl.Err(err))
+
+		return
+	}
+
+	for _, id := range ids {
+		if _, err := c.goods.Compact(ctx, strconv.Itoa(id)); err != nil {
+			c.log.Error("failed to compact project", sl.Err(err), slog.Int("project_id", id))
+
+			continue
+		}
+
+		c.log.Info("project compacted", slog.Int("project_id", id))
+	}
+}
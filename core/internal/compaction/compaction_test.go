@@ -0,0 +1,114 @@
+package compaction_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/compaction"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage reports projects 1 and 2 as due until they are compacted.
+type fakeStorage struct {
+	goodsService.Storage
+
+	mu          sync.Mutex
+	compacted   map[string]bool
+	invalidated []string
+	failProject string
+}
+
+func (s *fakeStorage) CompactGoods(_ context.Context, projectID string) ([]models.Good, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if projectID == s.failProject {
+		return nil, errors.New("fake: connection refused")
+	}
+
+	s.compacted[projectID] = true
+
+	return nil, nil
+}
+
+func (s *fakeStorage) ListProjectsToCompact(context.Context) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []int
+	for _, id := range []int{1, 2} {
+		if !s.compacted[strconv.Itoa(id)] {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *fakeStorage) InvalidList(_ context.Context, projectID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.invalidated = append(s.invalidated, projectID)
+
+	return nil
+}
+
+func (*fakeStorage) InvalidGoods(context.Context, ...models.Good) error { return nil }
+
+func (s *fakeStorage) isCompacted(projectID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compacted[projectID]
+}
+
+type fakeProducer struct {
+	sent chan []byte
+}
+
+func (p fakeProducer) Send(_ context.Context, data []byte) error {
+	p.sent <- data
+
+	return nil
+}
+
+func (p fakeProducer) SendAsync(ctx context.Context, data []byte) error {
+	return p.Send(ctx, data)
+}
+
+func TestRunCompactsPendingProjects(t *testing.T) {
+	storage := &fakeStorage{compacted: map[string]bool{}, failProject: "1"}
+	producer := fakeProducer{sent: make(chan []byte, 4)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		log := slogdiscard.NewDiscardLogger()
+		compaction.New(log, storage, goodsService.New(log, storage, producer)).Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	// Project 1 fails on every tick, project 2 is still compacted.
+	require.Eventually(t, func() bool { return storage.isCompacted("2") }, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+
+	// Compaction keeps every priority, so nothing is published.
+	require.Empty(t, producer.sent)
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	require.Equal(t, "2", storage.invalidated[0])
+	require.False(t, storage.compacted["1"])
+}
@@ -16,7 +16,16 @@ type Config struct {
 	Nats            Nats            `yaml:"nats"`
 	PostgresStorage PostgresStorage `yaml:"postgres_storage"`
 	RedisStorage    RedisStorage    `yaml:"redis_storage"`
+	History         History         `yaml:"history"`
 	HTTPServer      HTTPServer      `yaml:"http_server"`
+	GRPCServer      GRPCServer      `yaml:"grpc_server"`
+	Auth            Auth            `yaml:"auth"`
+	RateLimit       RateLimit       `yaml:"rate_limit"`
+	Idempotency     Idempotency     `yaml:"idempotency"`
+	Tracing         Tracing         `yaml:"tracing"`
+	OpenAPI         OpenAPI         `yaml:"openapi"`
+	Events          Events          `yaml:"events"`
+	Compaction      Compaction      `yaml:"compaction"`
 }
 
 type Nats struct {
@@ -41,17 +50,158 @@ type PostgresStorage struct {
 	Password string `yaml:"password" env-default:"postgres"`
 }
 
+// Cache modes supported by RedisStorage.Mode.
+const (
+	CacheModeRedis  = "redis"
+	CacheModeMemory = "memory"
+	CacheModeNone   = "none"
+)
+
 type RedisStorage struct {
-	Addr       string `yaml:"addr" env-default:"redis"`
-	Password   string `yaml:"password" env-default:"password"`
-	DB         int    `yaml:"db" env-default:"0"`
-	MaxRetries int    `yaml:"max_retries" env-default:"3"`
+	// Mode selects the cache backend: redis, memory (in-process LRU)
+	// or none to read everything from Postgres.
+	Mode string `yaml:"mode" env:"CACHE_MODE" env-default:"redis"`
+
+	Addr       string        `yaml:"addr" env-default:"redis"`
+	Password   string        `yaml:"password" env-default:"password"`
+	DB         int           `yaml:"db" env-default:"0"`
+	MaxRetries int           `yaml:"max_retries" env-default:"3"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"500ms"`
+
+	// MemorySize is the number of entries kept by the memory cache.
+	MemorySize int `yaml:"memory_size" env-default:"10000"`
+
+	GoodTTL time.Duration `yaml:"good_ttl" env-default:"1m"`
+
+	// ListSoftTTL is how long a cached list page is considered fresh,
+	// ListHardTTL is how long it is kept at all. Between the two a page is
+	// stale and served only when StaleWhileRevalidate is enabled.
+	ListSoftTTL          time.Duration `yaml:"list_soft_ttl" env-default:"30s"`
+	ListHardTTL          time.Duration `yaml:"list_hard_ttl" env-default:"1m"`
+	StaleWhileRevalidate bool          `yaml:"stale_while_revalidate" env-default:"false"`
+}
+
+// History reads the events of goods the listener of clickhouse-service
+// stores in ClickHouse, for the history fields of GraphQL.
+type History struct {
+	// Enabled is false to answer history fields with an error instead.
+	Enabled  bool   `yaml:"enabled" env:"HISTORY_ENABLED" env-default:"false"`
+	Addr     string `yaml:"addr" env:"HISTORY_ADDR" env-default:"clickhouse:9000"`
+	User     string `yaml:"user" env-default:"hezzl_admin"`
+	Password string `yaml:"password" env-default:"hezzl_password"`
+	DB       string `yaml:"db" env-default:"hezzl"`
+}
+
+type Auth struct {
+	// Enabled turns off authentication when false, every request then acts
+	// as an admin. Meant for local development only.
+	Enabled bool `yaml:"enabled" env:"AUTH_ENABLED" env-default:"true"`
+	// AdminKey is a static key allowed to act on every project and to
+	// issue and revoke API keys.
+	AdminKey string `yaml:"admin_key" env:"ADMIN_KEY"`
+	// TicketTTL is how long a ticket from /auth/tickets may be redeemed.
+	TicketTTL time.Duration `yaml:"ticket_ttl" env:"AUTH_TICKET_TTL" env-default:"30s"`
+
+	JWT JWT `yaml:"jwt"`
+}
+
+// JWT configures bearer token verification. Tokens are signed either with
+// the shared HMAC Secret or with a key from the local JWKSFile; leaving
+// both empty disables bearer tokens.
+type JWT struct {
+	Secret   string `yaml:"secret" env:"JWT_SECRET"`
+	JWKSFile string `yaml:"jwks_file" env:"JWT_JWKS_FILE"`
+	Issuer   string `yaml:"issuer" env:"JWT_ISSUER"`
+	Audience string `yaml:"audience" env:"JWT_AUDIENCE"`
+}
+
+// RateLimit limits requests per client: the API key or bearer token
+// subject of the request, or its IP address for anonymous requests.
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED" env-default:"false"`
+	// Default applies to every route without its own limit.
+	Default Limit `yaml:"default"`
+	// Routes overrides Default per route, keyed by method and route
+	// pattern, e.g. "POST /good/create", or by the full gRPC method name,
+	// e.g. "/goods.v1.GoodsService/Create".
+	Routes map[string]Limit `yaml:"routes"`
+}
+
+// Limit allows Requests per Window with bursts of up to Requests.
+type Limit struct {
+	Requests int           `yaml:"requests" env-default:"100"`
+	Window   time.Duration `yaml:"window" env-default:"1m"`
+}
+
+// Idempotency makes mutating requests with an Idempotency-Key header safe
+// to retry.
+type Idempotency struct {
+	Enabled bool `yaml:"enabled" env:"IDEMPOTENCY_ENABLED" env-default:"true"`
+	// TTL is how long a response is kept for replays.
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h"`
+	// LockTimeout is how long a key stays locked by a request in progress.
+	// It should exceed the HTTP server timeout.
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"IDEMPOTENCY_LOCK_TIMEOUT" env-default:"1m"`
+}
+
+// Trace exporters supported by Tracing.Exporter.
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterOTLP   = "otlp"
+)
+
+type Tracing struct {
+	// Exporter is none, stdout or otlp (OTLP over HTTP).
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	// Endpoint is the host:port of the OTLP collector.
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4318"`
+	Insecure bool   `yaml:"insecure" env:"TRACING_INSECURE" env-default:"true"`
+	// SampleRatio is the share of new traces that are recorded. Requests
+	// with a sampled parent are always recorded.
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+}
+
+// OpenAPI validates requests and responses against the OpenAPI spec of
+// core. Invalid requests are rejected with 400, invalid responses are only
+// logged, since the client already relies on them.
+type OpenAPI struct {
+	ValidateRequests  bool `yaml:"validate_requests" env:"OPENAPI_VALIDATE_REQUESTS" env-default:"true"`
+	ValidateResponses bool `yaml:"validate_responses" env:"OPENAPI_VALIDATE_RESPONSES" env-default:"false"`
+}
+
+type Events struct {
+	// Heartbeat is how often an idle event stream gets a comment, so
+	// proxies do not close it.
+	Heartbeat time.Duration `yaml:"heartbeat" env:"EVENTS_HEARTBEAT" env-default:"15s"`
+	// AllowedOrigins are the pages, e.g. "https://admin.example.com", that
+	// may open the WebSocket besides the ones served from the API host.
+	AllowedOrigins []string `yaml:"allowed_origins" env:"EVENTS_ALLOWED_ORIGINS" env-separator:","`
+}
+
+type Compaction struct {
+	// Interval is how often projects with long or repeated ranks are
+	// compacted, 0 disables the job.
+	Interval time.Duration `yaml:"interval" env:"COMPACTION_INTERVAL" env-default:"1h"`
 }
 
 type HTTPServer struct {
 	Address     string        `yaml:"address" env:"ADDRESS" env-default:"localhost:8080"`
 	Timeout     time.Duration `yaml:"timeout" env:"TIMEOUT" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT" env-default:"60s"`
+	// ShutdownDelay is how long /readyz reports not ready before the server
+	// stops accepting requests, so load balancers have time to notice.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY" env-default:"0s"`
+	// MaxBodySize is the largest request body in bytes, larger ones are
+	// rejected with 413.
+	MaxBodySize int64 `yaml:"max_body_size" env:"MAX_BODY_SIZE" env-default:"65536"`
+}
+
+type GRPCServer struct {
+	// Address is empty to disable the gRPC server.
+	Address string `yaml:"address" env:"GRPC_ADDRESS" env-default:"localhost:9090"`
+	// Reflection lets tools like grpcurl discover the services.
+	Reflection bool `yaml:"reflection" env:"GRPC_REFLECTION" env-default:"true"`
 }
 
 func MustLoad() *Config {
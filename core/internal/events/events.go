@@ -0,0 +1,252 @@
+// Package events reads changes of goods back from the JetStream stream the
+// producer publishes to, so every core instance sees the changes made
+// through any of them.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/nats-io/nats.go"
+)
+
+// buffer is how many events a subscriber may lag behind before it is
+// dropped.
+const buffer = 64
+
+type Event struct {
+	// ID is the sequence of the message in the stream. IDs only grow and
+	// are the same on every core instance.
+	ID        uint64
+	Type      string
+	ProjectID int
+	// FromProjectID is the project a moved good left.
+	FromProjectID int
+	// Data is the GoodEvent as published.
+	Data []byte
+}
+
+// JetStream reads the stream through a single subscription, started by
+// the first subscriber, and hands every event to all subscribers.
+type JetStream struct {
+	log     *slog.Logger
+	js      nats.JetStreamContext
+	subject string
+
+	mu   sync.Mutex
+	live *nats.Subscription
+	subs map[chan Event]struct{}
+}
+
+func NewJetStream(log *slog.Logger, js nats.JetStreamContext, subject string) *JetStream {
+	return &JetStream{
+		log:     log.With(slog.String("component", "events")),
+		js:      js,
+		subject: subject,
+		subs:    make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe delivers the events published after the one with id after, or
+// only new ones when after is 0. Events older than the stream retains are
+// skipped, so the first event may not follow after. The channel is closed
+// when ctx is done, or when the subscriber falls more than buffer events
+// behind. It should then subscribe again after the last event it got.
+func (s *JetStream) Subscribe(ctx context.Context, after uint64) (<-chan Event, error) {
+	const op = "events.JetStream.Subscribe"
+
+	live, err := s.register(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if after == 0 {
+		return live, nil
+	}
+
+	// Every event up to last is replayed, the live ones after it were
+	// published once live was registered.
+	last, err := s.lastSeq(ctx)
+	if err != nil {
+		s.drop(live)
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if after >= last {
+		return live, nil
+	}
+
+	missed, err := s.js.SubscribeSync(s.subject, nats.OrderedConsumer(), nats.StartSequence(after+1))
+	if err != nil {
+		s.drop(live)
+
+		return nil, fmt.Errorf("%s: replay: %w", op, err)
+	}
+
+	ch := make(chan Event, buffer)
+
+	go s.replay(ctx, missed, last, live, ch)
+
+	return ch, nil
+}
+
+// register adds a subscriber of the live events until ctx is done,
+// subscribing to the stream on first use.
+func (s *JetStream) register(ctx context.Context) (chan Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.live == nil {
+		sub, err := s.js.Subscribe(s.subject, s.receive, nats.OrderedConsumer(), nats.DeliverNew())
+		if err != nil {
+			return nil, fmt.Errorf("subscribe: %w", err)
+		}
+
+		s.live = sub
+	}
+
+	ch := make(chan Event, buffer)
+	s.subs[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		s.drop(ch)
+	}()
+
+	return ch, nil
+}
+
+// drop closes the channel of a subscriber, unless broadcast already did.
+func (s *JetStream) drop(ch chan Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+func (s *JetStream) receive(msg *nats.Msg) {
+	ev, err := s.decode(msg)
+	if err != nil {
+		s.log.Warn("failed to decode event", sl.Err(err))
+
+		return
+	}
+
+	s.broadcast(ev)
+}
+
+// broadcast hands ev to every subscriber without waiting for any of them.
+// A subscriber that already has buffer events pending is dropped.
+func (s *JetStream) broadcast(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+			s.log.Info("subscriber fell behind, dropping it", slog.Uint64("seq", ev.ID))
+
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// replay sends the events of missed up to last to ch, then the live events
+// that follow them. ch is closed once live is.
+func (s *JetStream) replay(
+	ctx context.Context,
+	missed *nats.Subscription,
+	last uint64,
+	live <-chan Event,
+	ch chan<- Event,
+) {
+	defer close(ch)
+
+	var sent uint64
+
+	for sent < last {
+		msg, err := missed.NextMsgWithContext(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.log.Warn("failed to replay events", sl.Err(err))
+			}
+
+			break
+		}
+
+		ev, err := s.decode(msg)
+		if err != nil {
+			s.log.Warn("failed to decode event", sl.Err(err))
+
+			continue
+		}
+
+		sent = ev.ID
+
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+		}
+	}
+
+	if err := missed.Unsubscribe(); err != nil {
+		s.log.Warn("failed to unsubscribe", sl.Err(err))
+	}
+
+	for ev := range live {
+		if ev.ID <= sent {
+			continue
+		}
+
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (s *JetStream) lastSeq(ctx context.Context) (uint64, error) {
+	name, err := s.js.StreamNameBySubject(s.subject, nats.Context(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("find stream: %w", err)
+	}
+
+	info, err := s.js.StreamInfo(name, nats.Context(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("stream info: %w", err)
+	}
+
+	return info.State.LastSeq, nil
+}
+
+func (s *JetStream) decode(msg *nats.Msg) (Event, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return Event{}, fmt.Errorf("read metadata: %w", err)
+	}
+
+	var good models.GoodEvent
+	if err := json.Unmarshal(msg.Data, &good); err != nil {
+		return Event{}, fmt.Errorf("unmarshal event %d: %w", meta.Sequence.Stream, err)
+	}
+
+	return Event{
+		ID:            meta.Sequence.Stream,
+		Type:          good.Type,
+		ProjectID:     good.ProjectID,
+		FromProjectID: good.FromProjectID,
+		Data:          msg.Data,
+	}, nil
+}
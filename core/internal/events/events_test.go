@@ -0,0 +1,44 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcastDropsSlowSubscribers(t *testing.T) {
+	s := NewJetStream(slogdiscard.NewDiscardLogger(), nil, "goods")
+
+	slow, fast := make(chan Event, buffer), make(chan Event, buffer)
+	s.subs[slow] = struct{}{}
+	s.subs[fast] = struct{}{}
+
+	var got []uint64
+	for id := range uint64(buffer + 1) {
+		s.broadcast(Event{ID: id + 1})
+
+		got = append(got, (<-fast).ID)
+	}
+
+	require.Len(t, got, buffer+1)
+	require.Equal(t, uint64(buffer+1), got[buffer])
+
+	// The slow subscriber gets the events it had room for, then its
+	// channel is closed.
+	for id := range uint64(buffer) {
+		ev, ok := <-slow
+		require.True(t, ok)
+		require.Equal(t, id+1, ev.ID)
+	}
+
+	_, ok := <-slow
+	require.False(t, ok)
+	require.NotContains(t, s.subs, slow)
+
+	// Dropping it again when its context is done is a no-op.
+	s.drop(slow)
+	s.drop(fast)
+
+	require.Empty(t, s.subs)
+}
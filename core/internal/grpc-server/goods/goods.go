@@ -0,0 +1,372 @@
+// Package goods serves goods over gRPC. Reads go through the same cache
+// readers as the HTTP handlers and writes through the same goods.Service,
+// so clients of either transport see the same data.
+package goods
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+
+	goodsv1 "github.com/Gonnekone/hezzl-test/core/api/goods/v1"
+	"github.com/Gonnekone/hezzl-test/core/internal/grpc-server/grpcerr"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/create"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/get"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/remove"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/update"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	limitDefault  = 10
+	offsetDefault = 1
+)
+
+// Storage is everything the service reads from storage.Storage.
+type Storage interface {
+	get.GoodGetter
+	list.GoodLister
+}
+
+// Goods is implemented by goods.Service.
+type Goods interface {
+	create.GoodCreator
+	update.GoodUpdater
+	remove.GoodDeleter
+	reprioritize.GoodPriorityUpdater
+}
+
+type Server struct {
+	goodsv1.UnimplementedGoodsServiceServer
+
+	log     *slog.Logger
+	storage Storage
+	goods   Goods
+	lists   *list.Reader
+}
+
+func New(log *slog.Logger, storage Storage, goods Goods) *Server {
+	return &Server{
+		log:     log,
+		storage: storage,
+		goods:   goods,
+		lists:   list.NewReader(storage),
+	}
+}
+
+func (s *Server) Create(ctx context.Context, in *goodsv1.CreateRequest) (*goodsv1.Good, error) {
+	const op = "grpc.goods.Create"
+
+	log := s.logger(ctx, op)
+
+	projectID, err := authorize(ctx, in.GetProjectId(), auth.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	req := create.Request{Name: in.GetName()}
+	req.Sanitize()
+
+	if err := validate(req); err != nil {
+		log.Info("invalid request", sl.Err(err))
+
+		return nil, err
+	}
+
+	good, err := s.goods.Create(ctx, req.Name, projectID)
+	if err != nil {
+		log.Error("failed to save good", sl.Err(err))
+
+		return nil, grpcerr.FromError(err)
+	}
+
+	log.Info("good added", slog.Any("good", good))
+
+	return toProto(good), nil
+}
+
+func (s *Server) Get(ctx context.Context, in *goodsv1.GetRequest) (*goodsv1.Good, error) {
+	const op = "grpc.goods.Get"
+
+	log := s.logger(ctx, op)
+
+	projectID, err := authorize(ctx, in.GetProjectId(), auth.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(in.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	good, err := get.Read(ctx, log, s.storage, id, projectID)
+	if err != nil {
+		log.Error("failed to get good", sl.Err(err))
+
+		return nil, grpcerr.FromError(err)
+	}
+
+	return toProto(good), nil
+}
+
+// List reads the same cached pages as the HTTP list handler, but does not
+// serve stale ones.
+func (s *Server) List(ctx context.Context, in *goodsv1.ListRequest) (*goodsv1.ListResponse, error) {
+	const op = "grpc.goods.List"
+
+	log := s.logger(ctx, op)
+
+	var projectID string
+
+	if in.GetProjectId() == 0 {
+		principal, ok := auth.FromContext(ctx)
+		if !ok || !principal.Admin {
+			return nil, grpcerr.New(problem.CodeForbidden, "")
+		}
+	} else {
+		var err error
+
+		projectID, err = authorize(ctx, in.GetProjectId(), auth.ScopeRead)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if in.GetLimit() < 0 || in.GetOffset() < 0 || in.GetLimit() > list.LimitMax || in.GetOffset() > math.MaxInt32 {
+		return nil, grpcerr.New(problem.CodeInvalidRequest, "invalid limit or offset")
+	}
+
+	limit, offset := int(in.GetLimit()), int(in.GetOffset())
+	if limit == 0 {
+		limit = limitDefault
+	}
+	if in.Offset == nil {
+		offset = offsetDefault
+	}
+
+	page, err := s.lists.Read(ctx, log, projectID, limit, offset, false)
+	if err != nil {
+		log.Error("failed to list goods", sl.Err(err))
+
+		return nil, grpcerr.FromError(err)
+	}
+
+	var goods list.GoodListResponse
+	if err := json.Unmarshal(page.Data, &goods); err != nil {
+		log.Error("failed to unmarshal list", sl.Err(err))
+
+		return nil, grpcerr.FromError(err)
+	}
+
+	return toProtoList(&goods), nil
+}
+
+func (s *Server) Update(ctx context.Context, in *goodsv1.UpdateRequest) (*goodsv1.Good, error) {
+	const op = "grpc.goods.Update"
+
+	log := s.logger(ctx, op)
+
+	projectID, err := authorize(ctx, in.GetProjectId(), auth.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(in.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	req := update.Request{Name: in.GetName(), Desc: in.GetDescription()}
+	req.Sanitize()
+
+	if err := validate(req); err != nil {
+		log.Info("invalid request", sl.Err(err))
+
+		return nil, err
+	}
+
+	good, err := s.goods.Update(ctx, id, projectID, req.Name, req.Desc)
+	if err != nil {
+		log.Error("failed to update good", sl.Err(err))
+
+		return nil, grpcerr.FromError(err)
+	}
+
+	log.Info("good updated", slog.Any("good", good))
+
+	return toProto(good), nil
+}
+
+func (s *Server) Remove(ctx context.Context, in *goodsv1.RemoveRequest) (*goodsv1.RemoveResponse, error) {
+	const op = "grpc.goods.Remove"
+
+	log := s.logger(ctx, op)
+
+	projectID, err := authorize(ctx, in.GetProjectId(), auth.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(in.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	good, err := s.goods.Remove(ctx, id, projectID)
+	if err != nil {
+		log.Error("failed to delete good", sl.Err(err))
+
+		return nil, grpcerr.FromError(err)
+	}
+
+	log.Info("good deleted")
+
+	return &goodsv1.RemoveResponse{
+		Id:        int64(good.ID),
+		ProjectId: int64(good.ProjectID),
+		Removed:   good.Removed,
+	}, nil
+}
+
+func (s *Server) Reprioritize(
+	ctx context.Context,
+	in *goodsv1.ReprioritizeRequest,
+) (*goodsv1.ReprioritizeResponse, error) {
+	const op = "grpc.goods.Reprioritize"
+
+	log := s.logger(ctx, op)
+
+	projectID, err := authorize(ctx, in.GetProjectId(), auth.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(in.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if in.GetNewPriority() > math.MaxInt32 || in.GetNewPriority() < math.MinInt32 {
+		return nil, grpcerr.New(problem.CodeInvalidRequest, "new_priority is out of range")
+	}
+
+	req := reprioritize.Request{NewPriority: int(in.GetNewPriority())}
+	if err := validate(req); err != nil {
+		log.Info("invalid request", sl.Err(err))
+
+		return nil, err
+	}
+
+	goods, err := s.goods.Reprioritize(ctx, id, projectID, req.NewPriority)
+	if err != nil {
+		log.Error("failed to update priority", sl.Err(err))
+
+		return nil, grpcerr.FromError(err)
+	}
+
+	log.Info("priority updated")
+
+	resp := &goodsv1.ReprioritizeResponse{
+		Priorities: make([]*goodsv1.ReprioritizeResponse_Priority, 0, len(goods)),
+	}
+
+	for _, good := range goods {
+		resp.Priorities = append(resp.Priorities, &goodsv1.ReprioritizeResponse_Priority{
+			Id:       int64(good.ID),
+			Priority: int64(good.Priority),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *Server) logger(ctx context.Context, op string) *slog.Logger {
+	return s.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+		slog.String("actor", auth.Actor(ctx)),
+	)
+}
+
+// authorize checks that the caller holds the scope on the project and
+// returns the project id in the form the storage expects.
+func authorize(ctx context.Context, projectID int64, scope auth.Scope) (string, error) {
+	id, err := parseID(projectID)
+	if err != nil {
+		return "", err
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", grpcerr.New(problem.CodeUnauthorized, "")
+	}
+
+	if !principal.Can(int(projectID), scope) {
+		return "", grpcerr.New(problem.CodeForbidden, "")
+	}
+
+	return id, nil
+}
+
+func parseID(id int64) (string, error) {
+	if id <= 0 || id > math.MaxInt32 {
+		return "", grpcerr.New(problem.CodeInvalidRequest, "ids must be positive integers")
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+func validate(req any) error {
+	err := validator.New().Struct(req)
+	if err == nil {
+		return nil
+	}
+
+	var validateErr validator.ValidationErrors
+	errors.As(err, &validateErr)
+
+	return grpcerr.FromProblem(problem.Validation(validateErr))
+}
+
+func toProto(good *models.Good) *goodsv1.Good {
+	return &goodsv1.Good{
+		Id:          int64(good.ID),
+		ProjectId:   int64(good.ProjectID),
+		Name:        good.Name,
+		Description: good.Description,
+		Priority:    int64(good.Priority),
+		Removed:     good.Removed,
+		CreatedAt:   timestamppb.New(good.CreatedAt),
+		Version:     int64(good.Version),
+	}
+}
+
+func toProtoList(list *list.GoodListResponse) *goodsv1.ListResponse {
+	resp := &goodsv1.ListResponse{
+		Meta: &goodsv1.ListResponse_Meta{
+			Total:   int64(list.Meta.Total),
+			Removed: int64(list.Meta.Removed),
+			Limit:   int64(list.Meta.Limit),
+			Offset:  int64(list.Meta.Offset),
+		},
+		Goods: make([]*goodsv1.Good, 0, len(list.Goods)),
+	}
+
+	for i := range list.Goods {
+		resp.Goods = append(resp.Goods, toProto(&list.Goods[i]))
+	}
+
+	return resp
+}
@@ -0,0 +1,81 @@
+// Package grpcerr converts problems of the HTTP API to gRPC statuses, so
+// both transports report the same codes. The problem code is sent as the
+// reason of a google.rpc.ErrorInfo detail.
+package grpcerr
+
+import (
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Domain of the ErrorInfo details.
+const Domain = "hezzl"
+
+var grpcCodes = map[problem.Code]codes.Code{
+	problem.CodeInvalidRequest:        codes.InvalidArgument,
+	problem.CodeValidationFailed:      codes.InvalidArgument,
+	problem.CodeUnauthorized:          codes.Unauthenticated,
+	problem.CodeForbidden:             codes.PermissionDenied,
+	problem.CodeNotFound:              codes.NotFound,
+	problem.CodeProjectNotFound:       codes.NotFound,
+	problem.CodeConflict:              codes.AlreadyExists,
+	problem.CodeVersionConflict:       codes.Aborted,
+	problem.CodeRateLimited:           codes.ResourceExhausted,
+	problem.CodeIdempotencyInProgress: codes.Aborted,
+	problem.CodeIdempotencyKeyReused:  codes.FailedPrecondition,
+	problem.CodeInternal:              codes.Internal,
+}
+
+// FromProblem returns the status error of the problem. Its message is the
+// detail of the problem, or the title when there is none.
+func FromProblem(p *problem.Problem) error {
+	code, ok := grpcCodes[p.Code]
+	if !ok {
+		code = codes.Unknown
+	}
+
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Title
+	}
+
+	st, err := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason: string(p.Code),
+		Domain: Domain,
+	})
+	if err != nil {
+		return status.Error(code, msg)
+	}
+
+	return st.Err()
+}
+
+// FromError maps storage errors like problem.FromError does. Any other
+// error is reported as internal without its text.
+func FromError(err error) error {
+	return FromProblem(problem.FromError(err))
+}
+
+// New is a shorthand for FromProblem(problem.New(code, detail)).
+func New(code problem.Code, detail string) error {
+	return FromProblem(problem.New(code, detail))
+}
+
+// Reason returns the problem code carried by a status error, or "" when
+// it has none.
+func Reason(err error) problem.Code {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == Domain {
+			return problem.Code(info.GetReason())
+		}
+	}
+
+	return ""
+}
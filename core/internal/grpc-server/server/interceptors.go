@@ -0,0 +1,470 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	goodsv1 "github.com/Gonnekone/hezzl-test/core/api/goods/v1"
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/grpc-server/grpcerr"
+	mwIdempotency "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/idempotency"
+	mwRateLimit "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/ratelimit"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/ratelimit"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	MetadataRequestID          = "x-request-id"
+	MetadataAPIKey             = "x-api-key"
+	MetadataAuthorization      = "authorization"
+	MetadataIdempotencyKey     = "idempotency-key"
+	MetadataIdempotentReplayed = "idempotent-replayed"
+	MetadataRateLimitLimit     = "x-ratelimit-limit"
+	MetadataRateLimitRemaining = "x-ratelimit-remaining"
+	MetadataRateLimitReset     = "x-ratelimit-reset"
+	MetadataRetryAfter         = "retry-after"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// writeMethods change goods, only their calls are made idempotent.
+var writeMethods = map[string]bool{
+	goodsv1.GoodsService_Create_FullMethodName:       true,
+	goodsv1.GoodsService_Update_FullMethodName:       true,
+	goodsv1.GoodsService_Remove_FullMethodName:       true,
+	goodsv1.GoodsService_Reprioritize_FullMethodName: true,
+}
+
+// requestID takes the request id from the x-request-id metadata or
+// generates one, and stores it where middleware.GetReqID finds it.
+func requestID(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	id := firstMetadata(ctx, MetadataRequestID)
+	if id == "" {
+		id = fmt.Sprintf("grpc-%06d", middleware.NextRequestID())
+	}
+
+	//nolint: errcheck
+	grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, id))
+
+	return handler(context.WithValue(ctx, middleware.RequestIDKey, id), req)
+}
+
+func logging(log *slog.Logger) grpc.UnaryServerInterceptor {
+	log = log.With(
+		slog.String("component", "grpc-server/logger"),
+	)
+
+	log.Info("logger interceptor enabled")
+
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		t1 := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		log.Info("call completed",
+			slog.String("method", info.FullMethod),
+			slog.String("code", status.Code(err).String()),
+			slog.String("request_id", middleware.GetReqID(ctx)),
+			slog.String("duration", time.Since(t1).String()),
+		)
+
+		return resp, err
+	}
+}
+
+// recovery turns a panic in a handler into an internal error, like
+// middleware.Recoverer does for HTTP.
+func recovery(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (resp any, err error) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				log.Error("panic in grpc handler",
+					slog.String("method", info.FullMethod),
+					slog.Any("panic", rvr),
+				)
+
+				err = grpcerr.New(problem.CodeInternal, "")
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// authenticate stores the principal of the call in the context. Calls
+// without valid credentials are rejected with Unauthenticated, the
+// services check scopes themselves.
+func authenticate(log *slog.Logger, authenticator *auth.Authenticator, enabled bool) grpc.UnaryServerInterceptor {
+	log = log.With(
+		slog.String("component", "grpc-server/auth"),
+	)
+
+	if !enabled {
+		log.Warn("authentication is disabled, every call acts as admin")
+
+		return func(
+			ctx context.Context,
+			req any,
+			_ *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (any, error) {
+			return handler(auth.WithPrincipal(ctx, &auth.Principal{Actor: auth.ActorAnonymous, Admin: true}), req)
+		}
+	}
+
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		log := log.With(
+			slog.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		var (
+			principal *auth.Principal
+			err       error
+		)
+
+		if token, ok := auth.BearerToken(firstMetadata(ctx, MetadataAuthorization)); ok {
+			principal, err = authenticator.Token(token)
+			if err != nil {
+				log.Info("invalid bearer token", sl.Err(err))
+
+				return nil, grpcerr.New(problem.CodeUnauthorized, "")
+			}
+		} else {
+			principal, err = authenticator.Key(ctx, firstMetadata(ctx, MetadataAPIKey))
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				log.Info("api key is missing, unknown or revoked")
+
+				return nil, grpcerr.New(problem.CodeUnauthorized, "")
+			}
+
+			if err != nil {
+				log.Error("failed to find api key", sl.Err(err))
+
+				return nil, grpcerr.FromError(err)
+			}
+		}
+
+		log.Debug("call authenticated",
+			slog.String("method", info.FullMethod),
+			slog.String("actor", principal.Actor),
+		)
+
+		return handler(auth.WithPrincipal(ctx, principal), req)
+	}
+}
+
+// rateLimit limits calls per method and client like the HTTP rate limit
+// middleware. Limits are looked up in cfg.Routes by the full method name,
+// e.g. "/goods.v1.GoodsService/Create". It must run after authenticate.
+// When the limiter fails the call is let through.
+func rateLimit(log *slog.Logger, limiter mwRateLimit.Limiter, cfg config.RateLimit) grpc.UnaryServerInterceptor {
+	log = log.With(
+		slog.String("component", "grpc-server/ratelimit"),
+	)
+
+	log.Info("rate limit interceptor enabled")
+
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		limit, ok := cfg.Routes[info.FullMethod]
+		if !ok {
+			limit = cfg.Default
+		}
+
+		if limit.Requests <= 0 || limit.Window <= 0 {
+			return handler(ctx, req)
+		}
+
+		client := clientKey(ctx)
+
+		res, err := limiter.Allow(ctx, info.FullMethod+" "+client, limit)
+		if err != nil {
+			log.Warn("failed to check rate limit",
+				sl.Err(err),
+				slog.String("request_id", middleware.GetReqID(ctx)),
+			)
+
+			return handler(ctx, req)
+		}
+
+		md := metadata.Pairs(
+			MetadataRateLimitLimit, strconv.Itoa(res.Limit),
+			MetadataRateLimitRemaining, strconv.Itoa(res.Remaining),
+			MetadataRateLimitReset, strconv.Itoa(ratelimit.Seconds(res.Reset)),
+		)
+
+		if !res.Allowed {
+			log.Info("rate limit exceeded",
+				slog.String("request_id", middleware.GetReqID(ctx)),
+				slog.String("method", info.FullMethod),
+				slog.String("client", client),
+			)
+
+			md.Set(MetadataRetryAfter, strconv.Itoa(ratelimit.Seconds(res.RetryAfter)))
+			//nolint: errcheck
+			grpc.SetHeader(ctx, md)
+
+			return nil, grpcerr.New(problem.CodeRateLimited, "")
+		}
+
+		//nolint: errcheck
+		grpc.SetHeader(ctx, md)
+
+		return handler(ctx, req)
+	}
+}
+
+// idempotency replays the stored result of a write call retried with the
+// same idempotency-key metadata instead of running it again, like the HTTP
+// idempotency middleware, and shares its store. Keys are scoped to the
+// client, so it must run after authenticate. Results with server error
+// codes are not stored, so such calls may be retried. When the store fails
+// the call is let through.
+func idempotency(log *slog.Logger, store mwIdempotency.Store, cfg config.Idempotency) grpc.UnaryServerInterceptor {
+	log = log.With(
+		slog.String("component", "grpc-server/idempotency"),
+	)
+
+	log.Info("idempotency interceptor enabled")
+
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		idemKey := firstMetadata(ctx, MetadataIdempotencyKey)
+		if idemKey == "" || !writeMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		log := log.With(
+			slog.String("request_id", middleware.GetReqID(ctx)),
+			slog.String("idempotency_key", idemKey),
+		)
+
+		if len(idemKey) > maxIdempotencyKeyLength {
+			log.Info("idempotency key is too long")
+
+			return nil, grpcerr.New(problem.CodeInvalidRequest, "invalid idempotency key")
+		}
+
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		hash, err := requestHash(info.FullMethod, msg)
+		if err != nil {
+			log.Error("failed to hash request", sl.Err(err))
+
+			return nil, grpcerr.New(problem.CodeInternal, "")
+		}
+
+		key := auth.Actor(ctx) + ":" + idemKey
+
+		// Bookkeeping must outlive a client that hangs up mid-call.
+		storeCtx := context.WithoutCancel(ctx)
+
+		stored, err := store.Reserve(storeCtx, key, models.IdempotencyRecord{RequestHash: hash}, cfg.LockTimeout)
+		if err != nil {
+			log.Warn("failed to reserve idempotency key", sl.Err(err))
+
+			return handler(ctx, req)
+		}
+
+		if stored != nil {
+			return replay(ctx, log, stored, hash)
+		}
+
+		resp, callErr := handler(ctx, req)
+
+		rec, err := record(hash, resp, callErr)
+		if err == nil && rec != nil {
+			err = store.Complete(storeCtx, key, *rec, cfg.TTL)
+		}
+
+		if err != nil || rec == nil {
+			if err != nil {
+				log.Warn("failed to save idempotent result", sl.Err(err))
+			}
+
+			if err := store.Release(storeCtx, key); err != nil {
+				log.Warn("failed to release idempotency key", sl.Err(err))
+			}
+		}
+
+		return resp, callErr
+	}
+}
+
+// record turns the result of a call into the record to store, or nil when
+// the call failed with a server error. ContentType holds the full name of
+// the response message, Status the gRPC code and Body the marshaled
+// response or status.
+func record(hash string, resp any, callErr error) (*models.IdempotencyRecord, error) {
+	rec := &models.IdempotencyRecord{RequestHash: hash, Done: true}
+
+	if callErr != nil {
+		st := status.Convert(callErr)
+
+		switch st.Code() {
+		case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded, codes.Canceled:
+			return nil, nil
+		}
+
+		body, err := proto.Marshal(st.Proto())
+		if err != nil {
+			return nil, err
+		}
+
+		rec.Status = int(st.Code())
+		rec.Body = body
+
+		return rec, nil
+	}
+
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return nil, nil
+	}
+
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	rec.ContentType = string(proto.MessageName(msg))
+	rec.Body = body
+
+	return rec, nil
+}
+
+func replay(ctx context.Context, log *slog.Logger, stored *models.IdempotencyRecord, hash string) (any, error) {
+	if stored.RequestHash != hash {
+		log.Info("idempotency key reused with a different request")
+
+		return nil, grpcerr.New(problem.CodeIdempotencyKeyReused, "")
+	}
+
+	if !stored.Done {
+		log.Info("call with the same idempotency key is in progress")
+
+		return nil, grpcerr.New(problem.CodeIdempotencyInProgress, "")
+	}
+
+	log.Info("replaying stored result", slog.Int("code", stored.Status))
+
+	//nolint: errcheck
+	grpc.SetHeader(ctx, metadata.Pairs(MetadataIdempotentReplayed, strconv.FormatBool(true)))
+
+	if codes.Code(stored.Status) != codes.OK {
+		var st spb.Status
+		if err := proto.Unmarshal(stored.Body, &st); err != nil {
+			log.Error("failed to unmarshal stored status", sl.Err(err))
+
+			return nil, grpcerr.New(problem.CodeInternal, "")
+		}
+
+		return nil, status.ErrorProto(&st)
+	}
+
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(stored.ContentType))
+	if err != nil {
+		log.Error("unknown stored response type", sl.Err(err), slog.String("type", stored.ContentType))
+
+		return nil, grpcerr.New(problem.CodeInternal, "")
+	}
+
+	resp := mt.New().Interface()
+	if err := proto.Unmarshal(stored.Body, resp); err != nil {
+		log.Error("failed to unmarshal stored response", sl.Err(err))
+
+		return nil, grpcerr.New(problem.CodeInternal, "")
+	}
+
+	return resp, nil
+}
+
+// requestHash identifies the call a key was first used with: its method
+// and request message.
+func requestHash(method string, req proto.Message) (string, error) {
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	h.Write([]byte(method + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// clientKey is the actor of the call, or the address of the peer for
+// anonymous calls.
+func clientKey(ctx context.Context) string {
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+
+	return ratelimit.ClientKey(ctx, addr)
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
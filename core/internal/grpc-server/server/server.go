@@ -0,0 +1,68 @@
+package server
+
+import (
+	"log/slog"
+
+	goodsv1 "github.com/Gonnekone/hezzl-test/core/api/goods/v1"
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/grpc-server/goods"
+	mwIdempotency "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/idempotency"
+	mwRateLimit "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/ratelimit"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// Storage is everything the services need from storage.Storage.
+type Storage interface {
+	goods.Storage
+	auth.KeyFinder
+}
+
+// SharedState is the rate limit and idempotency state shared with the
+// HTTP API.
+type SharedState interface {
+	mwRateLimit.Limiter
+	mwIdempotency.Store
+}
+
+type Deps struct {
+	Storage     Storage
+	Goods       goods.Goods
+	SharedState SharedState
+	JWTVerifier *auth.JWTVerifier
+}
+
+// New returns the gRPC API of core. It authenticates calls the same way
+// the HTTP API does, by the x-api-key or the authorization metadata, and
+// applies the same rate limits and idempotency keys.
+func New(log *slog.Logger, cfg *config.Config, deps Deps) *grpc.Server {
+	authenticator := auth.NewAuthenticator(deps.Storage, deps.JWTVerifier, cfg.Auth.AdminKey)
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		requestID,
+		logging(log),
+		recovery(log),
+		authenticate(log, authenticator, cfg.Auth.Enabled),
+	}
+	if cfg.RateLimit.Enabled {
+		interceptors = append(interceptors, rateLimit(log, deps.SharedState, cfg.RateLimit))
+	}
+	if cfg.Idempotency.Enabled {
+		interceptors = append(interceptors, idempotency(log, deps.SharedState, cfg.Idempotency))
+	}
+
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+
+	goodsv1.RegisterGoodsServiceServer(srv, goods.New(log, deps.Storage, deps.Goods))
+
+	if cfg.GRPCServer.Reflection {
+		reflection.Register(srv)
+	}
+
+	return srv
+}
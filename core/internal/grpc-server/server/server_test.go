@@ -0,0 +1,532 @@
+package server_test
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	goodsv1 "github.com/Gonnekone/hezzl-test/core/api/goods/v1"
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/grpc-server/grpcerr"
+	"github.com/Gonnekone/hezzl-test/core/internal/grpc-server/server"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/redis"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	adminKey = "admin-key"
+	// readerKey may only read project 1.
+	readerKey = "reader-key"
+)
+
+// memStorage keeps goods in memory. Only project 1 exists.
+type memStorage struct {
+	goodsService.Storage
+
+	goods  map[int]*models.Good
+	nextID int
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{goods: make(map[int]*models.Good), nextID: 1}
+}
+
+func (s *memStorage) find(id, projectID string) (*models.Good, error) {
+	good, ok := s.goods[atoi(id)]
+	if !ok || good.Removed || strconv.Itoa(good.ProjectID) != projectID {
+		return nil, storageerr.ErrNotFound
+	}
+
+	return good, nil
+}
+
+func (s *memStorage) SaveGood(_ context.Context, name, projectID string) (*models.Good, error) {
+	if projectID != "1" {
+		return nil, storageerr.ErrProjectNotFound
+	}
+
+	good := &models.Good{
+		ID:        s.nextID,
+		ProjectID: 1,
+		Name:      name,
+		Priority:  s.nextID,
+		CreatedAt: time.Date(2025, 6, 16, 19, 0, 41, 0, time.UTC),
+	}
+	s.goods[good.ID] = good
+	s.nextID++
+
+	return good, nil
+}
+
+func (s *memStorage) UpdateGood(_ context.Context, id, projectID, name, desc string) (*models.Good, error) {
+	good, err := s.find(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.Name, good.Description = name, desc
+
+	return good, nil
+}
+
+func (s *memStorage) DeleteGood(_ context.Context, id, projectID string) (*models.Good, error) {
+	good, err := s.find(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.Removed = true
+
+	return good, nil
+}
+
+func (s *memStorage) UpdateGoodsPriority(_ context.Context, id, projectID string, priority int) ([]models.Good, error) {
+	good, err := s.find(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.Priority = priority
+
+	return []models.Good{*good}, nil
+}
+
+func (s *memStorage) GetGood(_ context.Context, id, projectID string) (*models.Good, error) {
+	return s.find(id, projectID)
+}
+
+func (s *memStorage) ListGoods(_ context.Context, projectID string, limit, offset int) (*list.GoodListResponse, error) {
+	resp := &list.GoodListResponse{Goods: []models.Good{}}
+	resp.Meta.Limit, resp.Meta.Offset = limit, offset
+
+	for _, good := range s.goods {
+		if projectID != "" && strconv.Itoa(good.ProjectID) != projectID {
+			continue
+		}
+
+		resp.Meta.Total++
+		if good.Removed {
+			resp.Meta.Removed++
+		}
+
+		resp.Goods = append(resp.Goods, *good)
+	}
+
+	sort.Slice(resp.Goods, func(i, j int) bool { return resp.Goods[i].ID < resp.Goods[j].ID })
+
+	return resp, nil
+}
+
+func (*memStorage) GetAPIKeyByHash(_ context.Context, hash string) (*models.APIKey, error) {
+	if hash != auth.HashKey(readerKey) {
+		return nil, storageerr.ErrNotFound
+	}
+
+	return &models.APIKey{ID: 7, Projects: []models.ProjectScope{{ProjectID: 1, Scope: "read"}}}, nil
+}
+
+func (*memStorage) InvalidList(context.Context, string) error                 { return nil }
+func (*memStorage) InvalidGoods(context.Context, ...models.Good) error        { return nil }
+func (*memStorage) SaveGoodInCache(context.Context, int64, models.Good) error { return nil }
+func (*memStorage) SaveListInCache(context.Context, string, int64, list.Page) error {
+	return nil
+}
+
+func (*memStorage) GetCachedGood(context.Context, string, string) (*models.CachedGood, error) {
+	return nil, nil
+}
+
+func (*memStorage) GetCachedList(context.Context, string, int, int) (*list.CachedList, error) {
+	return nil, nil
+}
+
+type fakeProducer struct {
+	sent int
+}
+
+func (*fakeProducer) Send(context.Context, []byte) error { return nil }
+func (p *fakeProducer) SendAsync(context.Context, []byte) error {
+	p.sent++
+
+	return nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+
+	return n
+}
+
+func newClient(t *testing.T, cfg *config.Config, producer *fakeProducer) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+
+	mr := miniredis.RunT(t)
+
+	shared := redis.New(config.RedisStorage{Addr: mr.Addr()})
+	t.Cleanup(func() { shared.Close() }) //nolint: errcheck
+
+	log := slogdiscard.NewDiscardLogger()
+	storage := newMemStorage()
+
+	srv := server.New(log, cfg, server.Deps{
+		Storage:     storage,
+		Goods:       goodsService.New(log, storage, producer),
+		SharedState: shared,
+	})
+
+	go srv.Serve(lis) //nolint: errcheck
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func newConfig() *config.Config {
+	return &config.Config{
+		Auth:       config.Auth{Enabled: true, AdminKey: adminKey},
+		GRPCServer: config.GRPCServer{Reflection: true},
+	}
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), server.MetadataAPIKey, key)
+}
+
+func requireProblem(t *testing.T, err error, code codes.Code, reason problem.Code) {
+	t.Helper()
+
+	require.Error(t, err)
+	require.Equal(t, code, status.Code(err), err.Error())
+	require.Equal(t, reason, grpcerr.Reason(err))
+}
+
+func TestGoodsLifecycle(t *testing.T) {
+	producer := &fakeProducer{}
+	client := goodsv1.NewGoodsServiceClient(newClient(t, newConfig(), producer))
+	ctx := withKey(adminKey)
+
+	created, err := client.Create(ctx, &goodsv1.CreateRequest{ProjectId: 1, Name: "  first  "})
+	require.NoError(t, err)
+	require.Equal(t, "first", created.GetName())
+	require.Equal(t, int64(1), created.GetProjectId())
+	require.NotNil(t, created.GetCreatedAt())
+
+	got, err := client.Get(ctx, &goodsv1.GetRequest{ProjectId: 1, Id: created.GetId()})
+	require.NoError(t, err)
+	require.Equal(t, created.GetId(), got.GetId())
+
+	updated, err := client.Update(ctx, &goodsv1.UpdateRequest{
+		ProjectId:   1,
+		Id:          created.GetId(),
+		Name:        "renamed",
+		Description: "about",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "renamed", updated.GetName())
+	require.Equal(t, "about", updated.GetDescription())
+
+	prio, err := client.Reprioritize(ctx, &goodsv1.ReprioritizeRequest{
+		ProjectId:   1,
+		Id:          created.GetId(),
+		NewPriority: 5,
+	})
+	require.NoError(t, err)
+	require.Len(t, prio.GetPriorities(), 1)
+	require.Equal(t, int64(5), prio.GetPriorities()[0].GetPriority())
+
+	listed, err := client.List(ctx, &goodsv1.ListRequest{ProjectId: 1})
+	require.NoError(t, err)
+	require.Len(t, listed.GetGoods(), 1)
+	require.Equal(t, int64(10), listed.GetMeta().GetLimit())
+	require.Equal(t, int64(1), listed.GetMeta().GetOffset())
+
+	removed, err := client.Remove(ctx, &goodsv1.RemoveRequest{ProjectId: 1, Id: created.GetId()})
+	require.NoError(t, err)
+	require.True(t, removed.GetRemoved())
+
+	_, err = client.Get(ctx, &goodsv1.GetRequest{ProjectId: 1, Id: created.GetId()})
+	requireProblem(t, err, codes.NotFound, problem.CodeNotFound)
+
+	require.Equal(t, 4, producer.sent)
+}
+
+// TestListOffset fails when an explicit offset 0 is replaced by the
+// default.
+func TestListOffset(t *testing.T) {
+	client := goodsv1.NewGoodsServiceClient(newClient(t, newConfig(), &fakeProducer{}))
+	ctx := withKey(adminKey)
+
+	cases := []struct {
+		name       string
+		offset     *int64
+		wantOffset int64
+	}{
+		{name: "Unset", wantOffset: 1},
+		{name: "Zero", offset: proto.Int64(0), wantOffset: 0},
+		{name: "Set", offset: proto.Int64(5), wantOffset: 5},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			listed, err := client.List(ctx, &goodsv1.ListRequest{ProjectId: 1, Offset: tc.offset})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOffset, listed.GetMeta().GetOffset())
+		})
+	}
+}
+
+func TestGoodsErrors(t *testing.T) {
+	client := goodsv1.NewGoodsServiceClient(newClient(t, newConfig(), &fakeProducer{}))
+	admin := withKey(adminKey)
+	reader := withKey(readerKey)
+
+	cases := []struct {
+		name   string
+		call   func() error
+		code   codes.Code
+		reason problem.Code
+	}{
+		{
+			name: "no credentials",
+			call: func() error {
+				_, err := client.Get(context.Background(), &goodsv1.GetRequest{ProjectId: 1, Id: 1})
+				return err
+			},
+			code:   codes.Unauthenticated,
+			reason: problem.CodeUnauthorized,
+		},
+		{
+			name: "unknown key",
+			call: func() error {
+				_, err := client.Get(withKey("nope"), &goodsv1.GetRequest{ProjectId: 1, Id: 1})
+				return err
+			},
+			code:   codes.Unauthenticated,
+			reason: problem.CodeUnauthorized,
+		},
+		{
+			name: "invalid bearer token",
+			call: func() error {
+				ctx := metadata.AppendToOutgoingContext(context.Background(), server.MetadataAuthorization, "Bearer x")
+				_, err := client.Get(ctx, &goodsv1.GetRequest{ProjectId: 1, Id: 1})
+				return err
+			},
+			code:   codes.Unauthenticated,
+			reason: problem.CodeUnauthorized,
+		},
+		{
+			name: "read scope writes",
+			call: func() error {
+				_, err := client.Create(reader, &goodsv1.CreateRequest{ProjectId: 1, Name: "x"})
+				return err
+			},
+			code:   codes.PermissionDenied,
+			reason: problem.CodeForbidden,
+		},
+		{
+			name: "other project",
+			call: func() error {
+				_, err := client.Get(reader, &goodsv1.GetRequest{ProjectId: 2, Id: 1})
+				return err
+			},
+			code:   codes.PermissionDenied,
+			reason: problem.CodeForbidden,
+		},
+		{
+			name: "list every project without admin",
+			call: func() error {
+				_, err := client.List(reader, &goodsv1.ListRequest{})
+				return err
+			},
+			code:   codes.PermissionDenied,
+			reason: problem.CodeForbidden,
+		},
+		{
+			name: "missing good",
+			call: func() error {
+				_, err := client.Get(reader, &goodsv1.GetRequest{ProjectId: 1, Id: 404})
+				return err
+			},
+			code:   codes.NotFound,
+			reason: problem.CodeNotFound,
+		},
+		{
+			name: "unknown project",
+			call: func() error {
+				_, err := client.Create(admin, &goodsv1.CreateRequest{ProjectId: 2, Name: "x"})
+				return err
+			},
+			code:   codes.NotFound,
+			reason: problem.CodeProjectNotFound,
+		},
+		{
+			name: "zero id",
+			call: func() error {
+				_, err := client.Remove(admin, &goodsv1.RemoveRequest{ProjectId: 1})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: problem.CodeInvalidRequest,
+		},
+		{
+			name: "empty name",
+			call: func() error {
+				_, err := client.Create(admin, &goodsv1.CreateRequest{ProjectId: 1, Name: " \x00 "})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: problem.CodeValidationFailed,
+		},
+		{
+			name: "zero priority",
+			call: func() error {
+				_, err := client.Reprioritize(admin, &goodsv1.ReprioritizeRequest{ProjectId: 1, Id: 1})
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: problem.CodeValidationFailed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			requireProblem(t, tc.call(), tc.code, tc.reason)
+		})
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	cfg := newConfig()
+	cfg.Auth.Enabled = false
+
+	client := goodsv1.NewGoodsServiceClient(newClient(t, cfg, &fakeProducer{}))
+
+	_, err := client.List(context.Background(), &goodsv1.ListRequest{})
+	require.NoError(t, err)
+}
+
+func TestReflection(t *testing.T) {
+	client := reflectionpb.NewServerReflectionClient(newClient(t, newConfig(), &fakeProducer{}))
+
+	stream, err := client.ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	var names []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		names = append(names, s.GetName())
+	}
+
+	require.Contains(t, names, goodsv1.GoodsService_ServiceDesc.ServiceName)
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := newConfig()
+	cfg.RateLimit = config.RateLimit{
+		Enabled: true,
+		Default: config.Limit{Requests: 100, Window: time.Minute},
+		Routes: map[string]config.Limit{
+			goodsv1.GoodsService_Get_FullMethodName: {Requests: 2, Window: time.Minute},
+		},
+	}
+
+	client := goodsv1.NewGoodsServiceClient(newClient(t, cfg, &fakeProducer{}))
+	ctx := withKey(adminKey)
+
+	for i := range 2 {
+		var header metadata.MD
+
+		_, err := client.Get(ctx, &goodsv1.GetRequest{ProjectId: 1, Id: 1}, grpc.Header(&header))
+		requireProblem(t, err, codes.NotFound, problem.CodeNotFound)
+		require.Equal(t, []string{"2"}, header.Get(server.MetadataRateLimitLimit))
+		require.Equal(t, []string{[]string{"1", "0"}[i]}, header.Get(server.MetadataRateLimitRemaining))
+	}
+
+	var header metadata.MD
+
+	_, err := client.Get(ctx, &goodsv1.GetRequest{ProjectId: 1, Id: 1}, grpc.Header(&header))
+	requireProblem(t, err, codes.ResourceExhausted, problem.CodeRateLimited)
+	require.Equal(t, []string{"30"}, header.Get(server.MetadataRetryAfter))
+
+	_, err = client.List(ctx, &goodsv1.ListRequest{ProjectId: 1})
+	require.NoError(t, err, "other methods keep their own limit")
+
+	_, err = client.Get(withKey(readerKey), &goodsv1.GetRequest{ProjectId: 1, Id: 1})
+	requireProblem(t, err, codes.NotFound, problem.CodeNotFound)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	cfg := newConfig()
+	cfg.Idempotency = config.Idempotency{Enabled: true, TTL: time.Hour, LockTimeout: time.Minute}
+
+	producer := &fakeProducer{}
+	client := goodsv1.NewGoodsServiceClient(newClient(t, cfg, producer))
+
+	withIdempotencyKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(withKey(adminKey), server.MetadataIdempotencyKey, key)
+	}
+
+	created, err := client.Create(withIdempotencyKey("a"), &goodsv1.CreateRequest{ProjectId: 1, Name: "first"})
+	require.NoError(t, err)
+
+	var header metadata.MD
+
+	replayed, err := client.Create(
+		withIdempotencyKey("a"),
+		&goodsv1.CreateRequest{ProjectId: 1, Name: "first"},
+		grpc.Header(&header),
+	)
+	require.NoError(t, err)
+	require.Equal(t, created.GetId(), replayed.GetId())
+	require.Equal(t, []string{"true"}, header.Get(server.MetadataIdempotentReplayed))
+	require.Equal(t, 1, producer.sent, "a replayed call is not run again")
+
+	_, err = client.Create(withIdempotencyKey("a"), &goodsv1.CreateRequest{ProjectId: 1, Name: "second"})
+	requireProblem(t, err, codes.FailedPrecondition, problem.CodeIdempotencyKeyReused)
+
+	// Client errors are replayed too.
+	for range 2 {
+		_, err = client.Remove(withIdempotencyKey("b"), &goodsv1.RemoveRequest{ProjectId: 1, Id: 404})
+		requireProblem(t, err, codes.NotFound, problem.CodeNotFound)
+	}
+
+	// Reads ignore the key.
+	_, err = client.Get(withIdempotencyKey("a"), &goodsv1.GetRequest{ProjectId: 1, Id: created.GetId()})
+	require.NoError(t, err)
+}
@@ -0,0 +1,107 @@
+package issue
+
+import (
+	"context"
+	"errors"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+)
+
+//go:generate go run github.com/vektra/mockery/v3@v3.4.0 --name=KeySaver
+type KeySaver interface {
+	SaveAPIKey(
+		ctx context.Context,
+		name string,
+		keyHash string,
+		projects []models.ProjectScope,
+	) (*models.APIKey, error)
+}
+
+type ProjectScope struct {
+	ProjectID int    `json:"projectId" validate:"required,gt=0"`
+	Scope     string `json:"scope" validate:"required,oneof=read write"`
+}
+
+type Request struct {
+	Name     string         `json:"name" validate:"required,max=255"`
+	Projects []ProjectScope `json:"projects" validate:"required,min=1,dive"`
+}
+
+func (r *Request) Sanitize() {
+	r.Name = request.Text(r.Name)
+}
+
+// Response carries the plain key. It is never stored and cannot be
+// shown again.
+type Response struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+func New(log *slog.Logger, keySaver KeySaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.issue.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := request.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Decode(err))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Validation(validateErr))
+
+			return
+		}
+
+		key, err := auth.GenerateKey()
+		if err != nil {
+			log.Error("failed to generate api key", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		projects := make([]models.ProjectScope, 0, len(req.Projects))
+		for _, p := range req.Projects {
+			projects = append(projects, models.ProjectScope{ProjectID: p.ProjectID, Scope: p.Scope})
+		}
+
+		apiKey, err := keySaver.SaveAPIKey(r.Context(), req.Name, auth.HashKey(key), projects)
+		if err != nil {
+			log.Error("failed to save api key", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("api key issued", slog.Int("api_key_id", apiKey.ID))
+
+		render.JSON(w, r, Response{APIKey: apiKey, Key: key})
+	}
+}
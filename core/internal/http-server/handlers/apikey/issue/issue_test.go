@@ -0,0 +1,92 @@
+package issue_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/apikey/issue"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStorage struct {
+	saved    bool
+	name     string
+	keyHash  string
+	projects []models.ProjectScope
+}
+
+func (s *fakeStorage) SaveAPIKey(
+	_ context.Context,
+	name string,
+	keyHash string,
+	projects []models.ProjectScope,
+) (*models.APIKey, error) {
+	s.saved = true
+	s.name = name
+	s.keyHash = keyHash
+	s.projects = projects
+
+	return &models.APIKey{ID: 3, Name: name, Projects: projects}, nil
+}
+
+func serve(storage *fakeStorage, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/keys", strings.NewReader(body))
+
+	rr := httptest.NewRecorder()
+	issue.New(slogdiscard.NewDiscardLogger(), storage).ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestIssueStoresOnlyTheHash(t *testing.T) {
+	storage := &fakeStorage{}
+
+	rr := serve(storage, `{"name":"dashboard","projects":[{"projectId":1,"scope":"read"},{"projectId":2,"scope":"write"}]}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var resp struct {
+		ID  int    `json:"id"`
+		Key string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+	require.Equal(t, 3, resp.ID)
+	require.NotEmpty(t, resp.Key)
+	require.Equal(t, auth.HashKey(resp.Key), storage.keyHash)
+	require.NotContains(t, storage.keyHash, resp.Key)
+
+	require.Equal(t, "dashboard", storage.name)
+	require.Equal(t, []models.ProjectScope{
+		{ProjectID: 1, Scope: "read"},
+		{ProjectID: 2, Scope: "write"},
+	}, storage.projects)
+}
+
+func TestIssueRejectsInvalidRequests(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+	}{
+		{name: "No name", body: `{"projects":[{"projectId":1,"scope":"read"}]}`},
+		{name: "No projects", body: `{"name":"dashboard","projects":[]}`},
+		{name: "Unknown scope", body: `{"name":"dashboard","projects":[{"projectId":1,"scope":"admin"}]}`},
+		{name: "Invalid project", body: `{"name":"dashboard","projects":[{"projectId":0,"scope":"read"}]}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := &fakeStorage{}
+
+			rr := serve(storage, tc.body)
+			require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+			require.False(t, storage.saved)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package revoke
+
+import (
+	"context"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+//go:generate go run github.com/vektra/mockery/v3@v3.4.0 --name=KeyRevoker
+type KeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error)
+}
+
+type Response struct {
+	ID      int  `json:"id"`
+	Revoked bool `json:"revoked"`
+}
+
+func New(log *slog.Logger, keyRevoker KeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.revoke.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id := r.URL.Query().Get("id")
+		if _, err := request.ParseID(id); err != nil {
+			log.Info("id is invalid", slog.String("id", id))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+			return
+		}
+
+		key, err := keyRevoker.RevokeAPIKey(r.Context(), id)
+		if err != nil {
+			log.Error("failed to revoke api key", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("api key revoked", slog.Int("api_key_id", key.ID))
+
+		render.JSON(w, r, Response{ID: key.ID, Revoked: true})
+	}
+}
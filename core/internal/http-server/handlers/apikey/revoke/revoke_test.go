@@ -0,0 +1,65 @@
+package revoke_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/apikey/revoke"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage knows only the key with id 3.
+type fakeStorage struct {
+	revoked []string
+}
+
+func (s *fakeStorage) RevokeAPIKey(_ context.Context, id string) (*models.APIKey, error) {
+	if id != "3" {
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrNotFound)
+	}
+
+	s.revoked = append(s.revoked, id)
+
+	n, _ := strconv.Atoi(id)
+
+	return &models.APIKey{ID: n}, nil
+}
+
+func TestRevoke(t *testing.T) {
+	cases := []struct {
+		name        string
+		id          string
+		wantStatus  int
+		wantBody    string
+		wantRevoked []string
+	}{
+		{name: "Known key", id: "3", wantStatus: http.StatusOK, wantBody: `{"id":3,"revoked":true}`, wantRevoked: []string{"3"}},
+		{name: "Unknown key", id: "4", wantStatus: http.StatusNotFound},
+		{name: "Invalid id", id: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := &fakeStorage{}
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/keys?id="+tc.id, nil)
+
+			rr := httptest.NewRecorder()
+			revoke.New(slogdiscard.NewDiscardLogger(), storage).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code, rr.Body.String())
+			require.Equal(t, tc.wantRevoked, storage.revoked)
+
+			if tc.wantBody != "" {
+				require.JSONEq(t, tc.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package bulkreorder
+
+import (
+	"context"
+	"errors"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+)
+
+// GoodsReorderer is implemented by goods.Service.
+//
+//go:generate go run github.com/vektra/mockery/v3@v3.4.0 --name=GoodsReorderer
+type GoodsReorderer interface {
+	Reorder(ctx context.Context, projectID string, ids []int) ([]models.Good, error)
+}
+
+// Request is the new order of the goods of the project, every good that
+// is not removed must be listed once.
+type Request struct {
+	IDs []int `json:"ids" validate:"required,min=1,max=1000,unique,dive,min=1"`
+}
+
+// New handles PATCH /goods/reorder. It answers with the priority of every
+// listed good and publishes an event for each good whose priority changed.
+func New(
+	log *slog.Logger,
+	goodsReorderer GoodsReorderer,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.bulkreorder.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		var req Request
+
+		err := request.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Decode(err))
+
+			return
+		}
+
+		projectID := r.URL.Query().Get("projectId")
+		if _, err := request.ParseID(projectID); err != nil {
+			log.Info("projectId is invalid", slog.String("project_id", projectID))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+
+			problem.Render(w, r, problem.Validation(validateErr))
+
+			return
+		}
+
+		goods, err := goodsReorderer.Reorder(r.Context(), projectID, req.IDs)
+		if err != nil {
+			log.Error("failed to reorder goods", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("goods reordered successfully", slog.Int("listed", len(req.IDs)), slog.Int("changed", len(goods)))
+
+		render.JSON(w, r, reprioritize.Response{Priorities: Priorities(req.IDs)})
+	}
+}
+
+// Priorities is the priority of each of ids after reordering.
+func Priorities(ids []int) []reprioritize.GoodPriorityView {
+	priorities := make([]reprioritize.GoodPriorityView, 0, len(ids))
+	for i, id := range ids {
+		priorities = append(priorities, reprioritize.GoodPriorityView{ID: id, Priority: i + 1})
+	}
+
+	return priorities
+}
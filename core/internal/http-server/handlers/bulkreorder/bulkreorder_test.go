@@ -0,0 +1,155 @@
+package bulkreorder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/bulkreorder"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	producer_mocks "github.com/Gonnekone/hezzl-test/core/internal/producer/mocks"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/service/goods/mocks"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReorderPublishesChangedGoods(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	storageMock := mocks.NewMockStorage(ctrl)
+	producerMock := producer_mocks.NewMockProducerInterface(ctrl)
+
+	// Good 2 already comes second, only goods 1 and 3 change places.
+	changed := []models.Good{
+		{ID: 3, ProjectID: 1, Priority: 1, Version: 2},
+		{ID: 1, ProjectID: 1, Priority: 3, Version: 2},
+	}
+
+	storageMock.EXPECT().ReorderGoods(gomock.Any(), "1", []int{3, 2, 1}).Return(changed, nil)
+	storageMock.EXPECT().InvalidGoods(gomock.Any(), changed).Return(nil)
+	storageMock.EXPECT().InvalidList(gomock.Any(), "1").Return(nil)
+
+	var published []int
+	producerMock.EXPECT().SendAsync(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, data []byte) error {
+		var event models.GoodEvent
+		require.NoError(t, json.Unmarshal(data, &event))
+		require.Equal(t, models.EventReprioritized, event.Type)
+
+		published = append(published, event.ID)
+
+		return nil
+	}).Times(len(changed))
+
+	log := slogdiscard.NewDiscardLogger()
+	handler := bulkreorder.New(log, goodsService.New(log, storageMock, producerMock))
+
+	req := httptest.NewRequest(http.MethodPatch, "/goods/reorder?projectId=1", strings.NewReader(`{"ids":[3,2,1]}`))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.JSONEq(t, `{"priorities":[
+{"id":3,"priority":1},{"id":2,"priority":2},{"id":1,"priority":3}
+]}`, rr.Body.String())
+
+	require.Equal(t, []int{3, 1}, published)
+}
+
+func TestReorderErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		query   string
+		reqBody string
+		// ReorderGoods is only expected when reorderErr is set.
+		reorderErr error
+
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Incomplete order",
+			query:      "?projectId=1",
+			reqBody:    `{"ids":[2,1]}`,
+			reorderErr: fmt.Errorf("reorder goods: %w", storageerr.ErrIncompleteOrder),
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{
+"type":"urn:hezzl:problem:validation_failed","title":"Validation failed","status":400,
+"detail":"ids must list every good of the project that is not removed",
+"instance":"/goods/reorder","code":"validation_failed"
+}`,
+		},
+		{
+			name:       "Unknown project",
+			query:      "?projectId=1",
+			reqBody:    `{"ids":[2,1]}`,
+			reorderErr: fmt.Errorf("reorder goods: %w", storageerr.ErrProjectNotFound),
+			wantStatus: http.StatusNotFound,
+			wantBody: `{
+"type":"urn:hezzl:problem:project_not_found","title":"Project not found","status":404,
+"instance":"/goods/reorder","code":"project_not_found"
+}`,
+		},
+		{
+			name:       "Duplicate ids",
+			query:      "?projectId=1",
+			reqBody:    `{"ids":[2,2]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{
+"type":"urn:hezzl:problem:validation_failed","title":"Validation failed","status":400,
+"detail":"field IDs is not valid","instance":"/goods/reorder","code":"validation_failed"
+}`,
+		},
+		{
+			name:       "Empty ids",
+			query:      "?projectId=1",
+			reqBody:    `{"ids":[]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{
+"type":"urn:hezzl:problem:validation_failed","title":"Validation failed","status":400,
+"detail":"field IDs is not valid","instance":"/goods/reorder","code":"validation_failed"
+}`,
+		},
+		{
+			name:       "Missing projectId",
+			reqBody:    `{"ids":[2,1]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{
+"type":"urn:hezzl:problem:invalid_request","title":"Invalid request","status":400,
+"detail":"invalid url params","instance":"/goods/reorder","code":"invalid_request"
+}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+
+			storageMock := mocks.NewMockStorage(ctrl)
+			producerMock := producer_mocks.NewMockProducerInterface(ctrl)
+
+			if tc.reorderErr != nil {
+				storageMock.EXPECT().ReorderGoods(gomock.Any(), "1", []int{2, 1}).Return(nil, tc.reorderErr)
+			}
+
+			log := slogdiscard.NewDiscardLogger()
+			handler := bulkreorder.New(log, goodsService.New(log, storageMock, producerMock))
+
+			req := httptest.NewRequest(http.MethodPatch, "/goods/reorder"+tc.query, strings.NewReader(tc.reqBody))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code, rr.Body.String())
+			require.JSONEq(t, tc.wantBody, rr.Body.String())
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package compact
+
+import (
+	"context"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+// Compactor is implemented by goods.Service.
+type Compactor interface {
+	Compact(ctx context.Context, projectID string) ([]models.Good, error)
+}
+
+// New handles POST /admin/compact. It answers with the goods whose
+// priority changed, which compaction never does, so the list is empty.
+func New(log *slog.Logger, compactor Compactor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.compact.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		projectID := r.URL.Query().Get("projectId")
+		if _, err := request.ParseID(projectID); err != nil {
+			log.Info("projectId is invalid", slog.String("project_id", projectID))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+			return
+		}
+
+		goods, err := compactor.Compact(r.Context(), projectID)
+		if err != nil {
+			log.Error("failed to compact project", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("project compacted", slog.String("project_id", projectID), slog.Int("changed", len(goods)))
+
+		priorities := make([]reprioritize.GoodPriorityView, 0, len(goods))
+		for _, good := range goods {
+			priorities = append(priorities, reprioritize.GoodPriorityView{ID: good.ID, Priority: good.Priority})
+		}
+
+		render.JSON(w, r, reprioritize.Response{Priorities: priorities})
+	}
+}
@@ -0,0 +1,88 @@
+package compact_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/compact"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	producer_mocks "github.com/Gonnekone/hezzl-test/core/internal/producer/mocks"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/service/goods/mocks"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCompactHandler(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		// CompactGoods is only expected when compacted is set.
+		compacted  bool
+		compactErr error
+
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			// Compaction keeps the order, so no good is listed or
+			// published, only the cached lists of the project are dropped.
+			name:       "Success",
+			query:      "?projectId=1",
+			compacted:  true,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"priorities":[]}`,
+		},
+		{
+			name:       "Unknown project",
+			query:      "?projectId=1",
+			compacted:  true,
+			compactErr: fmt.Errorf("compact goods: %w", storageerr.ErrProjectNotFound),
+			wantStatus: http.StatusNotFound,
+			wantBody: `{
+"type":"urn:hezzl:problem:project_not_found","title":"Project not found","status":404,
+"instance":"/admin/compact","code":"project_not_found"
+}`,
+		},
+		{
+			name:       "Invalid projectId",
+			query:      "?projectId=-1",
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{
+"type":"urn:hezzl:problem:invalid_request","title":"Invalid request","status":400,
+"detail":"invalid url params","instance":"/admin/compact","code":"invalid_request"
+}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+
+			storageMock := mocks.NewMockStorage(ctrl)
+			producerMock := producer_mocks.NewMockProducerInterface(ctrl)
+
+			if tc.compacted {
+				storageMock.EXPECT().CompactGoods(gomock.Any(), "1").Return(nil, tc.compactErr)
+			}
+
+			if tc.compacted && tc.compactErr == nil {
+				storageMock.EXPECT().InvalidList(gomock.Any(), "1").Return(nil)
+			}
+
+			log := slogdiscard.NewDiscardLogger()
+			handler := compact.New(log, goodsService.New(log, storageMock, producerMock))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/compact"+tc.query, nil))
+
+			require.Equal(t, tc.wantStatus, rr.Code, rr.Body.String())
+			require.JSONEq(t, tc.wantBody, rr.Body.String())
+		})
+	}
+}
@@ -2,12 +2,12 @@ package create
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	resp "github.com/Gonnekone/hezzl-test/core/internal/lib/api/response"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
 	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
 	"github.com/Gonnekone/hezzl-test/core/internal/models"
-	"github.com/Gonnekone/hezzl-test/core/internal/producer"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
@@ -15,51 +15,52 @@ import (
 	"net/http"
 )
 
-//go:generate mockgen -source=create.go -destination=mocks/GoodSaver.go -package=mocks
-type GoodSaver interface {
-	SaveGood(
+// GoodCreator is implemented by goods.Service.
+type GoodCreator interface {
+	Create(
 		ctx context.Context,
 		name string,
 		projectID string,
 	) (*models.Good, error)
-	InvalidList(ctx context.Context) error
 }
 
 type Request struct {
-	Name string `json:"name" validate:"required"`
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+func (r *Request) Sanitize() {
+	r.Name = request.Text(r.Name)
 }
 
 func New(
 	log *slog.Logger,
-	goodSaver GoodSaver,
-	producer producer.ProducerInterface,
+	goodCreator GoodCreator,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.create.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
 		)
 
 		var req Request
 
-		err := render.DecodeJSON(r.Body, &req)
+		err := request.DecodeJSON(r.Body, &req)
 		if err != nil {
 			log.Error("failed to decode request body", sl.Err(err))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("failed to decode request body"))
+			problem.Render(w, r, problem.Decode(err))
 
 			return
 		}
 
 		projectID := r.URL.Query().Get("projectId")
-		if projectID == "" {
-			log.Info("projectId is empty")
+		if _, err := request.ParseID(projectID); err != nil {
+			log.Info("projectId is invalid", slog.String("project_id", projectID))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("invalid url params"))
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
 
 			return
 		}
@@ -70,46 +71,24 @@ func New(
 
 			log.Error("invalid request body", sl.Err(err))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.ValidationError(validateErr))
+			problem.Render(w, r, problem.Validation(validateErr))
 
 			return
 		}
 
 		log.Info("request body decoded", slog.Any("request_body", req))
 
-		good, err := goodSaver.SaveGood(r.Context(), req.Name, projectID)
+		good, err := goodCreator.Create(r.Context(), req.Name, projectID)
 		if err != nil {
 			log.Error("failed to save good", sl.Err(err))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("failed to save good"))
-
-			return
-		}
-
-		err = goodSaver.InvalidList(r.Context())
-		if err != nil {
-			log.Error("failed to invalid cached list", sl.Err(err))
-
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, resp.Error("failed to invalid cached list"))
+			problem.Error(w, r, err)
 
 			return
 		}
 
 		log.Info("good added", slog.Any("good", good))
 
-		data, err := json.Marshal(good)
-		if err != nil {
-			log.Warn("failed to marshal data", sl.Err(err))
-		}
-
-		err = producer.SendAsync(data)
-		if err != nil {
-			log.Warn("failed to send message to nats", sl.Err(err))
-		}
-
 		// здесь мы еще раз маршаллим, по хорошему просто передавать []byte
 		render.JSON(w, r, good)
 	}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/create"
-	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/create/mocks"
 	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
 	"github.com/Gonnekone/hezzl-test/core/internal/models"
 	producer_mocks "github.com/Gonnekone/hezzl-test/core/internal/producer/mocks"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/service/goods/mocks"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
 	"go.uber.org/mock/gomock"
 	"net/http"
 	"net/http/httptest"
@@ -61,6 +63,7 @@ func TestSaveHandler(t *testing.T) {
 					Priority:    5,
 					Removed:     false,
 					CreatedAt:   time.UnixMilli(1234567890),
+					Version:     1,
 				},
 			},
 			invalidCacheMock: &invalidCacheMock{},
@@ -70,7 +73,7 @@ func TestSaveHandler(t *testing.T) {
 			wantBody: `{
 "id":1,"projectId":1,"name":"Apple",
 "description":"NO DESC","priority":5,
-"removed":false,"createdAt":"1970-01-15T09:56:07.89+03:00"
+"removed":false,"createdAt":"1970-01-15T09:56:07.89+03:00","version":1
 }`,
 			wantStatus: http.StatusOK,
 		},
@@ -79,7 +82,10 @@ func TestSaveHandler(t *testing.T) {
 			reqBody:    `{"name":""}`,
 			projectID:  "1",
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"field Name is a required field", "status":"Error"}`,
+			wantBody: `{
+"type":"urn:hezzl:problem:validation_failed","title":"Validation failed","status":400,
+"detail":"field Name is a required field","instance":"/good/create","code":"validation_failed"
+}`,
 		},
 		{
 			name: "SaveGood error",
@@ -90,15 +96,36 @@ func TestSaveHandler(t *testing.T) {
 			},
 			reqBody:    `{"name":"Apple"}`,
 			projectID:  "1",
-			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"status":"Error","error":"failed to save good"}`,
+			wantStatus: http.StatusInternalServerError,
+			wantBody: `{
+"type":"urn:hezzl:problem:internal","title":"Internal error","status":500,
+"instance":"/good/create","code":"internal"
+}`,
+		},
+		{
+			name: "Unknown project",
+			goodSaverMock: &goodSaverMock{
+				name:      "Apple",
+				projectID: "7",
+				err:       fmt.Errorf("save good: %w", storageerr.ErrProjectNotFound),
+			},
+			reqBody:    `{"name":"Apple"}`,
+			projectID:  "7",
+			wantStatus: http.StatusNotFound,
+			wantBody: `{
+"type":"urn:hezzl:problem:project_not_found","title":"Project not found","status":404,
+"instance":"/good/create","code":"project_not_found"
+}`,
 		},
 		{
 			name:       "Missing projectId",
 			reqBody:    `{"name":"Apple"}`,
 			projectID:  "",
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"status":"Error","error":"invalid url params"}`,
+			wantBody: `{
+"type":"urn:hezzl:problem:invalid_request","title":"Invalid request","status":400,
+"detail":"invalid url params","instance":"/good/create","code":"invalid_request"
+}`,
 		},
 		{
 			name: "Coulnd not invalidate cache",
@@ -113,15 +140,23 @@ func TestSaveHandler(t *testing.T) {
 					Priority:    5,
 					Removed:     false,
 					CreatedAt:   time.UnixMilli(1234567890),
+					Version:     1,
 				},
 			},
 			invalidCacheMock: &invalidCacheMock{
 				err: storageErr,
 			},
-			reqBody:    `{"name":"Apple"}`,
-			projectID:  "1",
-			wantBody:   `{"status":"Error","error":"failed to invalid cached list"}`,
-			wantStatus: http.StatusInternalServerError,
+			// The good is stored, a stale cached list only lives until its
+			// hard TTL.
+			producerMock: &producerMock{},
+			reqBody:      `{"name":"Apple"}`,
+			projectID:    "1",
+			wantBody: `{
+"id":1,"projectId":1,"name":"Apple",
+"description":"NO DESC","priority":5,
+"removed":false,"createdAt":"1970-01-15T09:56:07.89+03:00","version":1
+}`,
+			wantStatus: http.StatusOK,
 		},
 	}
 
@@ -134,7 +169,7 @@ func TestSaveHandler(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
-			goodSaverMock := mocks.NewMockGoodSaver(ctrl)
+			goodSaverMock := mocks.NewMockStorage(ctrl)
 			producerMock := producer_mocks.NewMockProducerInterface(ctrl)
 
 			if tc.goodSaverMock != nil {
@@ -145,17 +180,18 @@ func TestSaveHandler(t *testing.T) {
 
 			if tc.invalidCacheMock != nil {
 				goodSaverMock.EXPECT().
-					InvalidList(gomock.Any()).
+					InvalidList(gomock.Any(), tc.projectID).
 					Return(tc.invalidCacheMock.err).Times(1)
 			}
 
 			if tc.producerMock != nil {
 				producerMock.EXPECT().
-					SendAsync(gomock.Any()).
+					SendAsync(gomock.Any(), gomock.Any()).
 					Return(tc.producerMock.err).Times(1)
 			}
 
-			handler := create.New(slogdiscard.NewDiscardLogger(), goodSaverMock, producerMock)
+			log := slogdiscard.NewDiscardLogger()
+			handler := create.New(log, goodsService.New(log, goodSaverMock, producerMock))
 
 			url := "/good/create"
 			if tc.projectID != "" {
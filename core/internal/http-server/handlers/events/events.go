@@ -0,0 +1,161 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/Gonnekone/hezzl-test/core/internal/events"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EventReset tells the client that events were missed while it was away,
+// because the stream no longer holds them, and it should reload the list.
+const EventReset = "reset"
+
+type Subscriber interface {
+	Subscribe(ctx context.Context, after uint64) (<-chan events.Event, error)
+}
+
+// New streams changes of the goods of a project as Server-Sent Events.
+// The id of each event is its position in the stream, a client that
+// reconnects with it in Last-Event-ID, or lastEventId, gets the events it
+// missed. Idle
+// streams get a comment every heartbeat.
+func New(log *slog.Logger, subscriber Subscriber, heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.events.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		// An empty projectId streams every project, RequireProject lets
+		// only admins through without one.
+		var projectID int
+
+		if s := r.URL.Query().Get("projectId"); s != "" {
+			id, err := request.ParseID(s)
+			if err != nil {
+				problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+				return
+			}
+
+			projectID = id
+		}
+
+		// A browser opening a new EventSource with a fresh ticket cannot
+		// set Last-Event-ID, it passes lastEventId instead.
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("lastEventId")
+		}
+
+		var after uint64
+
+		if s := lastEventID; s != "" {
+			id, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid Last-Event-ID"))
+
+				return
+			}
+
+			after = id
+		}
+
+		ch, err := subscriber.Subscribe(r.Context(), after)
+		if err != nil {
+			log.Error("failed to subscribe to events", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		// The stream outlives the write timeout of the server.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Warn("failed to clear write deadline", sl.Err(err))
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		//nolint: errcheck
+		fmt.Fprint(w, ": connected\n\n")
+		//nolint: errcheck
+		rc.Flush()
+
+		log.Info("event stream opened", slog.Int("project_id", projectID), slog.Uint64("after", after))
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		first := true
+
+		for {
+			var err error
+
+			select {
+			case <-r.Context().Done():
+				log.Info("event stream closed")
+
+				return
+
+			case <-ticker.C:
+				_, err = fmt.Fprint(w, ": heartbeat\n\n")
+
+			case ev, ok := <-ch:
+				// The subscriber fell behind. The client reconnects
+				// with Last-Event-ID and gets what it missed.
+				if !ok {
+					log.Info("event stream fell behind, closing stream")
+
+					return
+				}
+
+				if first && after > 0 && ev.ID != after+1 {
+					_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {}\n\n", ev.ID-1, EventReset)
+				}
+				first = false
+
+				// A moved good is news to both of its projects.
+				if err == nil && (projectID == 0 || ev.ProjectID == projectID || ev.FromProjectID == projectID) {
+					_, err = writeEvent(w, ev)
+				}
+			}
+
+			if err == nil {
+				err = rc.Flush()
+			}
+
+			if err != nil {
+				log.Info("failed to write event, closing stream", sl.Err(err))
+
+				return
+			}
+
+			ticker.Reset(heartbeat)
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev events.Event) (int, error) {
+	if ev.Type == "" {
+		return fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.ID, ev.Data)
+	}
+
+	return fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+}
@@ -0,0 +1,124 @@
+package events_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	libevents "github.com/Gonnekone/hezzl-test/core/internal/events"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/events"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSubscriber delivers its events and records where the client resumed.
+type fakeSubscriber struct {
+	events []libevents.Event
+	after  chan uint64
+}
+
+func (s *fakeSubscriber) Subscribe(_ context.Context, after uint64) (<-chan libevents.Event, error) {
+	s.after <- after
+
+	ch := make(chan libevents.Event, len(s.events))
+	for _, ev := range s.events {
+		ch <- ev
+	}
+
+	return ch, nil
+}
+
+// readFrames reads n frames of the stream, comments included.
+func readFrames(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+
+	var (
+		frames []string
+		frame  strings.Builder
+	)
+
+	for len(frames) < n {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		if line == "\n" {
+			frames = append(frames, frame.String())
+			frame.Reset()
+
+			continue
+		}
+
+		frame.WriteString(line)
+	}
+
+	return frames
+}
+
+func TestStream(t *testing.T) {
+	subscriber := &fakeSubscriber{
+		events: []libevents.Event{
+			{ID: 7, Type: "created", ProjectID: 1, Data: []byte(`{"id":1}`)},
+			{ID: 8, Type: "created", ProjectID: 2, Data: []byte(`{"id":2}`)},
+			{ID: 9, Type: "removed", ProjectID: 1, Data: []byte(`{"id":1}`)},
+			{ID: 10, Type: "moved", ProjectID: 2, FromProjectID: 1, Data: []byte(`{"id":1}`)},
+		},
+		after: make(chan uint64, 1),
+	}
+
+	srv := httptest.NewServer(events.New(slogdiscard.NewDiscardLogger(), subscriber, 50*time.Millisecond))
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"?projectId=1", nil)
+	require.NoError(t, err)
+	// Events 4 to 6 are gone from the stream.
+	req.Header.Set("Last-Event-ID", "3")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() }) //nolint: errcheck
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	require.Equal(t, uint64(3), <-subscriber.after)
+
+	frames := readFrames(t, bufio.NewReader(resp.Body), 6)
+	require.Equal(t, []string{
+		": connected\n",
+		"id: 6\nevent: reset\ndata: {}\n",
+		"id: 7\nevent: created\ndata: {\"id\":1}\n",
+		"id: 9\nevent: removed\ndata: {\"id\":1}\n",
+		"id: 10\nevent: moved\ndata: {\"id\":1}\n",
+		": heartbeat\n",
+	}, frames)
+}
+
+func TestInvalidLastEventID(t *testing.T) {
+	subscriber := &fakeSubscriber{after: make(chan uint64, 1)}
+
+	req := httptest.NewRequest(http.MethodGet, "/goods/events?projectId=1", nil)
+	req.Header.Set("Last-Event-ID", "x")
+
+	rr := httptest.NewRecorder()
+	events.New(slogdiscard.NewDiscardLogger(), subscriber, time.Second).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Empty(t, subscriber.after, "subscribed with an invalid Last-Event-ID")
+}
+
+func TestLastEventIDInQuery(t *testing.T) {
+	subscriber := &fakeSubscriber{after: make(chan uint64, 1)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/goods/events?projectId=1&lastEventId=41", nil).WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	events.New(slogdiscard.NewDiscardLogger(), subscriber, time.Second).ServeHTTP(rr, req)
+
+	require.Equal(t, uint64(41), <-subscriber.after)
+}
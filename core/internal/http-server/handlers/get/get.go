@@ -0,0 +1,105 @@
+package get
+
+import (
+	"context"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/metrics"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type GoodGetter interface {
+	GetGood(
+		ctx context.Context,
+		id string,
+		projectID string,
+	) (*models.Good, error)
+	GetCachedGood(ctx context.Context, id string, projectID string) (*models.CachedGood, error)
+	SaveGoodInCache(ctx context.Context, version int64, good models.Good) error
+}
+
+func New(log *slog.Logger, goodGetter GoodGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.get.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		id := r.URL.Query().Get("id")
+		if _, err := request.ParseID(id); err != nil {
+			log.Info("id is invalid", slog.String("id", id))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+			return
+		}
+
+		projectID := r.URL.Query().Get("projectId")
+		if _, err := request.ParseID(projectID); err != nil {
+			log.Info("projectId is invalid", slog.String("project_id", projectID))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+			return
+		}
+
+		good, err := Read(r.Context(), log, goodGetter, id, projectID)
+		if err != nil {
+			log.Error("failed to get good", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("good got successfully")
+
+		render.JSON(w, r, good)
+	}
+}
+
+// Read returns the good from cache, or reads it from the main storage and
+// caches it under the version looked up before the read. Cache errors are
+// only logged. It is shared by the HTTP and gRPC transports.
+func Read(
+	ctx context.Context,
+	log *slog.Logger,
+	goodGetter GoodGetter,
+	id string,
+	projectID string,
+) (*models.Good, error) {
+	cached, err := goodGetter.GetCachedGood(ctx, id, projectID)
+	if err != nil {
+		log.Warn("failed to get cached good", sl.Err(err))
+	}
+
+	if cached != nil && cached.Good != nil {
+		metrics.CacheRequests.WithLabelValues("good", metrics.CacheHit).Inc()
+
+		return cached.Good, nil
+	}
+
+	metrics.CacheRequests.WithLabelValues("good", metrics.CacheMiss).Inc()
+
+	good, err := goodGetter.GetGood(ctx, id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil {
+		if err := goodGetter.SaveGoodInCache(ctx, cached.Version, *good); err != nil {
+			log.Warn("failed to cache good", sl.Err(err))
+		}
+	}
+
+	return good, nil
+}
@@ -0,0 +1,27 @@
+package graphql
+
+import (
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+)
+
+// gqlError reports a problem in the errors of a GraphQL response. Clients
+// match on extensions.code, the same code the REST API uses.
+type gqlError struct {
+	problem *problem.Problem
+}
+
+func problemError(p *problem.Problem) error {
+	return &gqlError{problem: p}
+}
+
+func (e *gqlError) Error() string {
+	if e.problem.Detail != "" {
+		return e.problem.Detail
+	}
+
+	return e.problem.Title
+}
+
+func (e *gqlError) Extensions() map[string]any {
+	return map[string]any{"code": e.problem.Code}
+}
@@ -0,0 +1,125 @@
+// Package graphql serves projects and goods over GraphQL at /graphql.
+// Mutations go through the same goods.Service as the REST handlers.
+// Projects, goods pages and history are loaded in batches per request, so
+// nested fields cost a query per level rather than per item. History is
+// read from ClickHouse, see storage/clickhouse.
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/create"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/get"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/remove"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/update"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	gql "github.com/graph-gophers/graphql-go"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	// maxFirst is the largest page of goods. Resolvers of a whole page run
+	// in parallel, so their loads fall into the same batch.
+	maxFirst = 100
+	maxDepth = 8
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+type ProjectGetter interface {
+	GetProjects(ctx context.Context, ids []int) ([]models.Project, error)
+}
+
+type GoodPager interface {
+	ListProjectGoods(
+		ctx context.Context,
+		projectIDs []int,
+		filter models.GoodFilter,
+	) (map[int]*models.GoodPage, error)
+}
+
+// Storage is everything the resolvers read from storage.Storage.
+type Storage interface {
+	get.GoodGetter
+	ProjectGetter
+	GoodPager
+}
+
+// HistoryReader is implemented by clickhouse.ClickHouseStorage.
+type HistoryReader interface {
+	ProjectsHistory(ctx context.Context, projectIDs []int, limit int) (map[int][]models.HistoryEvent, error)
+	GoodsHistory(ctx context.Context, ids []int, limit int) (map[int][]models.HistoryEvent, error)
+}
+
+// Goods is implemented by goods.Service.
+type Goods interface {
+	create.GoodCreator
+	update.GoodUpdater
+	remove.GoodDeleter
+	reprioritize.GoodPriorityUpdater
+}
+
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
+}
+
+// New returns the GraphQL handler. Errors of a query are reported in the
+// errors of the response with the problem code in extensions.code, the
+// status is 200 unless the request itself cannot be read. history is nil
+// when history is disabled, history fields then fail with an internal
+// error.
+func New(log *slog.Logger, storage Storage, goods Goods, history HistoryReader) http.HandlerFunc {
+	schema := gql.MustParseSchema(schemaSDL,
+		&resolver{log: log, storage: storage, goods: goods},
+		gql.MaxParallelism(maxFirst),
+		gql.MaxDepth(maxDepth),
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.graphql.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		var req Request
+
+		err := request.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Decode(err))
+
+			return
+		}
+
+		if req.Query == "" {
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "query is required"))
+
+			return
+		}
+
+		ctx := withLoaders(r.Context(), storage, history)
+
+		resp := schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+		if len(resp.Errors) > 0 {
+			log.Info("query completed with errors", slog.Int("errors", len(resp.Errors)))
+		}
+
+		render.JSON(w, r, resp)
+	}
+}
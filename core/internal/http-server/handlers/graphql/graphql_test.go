@@ -0,0 +1,432 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/graphql"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/stretchr/testify/require"
+)
+
+var createdAt = time.Date(2025, 6, 16, 19, 0, 41, 0, time.UTC)
+
+// countingStorage has projects 1 to 3 with two goods each and counts the
+// queries made. It also serves as the history, with one event per
+// project and good.
+type countingStorage struct {
+	goodsService.Storage
+
+	mu             sync.Mutex
+	projectQueries int
+	goodsQueries   int
+	goodsFilters   []models.GoodFilter
+	historyQueries []string
+	saved          []string
+}
+
+func (s *countingStorage) GetProjects(_ context.Context, ids []int) ([]models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.projectQueries++
+
+	var projects []models.Project
+	for _, id := range ids {
+		if id <= 3 {
+			projects = append(projects, models.Project{ID: id, Name: "project", CreatedAt: createdAt})
+		}
+	}
+
+	return projects, nil
+}
+
+func (s *countingStorage) ListProjectGoods(
+	_ context.Context,
+	projectIDs []int,
+	filter models.GoodFilter,
+) (map[int]*models.GoodPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.goodsQueries++
+	s.goodsFilters = append(s.goodsFilters, filter)
+
+	pages := make(map[int]*models.GoodPage, len(projectIDs))
+	for _, id := range projectIDs {
+		pages[id] = &models.GoodPage{
+			Goods: []models.Good{
+				{ID: id*10 + 1, ProjectID: id, Name: "a", Priority: 1, CreatedAt: createdAt},
+				{ID: id*10 + 2, ProjectID: id, Name: "b", Priority: 2, CreatedAt: createdAt},
+			},
+			Total:   3,
+			HasNext: true,
+		}
+	}
+
+	return pages, nil
+}
+
+func (s *countingStorage) ProjectsHistory(
+	_ context.Context,
+	projectIDs []int,
+	limit int,
+) (map[int][]models.HistoryEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.historyQueries = append(s.historyQueries, fmt.Sprintf("projects %v limit %d", slices.Sorted(slices.Values(projectIDs)), limit))
+
+	res := make(map[int][]models.HistoryEvent, len(projectIDs))
+	for _, id := range projectIDs {
+		res[id] = []models.HistoryEvent{{
+			GoodEvent: models.GoodEvent{
+				Good:  models.Good{ID: id*10 + 1, ProjectID: id, Name: "a", Priority: 1},
+				Type:  models.EventCreated,
+				Actor: "admin",
+			},
+			Time: createdAt,
+		}}
+	}
+
+	return res, nil
+}
+
+func (s *countingStorage) GoodsHistory(
+	_ context.Context,
+	ids []int,
+	limit int,
+) (map[int][]models.HistoryEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.historyQueries = append(s.historyQueries, fmt.Sprintf("goods %v limit %d", slices.Sorted(slices.Values(ids)), limit))
+
+	res := make(map[int][]models.HistoryEvent, len(ids))
+	for _, id := range ids {
+		res[id] = []models.HistoryEvent{{
+			GoodEvent: models.GoodEvent{
+				Good:          models.Good{ID: id, ProjectID: id / 10, Name: "a", Priority: 1},
+				Type:          models.EventMoved,
+				Actor:         "admin",
+				FromProjectID: 9,
+			},
+			Time: createdAt,
+		}}
+	}
+
+	return res, nil
+}
+
+func (s *countingStorage) SaveGood(_ context.Context, name, projectID string) (*models.Good, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saved = append(s.saved, name)
+
+	if projectID != "1" {
+		return nil, storageerr.ErrProjectNotFound
+	}
+
+	return &models.Good{ID: 7, ProjectID: 1, Name: name, Priority: 7, CreatedAt: createdAt}, nil
+}
+
+func (*countingStorage) UpdateGood(context.Context, string, string, string, string) (*models.Good, error) {
+	return nil, storageerr.ErrNotFound
+}
+
+func (*countingStorage) DeleteGood(context.Context, string, string) (*models.Good, error) {
+	return nil, storageerr.ErrNotFound
+}
+
+func (*countingStorage) UpdateGoodsPriority(context.Context, string, string, int) ([]models.Good, error) {
+	return nil, storageerr.ErrNotFound
+}
+
+func (*countingStorage) GetGood(context.Context, string, string) (*models.Good, error) {
+	return nil, storageerr.ErrNotFound
+}
+
+func (*countingStorage) InvalidList(context.Context, string) error                 { return nil }
+func (*countingStorage) InvalidGoods(context.Context, ...models.Good) error        { return nil }
+func (*countingStorage) SaveGoodInCache(context.Context, int64, models.Good) error { return nil }
+func (*countingStorage) GetCachedGood(context.Context, string, string) (*models.CachedGood, error) {
+	return nil, nil
+}
+
+type fakeProducer struct{}
+
+func (fakeProducer) Send(context.Context, []byte) error      { return nil }
+func (fakeProducer) SendAsync(context.Context, []byte) error { return nil }
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func exec(t *testing.T, storage *countingStorage, principal *auth.Principal, query string) response {
+	t.Helper()
+
+	return execWithHistory(t, storage, storage, principal, query)
+}
+
+func execWithHistory(
+	t *testing.T,
+	storage *countingStorage,
+	history graphql.HistoryReader,
+	principal *auth.Principal,
+	query string,
+) response {
+	t.Helper()
+
+	body, err := json.Marshal(graphql.Request{Query: query})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+
+	rr := httptest.NewRecorder()
+	log := slogdiscard.NewDiscardLogger()
+	graphql.New(log, storage, goodsService.New(log, storage, fakeProducer{}), history).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var resp response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+	return resp
+}
+
+var admin = &auth.Principal{Actor: "admin", Admin: true}
+
+func TestNestedFieldsAreBatched(t *testing.T) {
+	storage := &countingStorage{}
+
+	resp := exec(t, storage, admin, `{
+		projects(ids: [1, 2, 3, 4]) {
+			id
+			goods(first: 2, filter: {name: "A"}) {
+				totalCount
+				pageInfo { hasNextPage endCursor }
+				edges { cursor node { name project { id name } } }
+			}
+		}
+	}`)
+	require.Empty(t, resp.Errors)
+
+	var data struct {
+		Projects []struct {
+			ID    string `json:"id"`
+			Goods struct {
+				TotalCount int `json:"totalCount"`
+				PageInfo   struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Edges []struct {
+					Cursor string `json:"cursor"`
+				} `json:"edges"`
+			} `json:"goods"`
+		} `json:"projects"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+
+	require.Len(t, data.Projects, 3, "missing project 4 is skipped")
+	require.Equal(t, "1", data.Projects[0].ID)
+	require.Equal(t, 3, data.Projects[0].Goods.TotalCount)
+	require.True(t, data.Projects[0].Goods.PageInfo.HasNextPage)
+	require.Equal(t, data.Projects[0].Goods.Edges[1].Cursor, data.Projects[0].Goods.PageInfo.EndCursor)
+
+	// One query for the projects, one for their goods and none for the
+	// projects of the goods, which are already loaded.
+	require.Equal(t, 1, storage.projectQueries)
+	require.Equal(t, 1, storage.goodsQueries)
+	require.Equal(t, "A", storage.goodsFilters[0].Name)
+
+	// The end cursor continues after the last good of the page.
+	resp = exec(t, storage, admin, `{ project(id: 1) { goods(after: "`+data.Projects[0].Goods.PageInfo.EndCursor+`") { totalCount } } }`)
+	require.Empty(t, resp.Errors)
+	require.Equal(t, &models.GoodCursor{Priority: 2, ID: 12}, storage.goodsFilters[1].After)
+	require.Equal(t, 10, storage.goodsFilters[1].First)
+}
+
+func TestErrors(t *testing.T) {
+	reader := &auth.Principal{Actor: "api_key:1", Projects: map[int]auth.Scope{1: auth.ScopeRead}}
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		query     string
+		code      string
+	}{
+		{name: "other project", principal: reader, query: `{ project(id: 2) { name } }`, code: "forbidden"},
+		{name: "write with read scope", principal: reader, query: `mutation { createGood(projectId: 1, name: "x") { id } }`, code: "forbidden"},
+		{name: "invalid id", principal: admin, query: `{ project(id: "x") { name } }`, code: "invalid_request"},
+		{name: "empty name", principal: admin, query: `mutation { createGood(projectId: 1, name: " ") { id } }`, code: "validation_failed"},
+		{name: "unknown project", principal: admin, query: `mutation { createGood(projectId: 2, name: "x") { id } }`, code: "project_not_found"},
+		{name: "missing good", principal: admin, query: `mutation { removeGood(projectId: 1, id: 1) { id } }`, code: "not_found"},
+		{name: "page too large", principal: admin, query: `{ project(id: 1) { goods(first: 101) { totalCount } } }`, code: "invalid_request"},
+		{name: "invalid cursor", principal: admin, query: `{ project(id: 1) { goods(after: "!") { totalCount } } }`, code: "invalid_request"},
+		{name: "history too large", principal: admin, query: `{ project(id: 1) { history(first: 101) { type } } }`, code: "invalid_request"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := exec(t, &countingStorage{}, tc.principal, tc.query)
+
+			require.Len(t, resp.Errors, 1)
+			require.Equal(t, tc.code, resp.Errors[0].Extensions.Code, resp.Errors[0].Message)
+		})
+	}
+}
+
+func TestHistoryIsBatched(t *testing.T) {
+	storage := &countingStorage{}
+
+	resp := exec(t, storage, admin, `{
+		projects(ids: [1, 2]) {
+			history(first: 5) { type actor goodId projectId fromProjectId }
+			goods { edges { node { history { type goodId fromProjectId at } } } }
+		}
+	}`)
+	require.Empty(t, resp.Errors)
+
+	// One query for the history of the projects and one for the history of
+	// all their goods.
+	require.ElementsMatch(t, []string{"projects [1 2] limit 5", "goods [11 12 21 22] limit 20"}, storage.historyQueries)
+
+	var data struct {
+		Projects []struct {
+			History []map[string]any `json:"history"`
+			Goods   struct {
+				Edges []struct {
+					Node struct {
+						History []map[string]any `json:"history"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"goods"`
+		} `json:"projects"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+
+	require.Equal(t, []map[string]any{
+		{"type": "created", "actor": "admin", "goodId": "11", "projectId": "1", "fromProjectId": nil},
+	}, data.Projects[0].History)
+	require.Equal(t, []map[string]any{
+		{"type": "moved", "goodId": "22", "fromProjectId": "9", "at": "2025-06-16T19:00:41Z"},
+	}, data.Projects[1].Goods.Edges[1].Node.History)
+}
+
+// movedHistory is the history of goods that were updated in project 9
+// before they moved to their current project.
+type movedHistory struct {
+	graphql.HistoryReader
+}
+
+func (movedHistory) GoodsHistory(_ context.Context, ids []int, _ int) (map[int][]models.HistoryEvent, error) {
+	res := make(map[int][]models.HistoryEvent, len(ids))
+	for _, id := range ids {
+		res[id] = []models.HistoryEvent{
+			{GoodEvent: models.GoodEvent{
+				Good:          models.Good{ID: id, ProjectID: id / 10, Name: "a"},
+				Type:          models.EventMoved,
+				FromProjectID: 9,
+			}},
+			{GoodEvent: models.GoodEvent{
+				Good: models.Good{ID: id, ProjectID: 9, Name: "secret"},
+				Type: models.EventUpdated,
+			}},
+		}
+	}
+
+	return res, nil
+}
+
+func TestGoodHistoryLeavesOutUnreadableProjects(t *testing.T) {
+	const query = `{ project(id: 1) { goods(first: 1) { edges { node { history { type projectId name } } } } } }`
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		want      string
+	}{
+		{
+			name:      "Reader of the current project",
+			principal: &auth.Principal{Actor: "api_key:1", Projects: map[int]auth.Scope{1: auth.ScopeRead}},
+			want:      `[{"type":"moved","projectId":"1","name":"a"}]`,
+		},
+		{
+			name:      "Reader of both projects",
+			principal: &auth.Principal{Actor: "api_key:2", Projects: map[int]auth.Scope{1: auth.ScopeRead, 9: auth.ScopeRead}},
+			want:      `[{"type":"moved","projectId":"1","name":"a"},{"type":"updated","projectId":"9","name":"secret"}]`,
+		},
+		{
+			name:      "Admin",
+			principal: admin,
+			want:      `[{"type":"moved","projectId":"1","name":"a"},{"type":"updated","projectId":"9","name":"secret"}]`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := execWithHistory(t, &countingStorage{}, movedHistory{}, tc.principal, query)
+			require.Empty(t, resp.Errors)
+
+			var data struct {
+				Project struct {
+					Goods struct {
+						Edges []struct {
+							Node struct {
+								History json.RawMessage `json:"history"`
+							} `json:"node"`
+						} `json:"edges"`
+					} `json:"goods"`
+				} `json:"project"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Data, &data))
+			require.Len(t, data.Project.Goods.Edges, 2)
+			require.JSONEq(t, tc.want, string(data.Project.Goods.Edges[0].Node.History))
+		})
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	resp := execWithHistory(t, &countingStorage{}, nil, admin, `{ project(id: 1) { name history { type } } }`)
+
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "internal", resp.Errors[0].Extensions.Code)
+	require.Equal(t, "history is disabled", resp.Errors[0].Message)
+}
+
+func TestCreateGood(t *testing.T) {
+	storage := &countingStorage{}
+
+	resp := exec(t, storage, admin, `mutation { createGood(projectId: 1, name: " Mango ") { id name project { id } } }`)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"createGood":{"id":"7","name":"Mango","project":{"id":"1"}}}`, string(resp.Data))
+	require.Equal(t, []string{"Mango"}, storage.saved)
+}
+
+func TestMissingGoodIsNull(t *testing.T) {
+	resp := exec(t, &countingStorage{}, admin, `{ good(projectId: 1, id: 1) { id } }`)
+
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"good":null}`, string(resp.Data))
+}
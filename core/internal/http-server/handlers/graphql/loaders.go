@@ -0,0 +1,135 @@
+package graphql
+
+import (
+	"context"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/dataloader"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"time"
+)
+
+// loadWait is how long a loader collects keys before it queries storage.
+const loadWait = 2 * time.Millisecond
+
+// goodsKey is a page of goods of a project. Pages requested with the same
+// filter are loaded with one query.
+type goodsKey struct {
+	projectID int
+	filter    filterKey
+}
+
+// filterKey is models.GoodFilter without pointers, so it can be a map key.
+type filterKey struct {
+	name           string
+	includeRemoved bool
+	first          int
+	hasAfter       bool
+	after          models.GoodCursor
+}
+
+func (k filterKey) filter() models.GoodFilter {
+	f := models.GoodFilter{
+		Name:           k.name,
+		IncludeRemoved: k.includeRemoved,
+		First:          k.first,
+	}
+
+	if k.hasAfter {
+		after := k.after
+		f.After = &after
+	}
+
+	return f
+}
+
+// historyKey is the latest events of a project or a good. Keys with the
+// same first are loaded with one query.
+type historyKey struct {
+	id    int
+	first int
+}
+
+type loaders struct {
+	projects       *dataloader.Loader[int, *models.Project]
+	goods          *dataloader.Loader[goodsKey, *models.GoodPage]
+	projectHistory *dataloader.Loader[historyKey, []models.HistoryEvent]
+	goodHistory    *dataloader.Loader[historyKey, []models.HistoryEvent]
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, storage Storage, history HistoryReader) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		projects: dataloader.New(func(ctx context.Context, ids []int) (map[int]*models.Project, error) {
+			projects, err := storage.GetProjects(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+
+			res := make(map[int]*models.Project, len(projects))
+			for i := range projects {
+				res[projects[i].ID] = &projects[i]
+			}
+
+			return res, nil
+		}, loadWait, maxFirst),
+		goods: dataloader.New(func(ctx context.Context, keys []goodsKey) (map[goodsKey]*models.GoodPage, error) {
+			byFilter := make(map[filterKey][]int)
+			for _, k := range keys {
+				byFilter[k.filter] = append(byFilter[k.filter], k.projectID)
+			}
+
+			res := make(map[goodsKey]*models.GoodPage, len(keys))
+
+			for filter, projectIDs := range byFilter {
+				pages, err := storage.ListProjectGoods(ctx, projectIDs, filter.filter())
+				if err != nil {
+					return nil, err
+				}
+
+				for projectID, page := range pages {
+					res[goodsKey{projectID: projectID, filter: filter}] = page
+				}
+			}
+
+			return res, nil
+		}, loadWait, maxFirst),
+		projectHistory: historyLoader(history, HistoryReader.ProjectsHistory),
+		goodHistory:    historyLoader(history, HistoryReader.GoodsHistory),
+	})
+}
+
+// historyLoader batches the history of projects or goods, read by fetch.
+func historyLoader(
+	history HistoryReader,
+	fetch func(HistoryReader, context.Context, []int, int) (map[int][]models.HistoryEvent, error),
+) *dataloader.Loader[historyKey, []models.HistoryEvent] {
+	return dataloader.New(func(ctx context.Context, keys []historyKey) (map[historyKey][]models.HistoryEvent, error) {
+		if history == nil {
+			return nil, errHistoryDisabled
+		}
+
+		byFirst := make(map[int][]int)
+		for _, k := range keys {
+			byFirst[k.first] = append(byFirst[k.first], k.id)
+		}
+
+		res := make(map[historyKey][]models.HistoryEvent, len(keys))
+
+		for first, ids := range byFirst {
+			events, err := fetch(history, ctx, ids, first)
+			if err != nil {
+				return nil, err
+			}
+
+			for id, e := range events {
+				res[historyKey{id: id, first: first}] = e
+			}
+		}
+
+		return res, nil
+	}, loadWait, maxFirst)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,610 @@
+package graphql
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/create"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/get"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/update"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/dataloader"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+	gql "github.com/graph-gophers/graphql-go"
+	"golang.org/x/sync/errgroup"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// errHistoryDisabled fails history fields when history is disabled in
+// config.
+var errHistoryDisabled = errors.New("history is disabled")
+
+type resolver struct {
+	log     *slog.Logger
+	storage Storage
+	goods   Goods
+}
+
+func (r *resolver) logger(ctx context.Context, op string) *slog.Logger {
+	return r.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+		slog.String("actor", auth.Actor(ctx)),
+	)
+}
+
+func (r *resolver) Project(ctx context.Context, args struct{ ID gql.ID }) (*projectResolver, error) {
+	projectID, err := authorize(ctx, args.ID, auth.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := loadersFrom(ctx).projects.Load(ctx, projectID)
+	if err != nil {
+		r.logger(ctx, "handlers.graphql.Project").Error("failed to load project", sl.Err(err))
+
+		return nil, problemError(problem.FromError(err))
+	}
+
+	if project == nil {
+		return nil, nil
+	}
+
+	return &projectResolver{project: project}, nil
+}
+
+func (r *resolver) Projects(ctx context.Context, args struct{ IDs []gql.ID }) ([]*projectResolver, error) {
+	ids := make([]int, len(args.IDs))
+	for i, id := range args.IDs {
+		projectID, err := authorize(ctx, id, auth.ScopeRead)
+		if err != nil {
+			return nil, err
+		}
+
+		ids[i] = projectID
+	}
+
+	// Loads run concurrently to fall into one batch.
+	projects := make([]*models.Project, len(ids))
+
+	var g errgroup.Group
+	for i, id := range ids {
+		g.Go(func() error {
+			project, err := loadersFrom(ctx).projects.Load(ctx, id)
+			projects[i] = project
+
+			return err
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		r.logger(ctx, "handlers.graphql.Projects").Error("failed to load projects", sl.Err(err))
+
+		return nil, problemError(problem.FromError(err))
+	}
+
+	res := make([]*projectResolver, 0, len(projects))
+	for _, project := range projects {
+		if project != nil {
+			res = append(res, &projectResolver{project: project})
+		}
+	}
+
+	return res, nil
+}
+
+func (r *resolver) Good(ctx context.Context, args struct{ ProjectID, ID gql.ID }) (*goodResolver, error) {
+	const op = "handlers.graphql.Good"
+
+	log := r.logger(ctx, op)
+
+	projectID, id, err := goodArgs(ctx, args.ProjectID, args.ID, auth.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	good, err := get.Read(ctx, log, r.storage, id, projectID)
+	if errors.Is(err, storageerr.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		log.Error("failed to get good", sl.Err(err))
+
+		return nil, problemError(problem.FromError(err))
+	}
+
+	return &goodResolver{good: *good}, nil
+}
+
+func (r *resolver) CreateGood(ctx context.Context, args struct {
+	ProjectID gql.ID
+	Name      string
+}) (*goodResolver, error) {
+	const op = "handlers.graphql.CreateGood"
+
+	log := r.logger(ctx, op)
+
+	projectID, err := authorizeString(ctx, args.ProjectID, auth.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	req := create.Request{Name: args.Name}
+	req.Sanitize()
+
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	good, err := r.goods.Create(ctx, req.Name, projectID)
+	if err != nil {
+		log.Error("failed to save good", sl.Err(err))
+
+		return nil, problemError(problem.FromError(err))
+	}
+
+	log.Info("good added", slog.Any("good", good))
+
+	return &goodResolver{good: *good}, nil
+}
+
+func (r *resolver) UpdateGood(ctx context.Context, args struct {
+	ProjectID   gql.ID
+	ID          gql.ID
+	Name        string
+	Description *string
+}) (*goodResolver, error) {
+	const op = "handlers.graphql.UpdateGood"
+
+	log := r.logger(ctx, op)
+
+	projectID, id, err := goodArgs(ctx, args.ProjectID, args.ID, auth.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	req := update.Request{Name: args.Name}
+	if args.Description != nil {
+		req.Desc = *args.Description
+	}
+	req.Sanitize()
+
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	good, err := r.goods.Update(ctx, id, projectID, req.Name, req.Desc)
+	if err != nil {
+		log.Error("failed to update good", sl.Err(err))
+
+		return nil, problemError(problem.FromError(err))
+	}
+
+	log.Info("good updated", slog.Any("good", good))
+
+	return &goodResolver{good: *good}, nil
+}
+
+func (r *resolver) RemoveGood(ctx context.Context, args struct{ ProjectID, ID gql.ID }) (*goodResolver, error) {
+	const op = "handlers.graphql.RemoveGood"
+
+	log := r.logger(ctx, op)
+
+	projectID, id, err := goodArgs(ctx, args.ProjectID, args.ID, auth.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	good, err := r.goods.Remove(ctx, id, projectID)
+	if err != nil {
+		log.Error("failed to delete good", sl.Err(err))
+
+		return nil, problemError(problem.FromError(err))
+	}
+
+	log.Info("good deleted")
+
+	return &goodResolver{good: *good}, nil
+}
+
+func (r *resolver) ReprioritizeGood(ctx context.Context, args struct {
+	ProjectID   gql.ID
+	ID          gql.ID
+	NewPriority int32
+}) ([]*goodResolver, error) {
+	const op = "handlers.graphql.ReprioritizeGood"
+
+	log := r.logger(ctx, op)
+
+	projectID, id, err := goodArgs(ctx, args.ProjectID, args.ID, auth.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	req := reprioritize.Request{NewPriority: int(args.NewPriority)}
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	goods, err := r.goods.Reprioritize(ctx, id, projectID, req.NewPriority)
+	if err != nil {
+		log.Error("failed to update priority", sl.Err(err))
+
+		return nil, problemError(problem.FromError(err))
+	}
+
+	log.Info("priority updated")
+
+	res := make([]*goodResolver, 0, len(goods))
+	for _, good := range goods {
+		res = append(res, &goodResolver{good: good})
+	}
+
+	return res, nil
+}
+
+type projectResolver struct {
+	project *models.Project
+}
+
+func (p *projectResolver) ID() gql.ID {
+	return gql.ID(strconv.Itoa(p.project.ID))
+}
+
+func (p *projectResolver) Name() string {
+	return p.project.Name
+}
+
+func (p *projectResolver) CreatedAt() gql.Time {
+	return gql.Time{Time: p.project.CreatedAt}
+}
+
+// History is the latest changes of the goods of the project. It needs no
+// further authorization than the project itself.
+func (p *projectResolver) History(ctx context.Context, args struct{ First int32 }) ([]*historyEventResolver, error) {
+	return loadHistory(ctx, loadersFrom(ctx).projectHistory, p.project.ID, args.First)
+}
+
+type goodFilterInput struct {
+	Name           *string
+	IncludeRemoved bool
+}
+
+func (p *projectResolver) Goods(ctx context.Context, args struct {
+	First  int32
+	After  *string
+	Filter *goodFilterInput
+}) (*connectionResolver, error) {
+	filter := filterKey{first: int(args.First)}
+
+	if filter.first < 0 || filter.first > maxFirst {
+		return nil, problemError(problem.New(problem.CodeInvalidRequest, fmt.Sprintf("first must be between 0 and %d", maxFirst)))
+	}
+
+	if args.After != nil {
+		after, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, problemError(problem.New(problem.CodeInvalidRequest, "invalid cursor"))
+		}
+
+		filter.hasAfter, filter.after = true, after
+	}
+
+	if args.Filter != nil {
+		if args.Filter.Name != nil {
+			filter.name = request.Text(*args.Filter.Name)
+		}
+
+		filter.includeRemoved = args.Filter.IncludeRemoved
+	}
+
+	page, err := loadersFrom(ctx).goods.Load(ctx, goodsKey{projectID: p.project.ID, filter: filter})
+	if err != nil {
+		return nil, problemError(problem.FromError(err))
+	}
+
+	if page == nil {
+		page = &models.GoodPage{}
+	}
+
+	return &connectionResolver{page: page}, nil
+}
+
+type connectionResolver struct {
+	page *models.GoodPage
+}
+
+func (c *connectionResolver) TotalCount() int32 {
+	return int32(c.page.Total)
+}
+
+func (c *connectionResolver) Edges() []*edgeResolver {
+	edges := make([]*edgeResolver, 0, len(c.page.Goods))
+	for _, good := range c.page.Goods {
+		edges = append(edges, &edgeResolver{good: good})
+	}
+
+	return edges
+}
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNext: c.page.HasNext}
+
+	if n := len(c.page.Goods); n > 0 {
+		cursor := encodeCursor(c.page.Goods[n-1])
+		info.endCursor = &cursor
+	}
+
+	return info
+}
+
+type edgeResolver struct {
+	good models.Good
+}
+
+func (e *edgeResolver) Cursor() string {
+	return encodeCursor(e.good)
+}
+
+func (e *edgeResolver) Node() *goodResolver {
+	return &goodResolver{good: e.good}
+}
+
+type pageInfoResolver struct {
+	hasNext   bool
+	endCursor *string
+}
+
+func (p *pageInfoResolver) HasNextPage() bool {
+	return p.hasNext
+}
+
+func (p *pageInfoResolver) EndCursor() *string {
+	return p.endCursor
+}
+
+type goodResolver struct {
+	good models.Good
+}
+
+func (g *goodResolver) ID() gql.ID {
+	return gql.ID(strconv.Itoa(g.good.ID))
+}
+
+func (g *goodResolver) ProjectID() gql.ID {
+	return gql.ID(strconv.Itoa(g.good.ProjectID))
+}
+
+// Project needs no authorization, the good was only resolved if its
+// project may be read.
+func (g *goodResolver) Project(ctx context.Context) (*projectResolver, error) {
+	project, err := loadersFrom(ctx).projects.Load(ctx, g.good.ProjectID)
+	if err != nil {
+		return nil, problemError(problem.FromError(err))
+	}
+
+	if project == nil {
+		return nil, problemError(problem.New(problem.CodeProjectNotFound, ""))
+	}
+
+	return &projectResolver{project: project}, nil
+}
+
+func (g *goodResolver) Name() string {
+	return g.good.Name
+}
+
+func (g *goodResolver) Description() string {
+	return g.good.Description
+}
+
+func (g *goodResolver) Priority() int32 {
+	return int32(g.good.Priority)
+}
+
+func (g *goodResolver) Removed() bool {
+	return g.good.Removed
+}
+
+func (g *goodResolver) CreatedAt() gql.Time {
+	return gql.Time{Time: g.good.CreatedAt}
+}
+
+func (g *goodResolver) Version() int32 {
+	return int32(g.good.Version)
+}
+
+// History is the latest changes of the good, also those made before it
+// moved to its project. Changes made in projects the caller cannot read
+// are left out, so fewer than first may be returned.
+func (g *goodResolver) History(ctx context.Context, args struct{ First int32 }) ([]*historyEventResolver, error) {
+	events, err := loadHistory(ctx, loadersFrom(ctx).goodHistory, g.good.ID, args.First)
+	if err != nil {
+		return nil, err
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, problemError(problem.New(problem.CodeUnauthorized, ""))
+	}
+
+	return slices.DeleteFunc(events, func(h *historyEventResolver) bool {
+		return !principal.Can(h.event.ProjectID, auth.ScopeRead)
+	}), nil
+}
+
+type historyEventResolver struct {
+	event models.HistoryEvent
+}
+
+func (h *historyEventResolver) Type() string {
+	return h.event.Type
+}
+
+func (h *historyEventResolver) Actor() string {
+	return h.event.Actor
+}
+
+func (h *historyEventResolver) At() gql.Time {
+	return gql.Time{Time: h.event.Time}
+}
+
+func (h *historyEventResolver) GoodID() gql.ID {
+	return gql.ID(strconv.Itoa(h.event.ID))
+}
+
+func (h *historyEventResolver) ProjectID() gql.ID {
+	return gql.ID(strconv.Itoa(h.event.ProjectID))
+}
+
+func (h *historyEventResolver) FromProjectID() *gql.ID {
+	if h.event.FromProjectID == 0 {
+		return nil
+	}
+
+	id := gql.ID(strconv.Itoa(h.event.FromProjectID))
+
+	return &id
+}
+
+func (h *historyEventResolver) Name() string {
+	return h.event.Name
+}
+
+func (h *historyEventResolver) Description() string {
+	return h.event.Description
+}
+
+func (h *historyEventResolver) Priority() int32 {
+	return int32(h.event.Priority)
+}
+
+func (h *historyEventResolver) Removed() bool {
+	return h.event.Removed
+}
+
+func loadHistory(
+	ctx context.Context,
+	loader *dataloader.Loader[historyKey, []models.HistoryEvent],
+	id int,
+	first int32,
+) ([]*historyEventResolver, error) {
+	if first < 0 || first > maxFirst {
+		return nil, problemError(problem.New(problem.CodeInvalidRequest, fmt.Sprintf("first must be between 0 and %d", maxFirst)))
+	}
+
+	events, err := loader.Load(ctx, historyKey{id: id, first: int(first)})
+	if errors.Is(err, errHistoryDisabled) {
+		return nil, problemError(problem.New(problem.CodeInternal, err.Error()))
+	}
+
+	if err != nil {
+		return nil, problemError(problem.FromError(err))
+	}
+
+	res := make([]*historyEventResolver, 0, len(events))
+	for _, event := range events {
+		res = append(res, &historyEventResolver{event: event})
+	}
+
+	return res, nil
+}
+
+// authorize parses a project id and checks that the caller holds the
+// scope on the project.
+func authorize(ctx context.Context, id gql.ID, scope auth.Scope) (int, error) {
+	projectID, err := request.ParseID(string(id))
+	if err != nil {
+		return 0, problemError(problem.New(problem.CodeInvalidRequest, "ids must be positive integers"))
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return 0, problemError(problem.New(problem.CodeUnauthorized, ""))
+	}
+
+	if !principal.Can(projectID, scope) {
+		return 0, problemError(problem.New(problem.CodeForbidden, ""))
+	}
+
+	return projectID, nil
+}
+
+// authorizeString is authorize returning the id in the form the storage
+// expects.
+func authorizeString(ctx context.Context, id gql.ID, scope auth.Scope) (string, error) {
+	projectID, err := authorize(ctx, id, scope)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(projectID), nil
+}
+
+func goodArgs(ctx context.Context, projectID, id gql.ID, scope auth.Scope) (string, string, error) {
+	project, err := authorizeString(ctx, projectID, scope)
+	if err != nil {
+		return "", "", err
+	}
+
+	good, err := request.ParseID(string(id))
+	if err != nil {
+		return "", "", problemError(problem.New(problem.CodeInvalidRequest, "ids must be positive integers"))
+	}
+
+	return project, strconv.Itoa(good), nil
+}
+
+func validate(req any) error {
+	err := validator.New().Struct(req)
+	if err == nil {
+		return nil
+	}
+
+	var validateErr validator.ValidationErrors
+	errors.As(err, &validateErr)
+
+	return problemError(problem.Validation(validateErr))
+}
+
+// encodeCursor returns the opaque position of the good in the order of
+// Project.goods.
+func encodeCursor(good models.Good) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d", good.Priority, good.ID))
+}
+
+func decodeCursor(cursor string) (models.GoodCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return models.GoodCursor{}, err
+	}
+
+	priority, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return models.GoodCursor{}, errors.New("malformed cursor")
+	}
+
+	var c models.GoodCursor
+
+	if c.Priority, err = strconv.Atoi(priority); err != nil {
+		return models.GoodCursor{}, err
+	}
+
+	if c.ID, err = strconv.Atoi(id); err != nil {
+		return models.GoodCursor{}, err
+	}
+
+	return c, nil
+}
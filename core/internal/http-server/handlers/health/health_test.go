@@ -0,0 +1,57 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/health"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/stretchr/testify/require"
+)
+
+type pinger struct {
+	err error
+}
+
+func (p *pinger) Ping(context.Context) error {
+	return p.err
+}
+
+func TestReady(t *testing.T) {
+	redis := &pinger{}
+
+	probes := health.New(slogdiscard.NewDiscardLogger(), map[string]health.Pinger{
+		"postgres": &pinger{},
+		"redis":    redis,
+	})
+
+	ready := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		probes.Ready(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		return rr
+	}
+
+	rr := ready()
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"status":"ok","checks":{"postgres":"ok","redis":"ok"}}`, rr.Body.String())
+
+	redis.err = errors.New("connection refused")
+
+	rr = ready()
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.JSONEq(t, `{"status":"unavailable","checks":{"postgres":"ok","redis":"unavailable"}}`, rr.Body.String())
+
+	redis.err = nil
+	probes.Shutdown()
+
+	rr = ready()
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code, "not ready once shutdown has started")
+
+	rr = httptest.NewRecorder()
+	probes.Live(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rr.Code, "still alive while shutting down")
+}
@@ -2,11 +2,17 @@ package list
 
 import (
 	"context"
-	resp "github.com/Gonnekone/hezzl-test/core/internal/lib/api/response"
+	"encoding/json"
+	"fmt"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/etag"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
 	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/metrics"
 	"github.com/Gonnekone/hezzl-test/core/internal/models"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
+	"golang.org/x/sync/singleflight"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -15,15 +21,38 @@ import (
 const limitDefault = "10"
 const offsetDefault = "1"
 
-//go:generate go run github.com/vektra/mockery/v3@v3.4.0 --name=GoodLister
+// LimitMax is the largest page of goods.
+const LimitMax = 100
+
+var errLimitOffset = fmt.Errorf("limit must be between 1 and %d, offset must not be negative", LimitMax)
+
 type GoodLister interface {
 	ListGoods(
 		ctx context.Context,
+		projectID string,
 		limit int,
 		offset int,
 	) (*GoodListResponse, error)
-	GetCachedList(ctx context.Context, limit, offset int) ([]byte, error)
-	SaveListInCache(ctx context.Context, list GoodListResponse) error
+	GetCachedList(ctx context.Context, projectID string, limit, offset int) (*CachedList, error)
+	SaveListInCache(ctx context.Context, projectID string, version int64, page Page) error
+}
+
+// Page is a serialized list page. The same bytes are cached and sent to
+// clients, so ETag stays valid for both.
+type Page struct {
+	Limit  int
+	Offset int
+	Data   []byte
+	ETag   string
+}
+
+// CachedList is a list page looked up in cache. Data is nil on a cache miss,
+// Stale is set once the page has outlived its soft TTL.
+type CachedList struct {
+	Version int64
+	Data    []byte
+	ETag    string
+	Stale   bool
 }
 
 type GoodListResponse struct {
@@ -38,55 +67,181 @@ type GoodMetaListResponse struct {
 	Offset  int `json:"offset"`
 }
 
-func New(log *slog.Logger, goodLister GoodLister) http.HandlerFunc {
+func NewPage(list GoodListResponse) (*Page, error) {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil, fmt.Errorf("marshal list: %w", err)
+	}
+
+	return &Page{
+		Limit:  list.Meta.Limit,
+		Offset: list.Meta.Offset,
+		Data:   data,
+		ETag:   etag.Compute(data),
+	}, nil
+}
+
+// Reader reads pages through the cache. Concurrent cache misses of the
+// same page are coalesced into a single ListGoods call. It is shared by the
+// HTTP and gRPC transports.
+type Reader struct {
+	goodLister GoodLister
+	group      singleflight.Group
+}
+
+func NewReader(goodLister GoodLister) *Reader {
+	return &Reader{goodLister: goodLister}
+}
+
+// Read returns the cached page, or reads it from the main storage and
+// caches it. With allowStale a stale page is returned immediately while it
+// is refreshed in the background, otherwise it is read again. Cache errors
+// are only logged.
+func (rd *Reader) Read(
+	ctx context.Context,
+	log *slog.Logger,
+	projectID string,
+	limit, offset int,
+	allowStale bool,
+) (*Page, error) {
+	cached, err := rd.goodLister.GetCachedList(ctx, projectID, limit, offset)
+	if err != nil {
+		log.Warn("failed to get cached list", sl.Err(err))
+	}
+
+	if cached != nil && cached.Data != nil && (!cached.Stale || allowStale) {
+		if cached.Stale {
+			metrics.CacheRequests.WithLabelValues("list", metrics.CacheStale).Inc()
+
+			log.Info("serving stale list, revalidating in background")
+
+			go func() {
+				if _, err := rd.load(ctx, log, projectID, limit, offset, cached); err != nil {
+					log.Warn("failed to revalidate list", sl.Err(err))
+				}
+			}()
+		} else {
+			metrics.CacheRequests.WithLabelValues("list", metrics.CacheHit).Inc()
+		}
+
+		return &Page{Limit: limit, Offset: offset, Data: cached.Data, ETag: cached.ETag}, nil
+	}
+
+	metrics.CacheRequests.WithLabelValues("list", metrics.CacheMiss).Inc()
+
+	return rd.load(ctx, log, projectID, limit, offset, cached)
+}
+
+// load reads the page from the main storage and caches it. The context is
+// detached, so a canceled caller does not fail the others waiting for the
+// same page.
+func (rd *Reader) load(
+	ctx context.Context,
+	log *slog.Logger,
+	projectID string,
+	limit, offset int,
+	cached *CachedList,
+) (*Page, error) {
+	key := fmt.Sprintf("%s:%d:%d", projectID, limit, offset)
+
+	res, err, _ := rd.group.Do(key, func() (any, error) {
+		ctx := context.WithoutCancel(ctx)
+
+		goods, err := rd.goodLister.ListGoods(ctx, projectID, limit, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := NewPage(*goods)
+		if err != nil {
+			return nil, err
+		}
+
+		if cached != nil {
+			err = rd.goodLister.SaveListInCache(ctx, projectID, cached.Version, *page)
+			if err != nil {
+				log.Warn("failed to cache list", sl.Err(err))
+			}
+		}
+
+		return page, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*Page), nil
+}
+
+// New returns the list handler. With staleWhileRevalidate a stale page is
+// served immediately while it is refreshed in the background.
+func New(log *slog.Logger, goodLister GoodLister, staleWhileRevalidate bool) http.HandlerFunc {
+	reader := NewReader(goodLister)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.list.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
 		)
 
 		limit, offset, err := retrieveLimitAndOffset(r)
 		if err != nil {
-			log.Error("failed to retrieve limit and offset", sl.Err(err))
+			log.Info("failed to retrieve limit and offset", sl.Err(err))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("failed to retrieve limit and offset"))
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, errLimitOffset.Error()))
 
 			return
 		}
 
-		if data, err := goodLister.GetCachedList(r.Context(), limit, offset); err == nil {
-			log.Info("goods listed from cache successfully")
+		// Without projectId goods of all projects are listed.
+		projectID := r.URL.Query().Get("projectId")
+		if projectID != "" {
+			if _, err := request.ParseID(projectID); err != nil {
+				log.Info("projectId is invalid", slog.String("project_id", projectID))
 
-			w.Header().Set("Content-Type", "application/json")
+				problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
 
-			//nolint: errcheck
-			w.Write(data)
-
-			return
+				return
+			}
 		}
 
-		goods, err := goodLister.ListGoods(r.Context(), limit, offset)
+		page, err := reader.Read(r.Context(), log, projectID, limit, offset, staleWhileRevalidate)
 		if err != nil {
 			log.Error("failed to list goods", sl.Err(err))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("failed to list goods"))
+			problem.Error(w, r, err)
 
 			return
 		}
 
-		err = goodLister.SaveListInCache(r.Context(), *goods)
-		if err != nil {
-			log.Warn("failed to cache list", sl.Err(err))
-		}
+		log.Info("goods listed successfully")
+
+		writePage(w, r, page)
+	}
+}
 
-		log.Info("goods listed from main storage successfully")
+// writePage writes the page or 304 Not Modified when the client already
+// has it. Clients may keep the page but must revalidate it on every use.
+func writePage(w http.ResponseWriter, r *http.Request, page *Page) {
+	w.Header().Set("Cache-Control", "private, no-cache")
 
-		render.JSON(w, r, goods)
+	if page.ETag != "" {
+		w.Header().Set("ETag", page.ETag)
 	}
+
+	if etag.Match(r.Header.Get("If-None-Match"), page.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	//nolint: errcheck
+	w.Write(page.Data)
 }
 
 func retrieveLimitAndOffset(r *http.Request) (int, int, error) {
@@ -110,5 +265,9 @@ func retrieveLimitAndOffset(r *http.Request) (int, int, error) {
 		return 0, 0, err
 	}
 
+	if limit < 1 || limit > LimitMax || offset < 0 {
+		return 0, 0, errLimitOffset
+	}
+
 	return limit, offset, nil
 }
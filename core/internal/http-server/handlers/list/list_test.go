@@ -0,0 +1,192 @@
+package list_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLister struct {
+	cached  *list.CachedList
+	release chan struct{}
+
+	listCalls atomic.Int32
+	saved     chan struct{}
+}
+
+func (f *fakeLister) ListGoods(_ context.Context, _ string, limit, offset int) (*list.GoodListResponse, error) {
+	f.listCalls.Add(1)
+
+	if f.release != nil {
+		<-f.release
+	}
+
+	return &list.GoodListResponse{Meta: list.GoodMetaListResponse{Limit: limit, Offset: offset}}, nil
+}
+
+func (f *fakeLister) GetCachedList(context.Context, string, int, int) (*list.CachedList, error) {
+	return f.cached, nil
+}
+
+func (f *fakeLister) SaveListInCache(context.Context, string, int64, list.Page) error {
+	if f.saved != nil {
+		f.saved <- struct{}{}
+	}
+
+	return nil
+}
+
+func TestConcurrentMissesAreCoalesced(t *testing.T) {
+	const requests = 20
+
+	lister := &fakeLister{
+		cached:  &list.CachedList{},
+		release: make(chan struct{}),
+	}
+	handler := list.New(slogdiscard.NewDiscardLogger(), lister, false)
+
+	var wg sync.WaitGroup
+
+	for range requests {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/goods/list", nil))
+			require.Equal(t, http.StatusOK, rr.Code)
+		}()
+	}
+
+	// let every request reach the in-flight load before it completes
+	require.Eventually(t, func() bool { return lister.listCalls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(lister.release)
+
+	wg.Wait()
+
+	require.Equal(t, int32(1), lister.listCalls.Load())
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	cases := []struct {
+		name                 string
+		staleWhileRevalidate bool
+		wantBody             string
+	}{
+		{
+			name:                 "Stale page served and refreshed",
+			staleWhileRevalidate: true,
+			wantBody:             `{"stale":true}`,
+		},
+		{
+			name:                 "Stale page reloaded synchronously",
+			staleWhileRevalidate: false,
+			wantBody:             `{"meta":{"total":0,"removed":0,"limit":10,"offset":1},"goods":null}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lister := &fakeLister{
+				cached: &list.CachedList{Data: []byte(`{"stale":true}`), Stale: true},
+				saved:  make(chan struct{}, 1),
+			}
+			handler := list.New(slogdiscard.NewDiscardLogger(), lister, tc.staleWhileRevalidate)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/goods/list", nil))
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.JSONEq(t, tc.wantBody, rr.Body.String())
+
+			select {
+			case <-lister.saved:
+			case <-time.After(time.Second):
+				t.Fatal("page was not refreshed")
+			}
+
+			require.Equal(t, int32(1), lister.listCalls.Load())
+		})
+	}
+}
+
+func TestConditionalRequest(t *testing.T) {
+	page, err := list.NewPage(list.GoodListResponse{})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "Matching ETag",
+			ifNoneMatch: page.ETag,
+			wantStatus:  http.StatusNotModified,
+		},
+		{
+			name:        "Outdated ETag",
+			ifNoneMatch: `"outdated"`,
+			wantStatus:  http.StatusOK,
+			wantBody:    string(page.Data),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lister := &fakeLister{
+				cached: &list.CachedList{Data: page.Data, ETag: page.ETag},
+			}
+			handler := list.New(slogdiscard.NewDiscardLogger(), lister, false)
+
+			req := httptest.NewRequest(http.MethodGet, "/goods/list", nil)
+			req.Header.Set("If-None-Match", tc.ifNoneMatch)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+			require.Equal(t, page.ETag, rr.Header().Get("ETag"))
+			require.Equal(t, "private, no-cache", rr.Header().Get("Cache-Control"))
+			require.Equal(t, tc.wantBody, rr.Body.String())
+		})
+	}
+}
+
+// TestInvalidParams checks the handler itself, requests reach it
+// unvalidated when openapi.validate_requests is off.
+func TestInvalidParams(t *testing.T) {
+	cases := []string{
+		"/goods/list?projectId=x",
+		"/goods/list?projectId=0",
+		"/goods/list?limit=0",
+		"/goods/list?limit=101",
+		"/goods/list?offset=-1",
+		"/goods/list?limit=ten",
+	}
+
+	for _, url := range cases {
+		t.Run(url, func(t *testing.T) {
+			lister := &fakeLister{}
+			handler := list.New(slogdiscard.NewDiscardLogger(), lister, false)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+
+			require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+			require.Contains(t, rr.Body.String(), `"code":"invalid_request"`)
+			require.Zero(t, lister.listCalls.Load())
+		})
+	}
+}
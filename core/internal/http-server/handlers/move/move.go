@@ -0,0 +1,124 @@
+package move
+
+import (
+	"context"
+	"errors"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// GoodMover is implemented by goods.Service.
+type GoodMover interface {
+	Move(
+		ctx context.Context,
+		id string,
+		projectID string,
+		targetProjectID string,
+	) (*models.Good, error)
+}
+
+type Request struct {
+	TargetProjectID int `json:"targetProjectId" validate:"required,min=1"`
+}
+
+// New handles POST /good/move. The route checks the write scope on the
+// project the good leaves, the handler checks it on the target project.
+// It answers with the good as it is in the target project.
+func New(
+	log *slog.Logger,
+	goodMover GoodMover,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.move.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		var req Request
+
+		err := request.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Decode(err))
+
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request_body", req))
+
+		id := r.URL.Query().Get("id")
+		if _, err := request.ParseID(id); err != nil {
+			log.Info("id is invalid", slog.String("id", id))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+			return
+		}
+
+		projectID := r.URL.Query().Get("projectId")
+		fromProjectID, err := request.ParseID(projectID)
+		if err != nil {
+			log.Info("projectId is invalid", slog.String("project_id", projectID))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+
+			problem.Render(w, r, problem.Validation(validateErr))
+
+			return
+		}
+
+		if req.TargetProjectID == fromProjectID {
+			log.Info("good is already in the target project")
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "targetProjectId must differ from projectId"))
+
+			return
+		}
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok || !principal.Can(req.TargetProjectID, auth.ScopeWrite) {
+			log.Info("no write access to the target project", slog.Int("target_project_id", req.TargetProjectID))
+
+			problem.Render(w, r, problem.New(problem.CodeForbidden, ""))
+
+			return
+		}
+
+		targetProjectID := strconv.Itoa(req.TargetProjectID)
+
+		good, err := goodMover.Move(r.Context(), id, projectID, targetProjectID)
+		if err != nil {
+			log.Error("failed to move good", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("good moved successfully", slog.Any("good", good), slog.String("from_project_id", projectID))
+
+		render.JSON(w, r, good)
+	}
+}
@@ -0,0 +1,113 @@
+package move_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/move"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStorage struct {
+	goodsService.Storage
+
+	moved        bool
+	invalidLists []string
+	invalidGoods []string
+}
+
+func (s *fakeStorage) MoveGood(_ context.Context, id, _, targetProjectID string) (*models.Good, error) {
+	s.moved = true
+
+	n, _ := strconv.Atoi(id)
+	target, _ := strconv.Atoi(targetProjectID)
+
+	return &models.Good{ID: n, ProjectID: target, Name: "Mango", Priority: 3, Version: 2}, nil
+}
+
+func (s *fakeStorage) InvalidList(_ context.Context, projectID string) error {
+	s.invalidLists = append(s.invalidLists, projectID)
+
+	return nil
+}
+
+func (s *fakeStorage) InvalidGoods(_ context.Context, goods ...models.Good) error {
+	for _, g := range goods {
+		s.invalidGoods = append(s.invalidGoods, strconv.Itoa(g.ProjectID)+"/"+strconv.Itoa(g.ID))
+	}
+
+	return nil
+}
+
+type fakeProducer struct {
+	sent [][]byte
+}
+
+func (p *fakeProducer) Send(_ context.Context, data []byte) error {
+	p.sent = append(p.sent, data)
+
+	return nil
+}
+
+func (p *fakeProducer) SendAsync(ctx context.Context, data []byte) error {
+	return p.Send(ctx, data)
+}
+
+func serve(t *testing.T, storage *fakeStorage, producer *fakeProducer, principal *auth.Principal, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/good/move?id=7&projectId=1", strings.NewReader(body))
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+
+	rr := httptest.NewRecorder()
+	log := slogdiscard.NewDiscardLogger()
+	move.New(log, goodsService.New(log, storage, producer)).ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestMovePublishesOldIdentity(t *testing.T) {
+	storage := &fakeStorage{}
+	producer := &fakeProducer{}
+
+	principal := &auth.Principal{Actor: "api_key:1", Projects: map[int]auth.Scope{1: auth.ScopeWrite, 2: auth.ScopeWrite}}
+
+	rr := serve(t, storage, producer, principal, `{"targetProjectId":2}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	require.Equal(t, []string{"1", "2"}, storage.invalidLists)
+	require.Equal(t, []string{"1/7", "2/7"}, storage.invalidGoods)
+
+	require.Len(t, producer.sent, 1)
+
+	var event models.GoodEvent
+	require.NoError(t, json.Unmarshal(producer.sent[0], &event))
+	require.Equal(t, models.GoodEvent{
+		Good:          models.Good{ID: 7, ProjectID: 2, Name: "Mango", Priority: 3, Version: 2},
+		Type:          models.EventMoved,
+		Actor:         "api_key:1",
+		FromProjectID: 1,
+	}, event)
+}
+
+func TestMoveRequiresWriteOnTarget(t *testing.T) {
+	storage := &fakeStorage{}
+	producer := &fakeProducer{}
+
+	principal := &auth.Principal{Actor: "api_key:1", Projects: map[int]auth.Scope{1: auth.ScopeWrite, 2: auth.ScopeRead}}
+
+	rr := serve(t, storage, producer, principal, `{"targetProjectId":2}`)
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	require.False(t, storage.moved)
+	require.Empty(t, producer.sent)
+}
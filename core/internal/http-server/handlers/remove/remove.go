@@ -2,29 +2,26 @@ package remove
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	resp "github.com/Gonnekone/hezzl-test/core/internal/lib/api/response"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
 	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
 	"github.com/Gonnekone/hezzl-test/core/internal/models"
-	"github.com/Gonnekone/hezzl-test/core/internal/producer"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/jackc/pgx/v5"
 	"log/slog"
 	"net/http"
 )
 
-const errCode = 3
-
+// GoodDeleter is implemented by goods.Service.
+//
 //go:generate go run github.com/vektra/mockery/v3@v3.4.0 --name=GoodDeleter
 type GoodDeleter interface {
-	DeleteGood(
+	Remove(
 		ctx context.Context,
 		id string,
 		projectID string,
 	) (*models.Good, error)
-	InvalidList(ctx context.Context) error
 }
 
 type Response struct {
@@ -33,89 +30,48 @@ type Response struct {
 	Removed   bool `json:"removed"`
 }
 
-type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Msg     string `json:"message"`
-	Details string `json:"details"`
-}
-
 func New(
 	log *slog.Logger,
 	goodDeleter GoodDeleter,
-	producer producer.ProducerInterface,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.remove.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
 		)
 
 		id := r.URL.Query().Get("id")
-		if id == "" {
-			log.Info("id is empty")
+		if _, err := request.ParseID(id); err != nil {
+			log.Info("id is invalid", slog.String("id", id))
 
-			render.Status(r, http.StatusNotFound)
-			render.JSON(w, r, resp.Error("invalid url params"))
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
 
 			return
 		}
 
 		projectID := r.URL.Query().Get("projectId")
-		if projectID == "" {
-			log.Info("projectId is empty")
+		if _, err := request.ParseID(projectID); err != nil {
+			log.Info("projectId is invalid", slog.String("project_id", projectID))
 
-			render.Status(r, http.StatusNotFound)
-			render.JSON(w, r, resp.Error("invalid url params"))
-
-			return
-		}
-
-		good, err := goodDeleter.DeleteGood(r.Context(), id, projectID)
-		if errors.Is(err, pgx.ErrNoRows) {
-			log.Error("failed to delete good", sl.Err(err))
-
-			render.Status(r, http.StatusNotFound)
-			render.JSON(w, r, ErrorResponse{
-				Code:    errCode,
-				Msg:     "errors.common.notFound",
-				Details: err.Error(),
-			})
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
 
 			return
 		}
 
+		good, err := goodDeleter.Remove(r.Context(), id, projectID)
 		if err != nil {
 			log.Error("failed to delete good", sl.Err(err))
 
-			render.Status(r, http.StatusNotFound)
-			render.JSON(w, r, resp.Error("failed to delete good"))
-
-			return
-		}
-
-		err = goodDeleter.InvalidList(r.Context())
-		if err != nil {
-			log.Error("failed to invalid cached list", sl.Err(err))
-
-			render.Status(r, http.StatusInternalServerError)
+			problem.Error(w, r, err)
 
 			return
 		}
 
 		log.Info("good deleted successfully")
 
-		data, err := json.Marshal(good)
-		if err != nil {
-			log.Warn("failed to marshal data", sl.Err(err))
-		}
-
-		err = producer.SendAsync(data)
-		if err != nil {
-			log.Warn("failed to send message to nats", sl.Err(err))
-		}
-
 		render.JSON(w, r, Response{
 			ID:        good.ID,
 			ProjectID: good.ProjectID,
@@ -0,0 +1,107 @@
+package remove_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/remove"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	producer_mocks "github.com/Gonnekone/hezzl-test/core/internal/producer/mocks"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/service/goods/mocks"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRemovePublishesRemovedGood(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	storageMock := mocks.NewMockStorage(ctrl)
+	producerMock := producer_mocks.NewMockProducerInterface(ctrl)
+
+	removed := models.Good{ID: 2, ProjectID: 1, Name: "Pear", Priority: 2, Removed: true, Version: 4}
+
+	storageMock.EXPECT().DeleteGood(gomock.Any(), "2", "1").Return(&removed, nil)
+	storageMock.EXPECT().InvalidGoods(gomock.Any(), removed).Return(nil)
+	storageMock.EXPECT().InvalidList(gomock.Any(), "1").Return(nil)
+
+	var sent []byte
+	producerMock.EXPECT().SendAsync(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, data []byte) error {
+		sent = data
+
+		return nil
+	})
+
+	log := slogdiscard.NewDiscardLogger()
+	handler := remove.New(log, goodsService.New(log, storageMock, producerMock))
+
+	req := httptest.NewRequest(http.MethodDelete, "/good/remove?id=2&projectId=1", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Actor: "api_key:1"}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.JSONEq(t, `{"id":2,"projectId":1,"removed":true}`, rr.Body.String())
+
+	var event models.GoodEvent
+	require.NoError(t, json.Unmarshal(sent, &event))
+	require.Equal(t, models.GoodEvent{Good: removed, Type: models.EventRemoved, Actor: "api_key:1"}, event)
+}
+
+func TestRemoveErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		// DeleteGood is only expected when deleteErr is set.
+		deleteErr error
+
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "Invalid id", query: "?id=0&projectId=1", wantStatus: http.StatusBadRequest, wantCode: "invalid_request"},
+		{name: "Missing projectId", query: "?id=2", wantStatus: http.StatusBadRequest, wantCode: "invalid_request"},
+		{
+			name:       "Unknown good",
+			query:      "?id=2&projectId=1",
+			deleteErr:  fmt.Errorf("delete good: %w", storageerr.ErrNotFound),
+			wantStatus: http.StatusNotFound,
+			wantCode:   "not_found",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+
+			storageMock := mocks.NewMockStorage(ctrl)
+			producerMock := producer_mocks.NewMockProducerInterface(ctrl)
+
+			if tc.deleteErr != nil {
+				storageMock.EXPECT().DeleteGood(gomock.Any(), "2", "1").Return(nil, tc.deleteErr)
+			}
+
+			log := slogdiscard.NewDiscardLogger()
+			handler := remove.New(log, goodsService.New(log, storageMock, producerMock))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/good/remove"+tc.query, nil))
+
+			require.Equal(t, tc.wantStatus, rr.Code, rr.Body.String())
+
+			var resp struct {
+				Code string `json:"code"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
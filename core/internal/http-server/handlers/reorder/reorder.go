@@ -0,0 +1,397 @@
+// Package reorder serves the WebSocket channel of a project that drag and
+// drop reordering uses. Every subscriber gets the changes of the order of
+// the project, whoever made them, and may move goods over the same socket.
+package reorder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Gonnekone/hezzl-test/core/internal/events"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/websocket"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// readLimit is the largest command a client may send.
+	readLimit = 4 << 10
+	// writeWait is how long a message may take to reach the client.
+	writeWait = 10 * time.Second
+	// outbox is how many replies may wait for the writer.
+	outbox = 16
+)
+
+// Types of Message.
+const (
+	MessagePriorities = "priorities"
+	MessageAck        = "ack"
+	MessageError      = "error"
+	// MessageReset tells the client to reload the goods of the project: a
+	// good was removed or moved to another project, or the channel fell
+	// behind and is about to close.
+	MessageReset = "reset"
+)
+
+// CommandReorder moves a good to a new priority, like /good/reprioritize.
+const CommandReorder = "reorder"
+
+// GoodMover is implemented by goods.Service.
+type GoodMover interface {
+	ReprioritizeIfVersion(
+		ctx context.Context,
+		id string,
+		projectID string,
+		priority int,
+		version int,
+	) ([]models.Good, error)
+}
+
+// GoodGetter looks up the good a rejected move was about.
+type GoodGetter interface {
+	GetGood(ctx context.Context, id string, projectID string) (*models.Good, error)
+}
+
+type Subscriber interface {
+	Subscribe(ctx context.Context, after uint64) (<-chan events.Event, error)
+}
+
+// Command is sent by the client. Version is the version of the good the
+// client saw, the move is rejected when the good has changed since.
+type Command struct {
+	Type        string `json:"type" validate:"required,oneof=reorder"`
+	RequestID   string `json:"requestId,omitempty"`
+	ID          int    `json:"id" validate:"required,min=1"`
+	NewPriority int    `json:"newPriority" validate:"required,min=1"`
+	Version     int    `json:"version" validate:"required,min=1"`
+}
+
+type Priority struct {
+	ID       int `json:"id"`
+	Priority int `json:"priority"`
+	Version  int `json:"version"`
+}
+
+// Message is sent by the server. Replies to a command carry its
+// requestId. Current is the good as it is now when a move was rejected
+// with version_conflict.
+type Message struct {
+	Type       string           `json:"type"`
+	RequestID  string           `json:"requestId,omitempty"`
+	Priorities []Priority       `json:"priorities,omitempty"`
+	Problem    *problem.Problem `json:"problem,omitempty"`
+	Current    *Priority        `json:"current,omitempty"`
+}
+
+// New upgrades the request to a WebSocket of the project given by the
+// projectId query parameter. The connection is pinged every heartbeat and
+// closed when the client stops answering. Browsers may open it from the
+// host of the API or from allowedOrigins.
+func New(
+	log *slog.Logger,
+	goodMover GoodMover,
+	goodGetter GoodGetter,
+	subscriber Subscriber,
+	heartbeat time.Duration,
+	allowedOrigins []string,
+) http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: checkOrigin(allowedOrigins),
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			code := problem.CodeInvalidRequest
+			if status == http.StatusForbidden {
+				code = problem.CodeForbidden
+			}
+
+			problem.Render(w, r, problem.New(code, reason.Error()))
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.reorder.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		projectID, err := request.ParseID(r.URL.Query().Get("projectId"))
+		if err != nil {
+			log.Info("projectId is invalid", sl.Err(err))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+			return
+		}
+
+		if !websocket.IsWebSocketUpgrade(r) {
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "expected a websocket upgrade"))
+
+			return
+		}
+
+		// The request context is not canceled when a hijacked connection
+		// closes, the reader cancels ctx instead.
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		ch, err := subscriber.Subscribe(ctx, 0)
+		if err != nil {
+			log.Error("failed to subscribe to events", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Info("failed to upgrade connection", sl.Err(err))
+
+			return
+		}
+		//nolint: errcheck
+		defer conn.Close()
+
+		log.Info("reorder channel opened", slog.Int("project_id", projectID))
+
+		s := &session{
+			log:        log,
+			goodMover:  goodMover,
+			goodGetter: goodGetter,
+			projectID:  projectID,
+			conn:       conn,
+			out:        make(chan Message, outbox),
+		}
+
+		go s.read(ctx, cancel, heartbeat)
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			var err error
+
+			select {
+			case <-ctx.Done():
+				log.Info("reorder channel closed")
+
+				//nolint: errcheck
+				conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+					time.Now().Add(writeWait),
+				)
+
+				return
+
+			case <-ticker.C:
+				err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+
+			case ev, ok := <-ch:
+				if !ok {
+					log.Info("reorder channel fell behind, closing channel")
+
+					//nolint: errcheck
+					s.write(Message{Type: MessageReset})
+
+					return
+				}
+
+				msg, ok := s.message(ev)
+				if ok {
+					err = s.write(msg)
+				}
+
+			case msg := <-s.out:
+				err = s.write(msg)
+			}
+
+			if err != nil {
+				log.Info("failed to write message, closing channel", sl.Err(err))
+
+				return
+			}
+		}
+	}
+}
+
+type session struct {
+	log        *slog.Logger
+	goodMover  GoodMover
+	goodGetter GoodGetter
+	projectID  int
+	conn       *websocket.Conn
+	out        chan Message
+}
+
+func (s *session) write(msg Message) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+
+	return s.conn.WriteJSON(msg)
+}
+
+// read handles the commands of the client one at a time, so they apply in
+// the order they were sent. It cancels the session when the client leaves
+// or misses two pings.
+func (s *session) read(ctx context.Context, cancel context.CancelFunc, heartbeat time.Duration) {
+	defer cancel()
+
+	s.conn.SetReadLimit(readLimit)
+
+	deadline := func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	}
+	//nolint: errcheck
+	deadline("")
+	s.conn.SetPongHandler(deadline)
+
+	for {
+		_, r, err := s.conn.NextReader()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.log.Info("failed to read command", sl.Err(err))
+			}
+
+			return
+		}
+
+		var cmd Command
+
+		var msg Message
+		if err := request.DecodeJSON(r, &cmd); err != nil {
+			msg = failure("", problem.Decode(err))
+		} else {
+			msg = s.reorder(ctx, cmd)
+		}
+
+		select {
+		case s.out <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *session) reorder(ctx context.Context, cmd Command) Message {
+	log := s.log.With(
+		slog.String("client_request_id", cmd.RequestID),
+		slog.Int("id", cmd.ID),
+	)
+
+	if err := validator.New().Struct(cmd); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+
+		log.Info("invalid command", sl.Err(err))
+
+		return failure(cmd.RequestID, problem.Validation(validateErr))
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok || !principal.Can(s.projectID, auth.ScopeWrite) {
+		return failure(cmd.RequestID, problem.New(problem.CodeForbidden, ""))
+	}
+
+	id := strconv.Itoa(cmd.ID)
+	projectID := strconv.Itoa(s.projectID)
+
+	goods, err := s.goodMover.ReprioritizeIfVersion(ctx, id, projectID, cmd.NewPriority, cmd.Version)
+	if errors.Is(err, storageerr.ErrVersionConflict) {
+		log.Info("good was changed by another move", sl.Err(err))
+
+		msg := failure(cmd.RequestID, problem.FromError(err))
+
+		good, err := s.goodGetter.GetGood(ctx, id, projectID)
+		if err != nil {
+			log.Warn("failed to get current good", sl.Err(err))
+		} else {
+			msg.Current = &Priority{ID: good.ID, Priority: good.Priority, Version: good.Version}
+		}
+
+		return msg
+	}
+	if err != nil {
+		log.Error("failed to update priority", sl.Err(err))
+
+		return failure(cmd.RequestID, problem.FromError(err))
+	}
+
+	log.Info("priority updated successfully")
+
+	msg := Message{Type: MessageAck, RequestID: cmd.RequestID, Priorities: make([]Priority, 0, len(goods))}
+
+	for _, good := range goods {
+		msg.Priorities = append(msg.Priorities, Priority{ID: good.ID, Priority: good.Priority, Version: good.Version})
+	}
+
+	return msg
+}
+
+// message turns a change of the order of the project into a message.
+// Changes made through any API and on any core instance arrive here. A
+// good created, moved within or moved into the project comes with its
+// priority. A removed good and one that left the project reset the client,
+// which cannot tell the priorities of the goods left behind otherwise.
+func (s *session) message(ev events.Event) (Message, bool) {
+	switch {
+	case ev.Type == models.EventMoved && ev.FromProjectID == s.projectID:
+		return Message{Type: MessageReset}, true
+	case ev.ProjectID != s.projectID:
+		return Message{}, false
+	case ev.Type == models.EventRemoved:
+		return Message{Type: MessageReset}, true
+	case ev.Type != models.EventCreated && ev.Type != models.EventReprioritized && ev.Type != models.EventMoved:
+		return Message{}, false
+	}
+
+	var good models.GoodEvent
+	if err := json.Unmarshal(ev.Data, &good); err != nil {
+		s.log.Warn("failed to unmarshal event", sl.Err(err))
+
+		return Message{}, false
+	}
+
+	return Message{
+		Type:       MessagePriorities,
+		Priorities: []Priority{{ID: good.ID, Priority: good.Priority, Version: good.Version}},
+	}, true
+}
+
+// checkOrigin lets through clients other than browsers, which send no
+// Origin, and pages served from the host of the API or from allowed.
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+
+		return strings.EqualFold(u.Host, r.Host) || slices.Contains(allowed, origin)
+	}
+}
+
+func failure(requestID string, p *problem.Problem) Message {
+	return Message{Type: MessageError, RequestID: requestID, Problem: p}
+}
@@ -0,0 +1,273 @@
+package reorder_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/events"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reorder"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage holds goods 1 and 2 of project 1 at priorities 1 and 2,
+// moving a good shifts the goods at or after its new priority.
+type fakeStorage struct {
+	goodsService.Storage
+
+	mu    sync.Mutex
+	goods map[int]*models.Good
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{goods: map[int]*models.Good{
+		1: {ID: 1, ProjectID: 1, Priority: 1, Version: 1},
+		2: {ID: 2, ProjectID: 1, Priority: 2, Version: 1},
+	}}
+}
+
+func (s *fakeStorage) UpdateGoodsPriorityIfVersion(_ context.Context, id, _ string, priority, version int) ([]models.Good, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, _ := strconv.Atoi(id)
+
+	good, ok := s.goods[n]
+	if !ok {
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrNotFound)
+	}
+
+	if good.Version != version {
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrVersionConflict)
+	}
+
+	good.Priority = priority
+	good.Version++
+
+	changed := []models.Good{*good}
+
+	for _, g := range s.goods {
+		if g.ID != good.ID && g.Priority >= priority {
+			g.Priority++
+			g.Version++
+
+			changed = append(changed, *g)
+		}
+	}
+
+	return changed, nil
+}
+
+func (s *fakeStorage) GetGood(_ context.Context, id, _ string) (*models.Good, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, _ := strconv.Atoi(id)
+	good := *s.goods[n]
+
+	return &good, nil
+}
+
+func (*fakeStorage) InvalidList(context.Context, string) error          { return nil }
+func (*fakeStorage) InvalidGoods(context.Context, ...models.Good) error { return nil }
+
+type fakeSubscriber struct {
+	ch chan events.Event
+}
+
+func (s fakeSubscriber) Subscribe(context.Context, uint64) (<-chan events.Event, error) {
+	return s.ch, nil
+}
+
+type fakeProducer struct{}
+
+func (fakeProducer) Send(context.Context, []byte) error      { return nil }
+func (fakeProducer) SendAsync(context.Context, []byte) error { return nil }
+
+func dial(t *testing.T, storage *fakeStorage, subscriber fakeSubscriber, principal *auth.Principal) *websocket.Conn {
+	t.Helper()
+
+	log := slogdiscard.NewDiscardLogger()
+	handler := reorder.New(log, goodsService.New(log, storage, fakeProducer{}), storage, subscriber, time.Minute, nil)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?projectId=1", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) //nolint: errcheck
+
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, cmd reorder.Command) reorder.Message {
+	t.Helper()
+
+	require.NoError(t, conn.WriteJSON(cmd))
+
+	var msg reorder.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+
+	return msg
+}
+
+var writer = &auth.Principal{Actor: "api_key:1", Projects: map[int]auth.Scope{1: auth.ScopeWrite}}
+
+func TestConflictingMoves(t *testing.T) {
+	storage := newFakeStorage()
+	subscriber := fakeSubscriber{ch: make(chan events.Event)}
+
+	alice := dial(t, storage, subscriber, writer)
+	bob := dial(t, storage, subscriber, writer)
+
+	// Both saw good 2 at version 1 and move it, the first move wins.
+	msg := send(t, alice, reorder.Command{Type: reorder.CommandReorder, RequestID: "a", ID: 2, NewPriority: 1, Version: 1})
+	require.Equal(t, reorder.MessageAck, msg.Type)
+	require.Equal(t, "a", msg.RequestID)
+	require.ElementsMatch(t, []reorder.Priority{{ID: 2, Priority: 1, Version: 2}, {ID: 1, Priority: 2, Version: 2}}, msg.Priorities)
+
+	msg = send(t, bob, reorder.Command{Type: reorder.CommandReorder, RequestID: "b", ID: 2, NewPriority: 3, Version: 1})
+	require.Equal(t, reorder.MessageError, msg.Type)
+	require.Equal(t, "b", msg.RequestID)
+	require.Equal(t, problem.CodeVersionConflict, msg.Problem.Code)
+	require.Equal(t, &reorder.Priority{ID: 2, Priority: 1, Version: 2}, msg.Current)
+
+	// Bob retries from the current version.
+	msg = send(t, bob, reorder.Command{Type: reorder.CommandReorder, RequestID: "b", ID: 2, NewPriority: 3, Version: 2})
+	require.Equal(t, reorder.MessageAck, msg.Type)
+}
+
+func TestRejectedCommands(t *testing.T) {
+	reader := &auth.Principal{Actor: "api_key:2", Projects: map[int]auth.Scope{1: auth.ScopeRead}}
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		cmd       string
+		code      problem.Code
+	}{
+		{name: "read scope", principal: reader, cmd: `{"type":"reorder","id":1,"newPriority":2,"version":1}`, code: problem.CodeForbidden},
+		{name: "unknown type", principal: writer, cmd: `{"type":"move","id":1,"newPriority":2,"version":1}`, code: problem.CodeValidationFailed},
+		{name: "without version", principal: writer, cmd: `{"type":"reorder","id":1,"newPriority":2}`, code: problem.CodeValidationFailed},
+		{name: "unknown field", principal: writer, cmd: `{"type":"reorder","id":1,"newPriority":2,"version":1,"after":3}`, code: problem.CodeInvalidRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := dial(t, newFakeStorage(), fakeSubscriber{ch: make(chan events.Event)}, tc.principal)
+
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(tc.cmd)))
+
+			var msg reorder.Message
+			require.NoError(t, conn.ReadJSON(&msg))
+			require.Equal(t, reorder.MessageError, msg.Type)
+			require.Equal(t, tc.code, msg.Problem.Code)
+		})
+	}
+}
+
+func TestPushesOrderChangesOfProject(t *testing.T) {
+	subscriber := fakeSubscriber{ch: make(chan events.Event, 7)}
+	subscriber.ch <- events.Event{ID: 1, Type: models.EventReprioritized, ProjectID: 2, Data: []byte(`{"id":5,"priority":1,"version":2}`)}
+	subscriber.ch <- events.Event{ID: 2, Type: models.EventUpdated, ProjectID: 1, Data: []byte(`{"id":1,"priority":1,"version":2}`)}
+	subscriber.ch <- events.Event{ID: 3, Type: models.EventReprioritized, ProjectID: 1, Data: []byte(`{"id":2,"priority":4,"version":3}`)}
+	subscriber.ch <- events.Event{ID: 4, Type: models.EventCreated, ProjectID: 1, Data: []byte(`{"id":3,"priority":5,"version":1}`)}
+	subscriber.ch <- events.Event{ID: 5, Type: models.EventMoved, ProjectID: 1, FromProjectID: 2, Data: []byte(`{"id":5,"priority":6,"version":3}`)}
+	subscriber.ch <- events.Event{ID: 6, Type: models.EventRemoved, ProjectID: 1, Data: []byte(`{"id":1,"priority":1,"version":3}`)}
+	subscriber.ch <- events.Event{ID: 7, Type: models.EventMoved, ProjectID: 2, FromProjectID: 1, Data: []byte(`{"id":2,"priority":2,"version":4}`)}
+
+	conn := dial(t, newFakeStorage(), subscriber, writer)
+
+	want := []reorder.Message{
+		{Type: reorder.MessagePriorities, Priorities: []reorder.Priority{{ID: 2, Priority: 4, Version: 3}}},
+		{Type: reorder.MessagePriorities, Priorities: []reorder.Priority{{ID: 3, Priority: 5, Version: 1}}},
+		{Type: reorder.MessagePriorities, Priorities: []reorder.Priority{{ID: 5, Priority: 6, Version: 3}}},
+		{Type: reorder.MessageReset},
+		{Type: reorder.MessageReset},
+	}
+
+	for _, w := range want {
+		var msg reorder.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		require.Equal(t, w, msg)
+	}
+}
+
+func TestResetsWhenFallingBehind(t *testing.T) {
+	subscriber := fakeSubscriber{ch: make(chan events.Event)}
+	close(subscriber.ch)
+
+	conn := dial(t, newFakeStorage(), subscriber, writer)
+
+	var msg reorder.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Equal(t, reorder.Message{Type: reorder.MessageReset}, msg)
+
+	_, _, err := conn.ReadMessage()
+	require.Error(t, err)
+}
+
+func TestOrigin(t *testing.T) {
+	log := slogdiscard.NewDiscardLogger()
+	storage := newFakeStorage()
+	handler := reorder.New(
+		log,
+		goodsService.New(log, storage, fakeProducer{}),
+		storage,
+		fakeSubscriber{ch: make(chan events.Event)},
+		time.Minute,
+		[]string{"https://admin.example.com"},
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r.WithContext(auth.WithPrincipal(r.Context(), writer)))
+	}))
+	t.Cleanup(srv.Close)
+
+	cases := []struct {
+		name   string
+		origin string
+		status int
+	}{
+		{name: "no origin", status: http.StatusSwitchingProtocols},
+		{name: "same host", origin: srv.URL, status: http.StatusSwitchingProtocols},
+		{name: "allowed", origin: "https://admin.example.com", status: http.StatusSwitchingProtocols},
+		{name: "other", origin: "https://evil.example.com", status: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.origin != "" {
+				header.Set("Origin", tc.origin)
+			}
+
+			conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?projectId=1", header)
+			if conn != nil {
+				conn.Close() //nolint: errcheck
+			}
+
+			if tc.status != http.StatusSwitchingProtocols {
+				require.Error(t, err)
+			}
+
+			require.Equal(t, tc.status, resp.StatusCode)
+			resp.Body.Close() //nolint: errcheck
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package reprioritize_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	producer_mocks "github.com/Gonnekone/hezzl-test/core/internal/producer/mocks"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/service/goods/mocks"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReprioritizeListsShiftedGoods(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	storageMock := mocks.NewMockStorage(ctrl)
+	producerMock := producer_mocks.NewMockProducerInterface(ctrl)
+
+	// Good 3 moves up to the first position, goods 1 and 2 shift down.
+	changed := []models.Good{
+		{ID: 3, ProjectID: 1, Priority: 1, Version: 2},
+		{ID: 1, ProjectID: 1, Priority: 2, Version: 1},
+		{ID: 2, ProjectID: 1, Priority: 3, Version: 1},
+	}
+
+	storageMock.EXPECT().UpdateGoodsPriority(gomock.Any(), "3", "1", 1).Return(changed, nil)
+	storageMock.EXPECT().InvalidGoods(gomock.Any(), changed).Return(nil)
+	storageMock.EXPECT().InvalidList(gomock.Any(), "1").Return(nil)
+
+	var events []models.GoodEvent
+	producerMock.EXPECT().SendAsync(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, data []byte) error {
+		var event models.GoodEvent
+		require.NoError(t, json.Unmarshal(data, &event))
+
+		events = append(events, event)
+
+		return nil
+	}).Times(len(changed))
+
+	log := slogdiscard.NewDiscardLogger()
+	handler := reprioritize.New(log, goodsService.New(log, storageMock, producerMock))
+
+	req := httptest.NewRequest(http.MethodPatch, "/good/reprioritize?id=3&projectId=1", strings.NewReader(`{"newPriority":1}`))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.JSONEq(t, `{"priorities":[
+{"id":3,"priority":1},{"id":1,"priority":2},{"id":2,"priority":3}
+]}`, rr.Body.String())
+
+	require.Len(t, events, len(changed))
+	for i, event := range events {
+		require.Equal(t, models.EventReprioritized, event.Type)
+		require.Equal(t, changed[i], event.Good)
+	}
+}
+
+func TestReprioritizeErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		query   string
+		reqBody string
+		// UpdateGoodsPriority is only expected when updateErr is set.
+		updateErr error
+
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "Zero priority",
+			query:      "?id=3&projectId=1",
+			reqBody:    `{"newPriority":0}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_failed",
+		},
+		{
+			name:       "Negative priority",
+			query:      "?id=3&projectId=1",
+			reqBody:    `{"newPriority":-1}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_failed",
+		},
+		{
+			name:       "Unknown field",
+			query:      "?id=3&projectId=1",
+			reqBody:    `{"priority":1}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_request",
+		},
+		{
+			name:       "Missing id",
+			query:      "?projectId=1",
+			reqBody:    `{"newPriority":1}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_request",
+		},
+		{
+			name:       "Unknown good",
+			query:      "?id=3&projectId=1",
+			reqBody:    `{"newPriority":1}`,
+			updateErr:  fmt.Errorf("update priority: %w", storageerr.ErrNotFound),
+			wantStatus: http.StatusNotFound,
+			wantCode:   "not_found",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+
+			storageMock := mocks.NewMockStorage(ctrl)
+			producerMock := producer_mocks.NewMockProducerInterface(ctrl)
+
+			if tc.updateErr != nil {
+				storageMock.EXPECT().UpdateGoodsPriority(gomock.Any(), "3", "1", 1).Return(nil, tc.updateErr)
+			}
+
+			log := slogdiscard.NewDiscardLogger()
+			handler := reprioritize.New(log, goodsService.New(log, storageMock, producerMock))
+
+			req := httptest.NewRequest(http.MethodPatch, "/good/reprioritize"+tc.query, strings.NewReader(tc.reqBody))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code, rr.Body.String())
+
+			var resp struct {
+				Code string `json:"code"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package ticket
+
+import (
+	"context"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// TicketIssuer is implemented by auth.Tickets.
+type TicketIssuer interface {
+	Issue(ctx context.Context, p *auth.Principal) (string, error)
+	TTL() time.Duration
+}
+
+type Response struct {
+	Ticket string `json:"ticket"`
+	// ExpiresIn is how many seconds the ticket may be redeemed.
+	ExpiresIn int `json:"expiresIn"`
+}
+
+// New handles POST /auth/tickets. The ticket acts as the caller on the
+// event stream and the WebSocket, where browsers cannot send headers.
+func New(log *slog.Logger, ticketIssuer TicketIssuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.ticket.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			problem.Render(w, r, problem.New(problem.CodeUnauthorized, ""))
+
+			return
+		}
+
+		ticket, err := ticketIssuer.Issue(r.Context(), principal)
+		if err != nil {
+			log.Error("failed to issue ticket", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("ticket issued")
+
+		render.JSON(w, r, Response{Ticket: ticket, ExpiresIn: int(ticketIssuer.TTL().Seconds())})
+	}
+}
@@ -0,0 +1,73 @@
+package ticket_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/ticket"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps tickets in a map, taking them out like Redis GETDEL.
+type fakeStore map[string][]byte
+
+func (s fakeStore) SaveTicket(_ context.Context, ticketHash string, principal []byte, _ time.Duration) error {
+	s[ticketHash] = principal
+
+	return nil
+}
+
+func (s fakeStore) TakeTicket(_ context.Context, ticketHash string) ([]byte, error) {
+	principal, ok := s[ticketHash]
+	if !ok {
+		return nil, storageerr.ErrNotFound
+	}
+
+	delete(s, ticketHash)
+
+	return principal, nil
+}
+
+func TestTicketActsAsTheCallerOnce(t *testing.T) {
+	store := fakeStore{}
+	tickets := auth.NewTickets(store, 30*time.Second)
+
+	caller := &auth.Principal{Actor: "api_key:7", Projects: map[int]auth.Scope{1: auth.ScopeRead}}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/tickets", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), caller))
+
+	rr := httptest.NewRecorder()
+	ticket.New(slogdiscard.NewDiscardLogger(), tickets).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var resp ticket.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, 30, resp.ExpiresIn)
+
+	// Like API keys, only the hash of a ticket is stored.
+	require.NotContains(t, store, resp.Ticket)
+
+	principal, err := tickets.Redeem(context.Background(), resp.Ticket)
+	require.NoError(t, err)
+	require.Equal(t, caller, principal)
+
+	_, err = tickets.Redeem(context.Background(), resp.Ticket)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+}
+
+func TestTicketNeedsACaller(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/auth/tickets", nil)
+
+	rr := httptest.NewRecorder()
+	ticket.New(slogdiscard.NewDiscardLogger(), auth.NewTickets(fakeStore{}, time.Minute)).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
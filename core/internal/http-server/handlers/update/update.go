@@ -2,66 +2,62 @@ package update
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	resp "github.com/Gonnekone/hezzl-test/core/internal/lib/api/response"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
 	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
 	"github.com/Gonnekone/hezzl-test/core/internal/models"
-	"github.com/Gonnekone/hezzl-test/core/internal/producer"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
-	"github.com/jackc/pgx/v5"
 	"log/slog"
 	"net/http"
 )
 
-const errCode = 3
-
+// GoodUpdater is implemented by goods.Service.
+//
 //go:generate go run github.com/vektra/mockery/v3@v3.4.0 --name=GoodUpdater
 type GoodUpdater interface {
-	UpdateGood(
+	Update(
 		ctx context.Context,
 		id string,
 		projectID string,
 		name string,
 		desc string,
 	) (*models.Good, error)
-	InvalidList(ctx context.Context) error
 }
 
 type Request struct {
-	Name string `json:"name" validate:"required"`
-	Desc string `json:"description,omitempty"`
+	Name string `json:"name" validate:"required,max=255"`
+	Desc string `json:"description,omitempty" validate:"max=1000"`
 }
 
-type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Msg     string `json:"message"`
-	Details string `json:"details"`
+func (r *Request) Sanitize() {
+	r.Name = request.Text(r.Name)
+	r.Desc = request.Text(r.Desc)
 }
 
 func New(
 	log *slog.Logger,
 	goodUpdater GoodUpdater,
-	producer producer.ProducerInterface,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.update.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
 		)
 
 		var req Request
 
-		err := render.DecodeJSON(r.Body, &req)
+		err := request.DecodeJSON(r.Body, &req)
 		if err != nil {
 			log.Error("failed to decode request body", sl.Err(err))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("failed to decode request body"))
+			problem.Render(w, r, problem.Decode(err))
 
 			return
 		}
@@ -69,21 +65,19 @@ func New(
 		log.Info("request body decoded", slog.Any("request_body", req))
 
 		id := r.URL.Query().Get("id")
-		if id == "" {
-			log.Info("id is empty")
+		if _, err := request.ParseID(id); err != nil {
+			log.Info("id is invalid", slog.String("id", id))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("invalid url params"))
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
 
 			return
 		}
 
 		projectID := r.URL.Query().Get("projectId")
-		if projectID == "" {
-			log.Info("projectId is empty")
+		if _, err := request.ParseID(projectID); err != nil {
+			log.Info("projectId is invalid", slog.String("project_id", projectID))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("invalid url params"))
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
 
 			return
 		}
@@ -94,56 +88,22 @@ func New(
 
 			log.Error("invalid request", sl.Err(err))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.ValidationError(validateErr))
-
-			return
-		}
-
-		good, err := goodUpdater.UpdateGood(r.Context(), id, projectID, req.Name, req.Desc)
-		if errors.Is(err, pgx.ErrNoRows) {
-			log.Error("failed to delete good", sl.Err(err))
-
-			render.Status(r, http.StatusNotFound)
-			render.JSON(w, r, ErrorResponse{
-				Code:    errCode,
-				Msg:     "errors.common.notFound",
-				Details: err.Error(),
-			})
+			problem.Render(w, r, problem.Validation(validateErr))
 
 			return
 		}
 
+		good, err := goodUpdater.Update(r.Context(), id, projectID, req.Name, req.Desc)
 		if err != nil {
 			log.Error("failed to update good", sl.Err(err))
 
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("failed to update good"))
-
-			return
-		}
-
-		err = goodUpdater.InvalidList(r.Context())
-		if err != nil {
-			log.Error("failed to invalid cached list", sl.Err(err))
-
-			render.Status(r, http.StatusInternalServerError)
+			problem.Error(w, r, err)
 
 			return
 		}
 
 		log.Info("good added successfully", slog.Any("good", good))
 
-		data, err := json.Marshal(good)
-		if err != nil {
-			log.Warn("failed to marshal data", sl.Err(err))
-		}
-
-		err = producer.SendAsync(data)
-		if err != nil {
-			log.Warn("failed to send message to nats", sl.Err(err))
-		}
-
 		// здесь мы еще раз маршаллим, по хорошему просто передавать []byte
 		render.JSON(w, r, good)
 	}
@@ -0,0 +1,146 @@
+package update_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/update"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	producer_mocks "github.com/Gonnekone/hezzl-test/core/internal/producer/mocks"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/service/goods/mocks"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	updated := &models.Good{
+		ID:          2,
+		ProjectID:   1,
+		Name:        "Pear",
+		Description: "Green",
+		Priority:    2,
+		CreatedAt:   time.UnixMilli(1234567890),
+		Version:     3,
+	}
+
+	cases := []struct {
+		name    string
+		query   string
+		reqBody string
+		// UpdateGood is only expected when updated or updateErr is set.
+		updated   *models.Good
+		updateErr error
+
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:    "Success",
+			query:   "?id=2&projectId=1",
+			reqBody: `{"name":"Pear","description":"Green"}`,
+			updated: updated,
+			wantBody: `{
+"id":2,"projectId":1,"name":"Pear",
+"description":"Green","priority":2,
+"removed":false,"createdAt":"1970-01-15T09:56:07.89+03:00","version":3
+}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Empty name",
+			query:      "?id=2&projectId=1",
+			reqBody:    `{"name":""}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{
+"type":"urn:hezzl:problem:validation_failed","title":"Validation failed","status":400,
+"detail":"field Name is a required field","instance":"/good/update","code":"validation_failed"
+}`,
+		},
+		{
+			name:       "Invalid id",
+			query:      "?id=pear&projectId=1",
+			reqBody:    `{"name":"Pear"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{
+"type":"urn:hezzl:problem:invalid_request","title":"Invalid request","status":400,
+"detail":"invalid url params","instance":"/good/update","code":"invalid_request"
+}`,
+		},
+		{
+			name:       "Missing projectId",
+			query:      "?id=2",
+			reqBody:    `{"name":"Pear"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{
+"type":"urn:hezzl:problem:invalid_request","title":"Invalid request","status":400,
+"detail":"invalid url params","instance":"/good/update","code":"invalid_request"
+}`,
+		},
+		{
+			name:       "Unknown good",
+			query:      "?id=2&projectId=1",
+			reqBody:    `{"name":"Pear"}`,
+			updateErr:  fmt.Errorf("update good: %w", storageerr.ErrNotFound),
+			wantStatus: http.StatusNotFound,
+			wantBody: `{
+"type":"urn:hezzl:problem:not_found","title":"Resource not found","status":404,
+"instance":"/good/update","code":"not_found"
+}`,
+		},
+		{
+			name:       "UpdateGood error",
+			query:      "?id=2&projectId=1",
+			reqBody:    `{"name":"Pear"}`,
+			updateErr:  errors.New("storage error"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody: `{
+"type":"urn:hezzl:problem:internal","title":"Internal error","status":500,
+"instance":"/good/update","code":"internal"
+}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+
+			storageMock := mocks.NewMockStorage(ctrl)
+			producerMock := producer_mocks.NewMockProducerInterface(ctrl)
+
+			if tc.updated != nil || tc.updateErr != nil {
+				storageMock.EXPECT().
+					UpdateGood(gomock.Any(), "2", "1", "Pear", gomock.Any()).
+					Return(tc.updated, tc.updateErr).Times(1)
+			}
+
+			// The updated good and the lists of its project are dropped
+			// from the cache and the change is published.
+			if tc.updated != nil {
+				storageMock.EXPECT().InvalidGoods(gomock.Any(), *tc.updated).Return(nil).Times(1)
+				storageMock.EXPECT().InvalidList(gomock.Any(), "1").Return(nil).Times(1)
+				producerMock.EXPECT().SendAsync(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+			}
+
+			log := slogdiscard.NewDiscardLogger()
+			handler := update.New(log, goodsService.New(log, storageMock, producerMock))
+
+			req := httptest.NewRequest(http.MethodPatch, "/good/update"+tc.query, strings.NewReader(tc.reqBody))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code, "incorrect status code")
+			require.JSONEq(t, tc.wantBody, rr.Body.String(), "mismatch response body")
+		})
+	}
+}
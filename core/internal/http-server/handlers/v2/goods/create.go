@@ -0,0 +1,77 @@
+package goods
+
+import (
+	"errors"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/create"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+)
+
+// Create handles POST /v2/projects/{projectId}/goods.
+func Create(
+	log *slog.Logger,
+	goodCreator create.GoodCreator,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.v2.goods.Create"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		projectID, err := projectParam(r)
+		if err != nil {
+			log.Info("projectId is invalid", sl.Err(err))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, err.Error()))
+
+			return
+		}
+
+		var req create.Request
+
+		err = request.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Decode(err))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Validation(validateErr))
+
+			return
+		}
+
+		good, err := goodCreator.Create(r.Context(), req.Name, projectID)
+		if err != nil {
+			log.Error("failed to save good", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("good added", slog.Any("good", good))
+
+		w.Header().Set("Location", Location(good.ProjectID, good.ID))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, good)
+	}
+}
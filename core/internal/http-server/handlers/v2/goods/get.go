@@ -0,0 +1,47 @@
+package goods
+
+import (
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/get"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+// Get handles GET /v2/projects/{projectId}/goods/{id}.
+func Get(log *slog.Logger, goodGetter get.GoodGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.v2.goods.Get"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		id, projectID, err := goodParams(r)
+		if err != nil {
+			log.Info("url params are invalid", sl.Err(err))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, err.Error()))
+
+			return
+		}
+
+		good, err := get.Read(r.Context(), log, goodGetter, id, projectID)
+		if err != nil {
+			log.Error("failed to get good", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("good got successfully")
+
+		render.JSON(w, r, good)
+	}
+}
@@ -0,0 +1,42 @@
+// Package goods serves goods as a resource under
+// /v2/projects/{projectId}/goods/{id}. Unlike v1 it takes ids from the
+// path and answers 201 on create and 204 on delete.
+package goods
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+var errInvalidParams = errors.New("invalid url params")
+
+// Location is the URL of the good in the v2 API.
+func Location(projectID, id int) string {
+	return fmt.Sprintf("/v2/projects/%d/goods/%d", projectID, id)
+}
+
+func projectParam(r *http.Request) (string, error) {
+	projectID := chi.URLParam(r, "projectId")
+	if _, err := request.ParseID(projectID); err != nil {
+		return "", errInvalidParams
+	}
+
+	return projectID, nil
+}
+
+func goodParams(r *http.Request) (string, string, error) {
+	projectID, err := projectParam(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, err := request.ParseID(id); err != nil {
+		return "", "", errInvalidParams
+	}
+
+	return id, projectID, nil
+}
@@ -0,0 +1,307 @@
+package goods_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/v2/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage holds good 7 of project 1 and records the calls that reach
+// it as "method project/id".
+type fakeStorage struct {
+	goodsService.Storage
+
+	calls []string
+}
+
+func (s *fakeStorage) good(id, projectID string) (*models.Good, error) {
+	if id != "7" {
+		return nil, storageerr.ErrNotFound
+	}
+
+	project, _ := strconv.Atoi(projectID)
+
+	return &models.Good{ID: 7, ProjectID: project, Name: "Mango", Priority: 1, Version: 1}, nil
+}
+
+func (s *fakeStorage) SaveGood(_ context.Context, name, projectID string) (*models.Good, error) {
+	s.calls = append(s.calls, "SaveGood "+projectID)
+
+	project, _ := strconv.Atoi(projectID)
+
+	return &models.Good{ID: 8, ProjectID: project, Name: name, Priority: 2, Version: 1}, nil
+}
+
+func (s *fakeStorage) UpdateGood(_ context.Context, id, projectID, name, _ string) (*models.Good, error) {
+	s.calls = append(s.calls, "UpdateGood "+projectID+"/"+id)
+
+	good, err := s.good(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.Name = name
+	good.Version++
+
+	return good, nil
+}
+
+func (s *fakeStorage) DeleteGood(_ context.Context, id, projectID string) (*models.Good, error) {
+	s.calls = append(s.calls, "DeleteGood "+projectID+"/"+id)
+
+	good, err := s.good(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.Removed = true
+
+	return good, nil
+}
+
+func (s *fakeStorage) UpdateGoodsPriority(_ context.Context, id, projectID string, priority int) ([]models.Good, error) {
+	s.calls = append(s.calls, "UpdateGoodsPriority "+projectID+"/"+id)
+
+	good, err := s.good(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.Priority = priority
+
+	return []models.Good{*good, {ID: 8, ProjectID: good.ProjectID, Priority: 1}}, nil
+}
+
+func (s *fakeStorage) ReorderGoods(_ context.Context, projectID string, ids []int) ([]models.Good, error) {
+	s.calls = append(s.calls, "ReorderGoods "+projectID)
+
+	project, _ := strconv.Atoi(projectID)
+
+	changed := make([]models.Good, 0, len(ids))
+	for i, id := range ids {
+		changed = append(changed, models.Good{ID: id, ProjectID: project, Priority: i + 1})
+	}
+
+	return changed, nil
+}
+
+func (s *fakeStorage) MoveGood(_ context.Context, id, projectID, targetProjectID string) (*models.Good, error) {
+	s.calls = append(s.calls, "MoveGood "+projectID+"/"+id)
+
+	good, err := s.good(id, targetProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.Priority = 3
+
+	return good, nil
+}
+
+func (s *fakeStorage) GetGood(_ context.Context, id, projectID string) (*models.Good, error) {
+	s.calls = append(s.calls, "GetGood "+projectID+"/"+id)
+
+	return s.good(id, projectID)
+}
+
+func (s *fakeStorage) GetCachedGood(context.Context, string, string) (*models.CachedGood, error) {
+	return nil, nil
+}
+
+func (s *fakeStorage) SaveGoodInCache(context.Context, int64, models.Good) error { return nil }
+
+func (s *fakeStorage) InvalidList(context.Context, string) error { return nil }
+
+func (s *fakeStorage) InvalidGoods(context.Context, ...models.Good) error { return nil }
+
+type fakeProducer struct{}
+
+func (fakeProducer) Send(context.Context, []byte) error      { return nil }
+func (fakeProducer) SendAsync(context.Context, []byte) error { return nil }
+
+var writer = &auth.Principal{Actor: "api_key:1", Projects: map[int]auth.Scope{1: auth.ScopeWrite, 2: auth.ScopeWrite}}
+
+// serve routes the request like router.New does.
+func serve(t *testing.T, storage *fakeStorage, principal *auth.Principal, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	log := slogdiscard.NewDiscardLogger()
+	service := goodsService.New(log, storage, fakeProducer{})
+
+	r := chi.NewRouter()
+	r.Post("/v2/projects/{projectId}/goods", goods.Create(log, service))
+	r.Get("/v2/projects/{projectId}/goods/{id}", goods.Get(log, storage))
+	r.Patch("/v2/projects/{projectId}/goods/{id}", goods.Update(log, service))
+	r.Delete("/v2/projects/{projectId}/goods/{id}", goods.Remove(log, service))
+	r.Post("/v2/projects/{projectId}/goods/{id}:reprioritize", goods.Reprioritize(log, service))
+	r.Post("/v2/projects/{projectId}/goods:reorder", goods.Reorder(log, service))
+	r.Post("/v2/projects/{projectId}/goods/{id}:move", goods.Move(log, service))
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestGoods(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		target string
+		body   string
+
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+		wantCalls    []string
+	}{
+		{
+			name:         "create",
+			method:       http.MethodPost,
+			target:       "/v2/projects/1/goods",
+			body:         `{"name":"Kiwi"}`,
+			wantStatus:   http.StatusCreated,
+			wantLocation: "/v2/projects/1/goods/8",
+			wantBody: `{"id":8,"projectId":1,"name":"Kiwi","description":"","priority":2,
+"removed":false,"createdAt":"0001-01-01T00:00:00Z","version":1}`,
+			wantCalls: []string{"SaveGood 1"},
+		},
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			target:     "/v2/projects/1/goods/7",
+			wantStatus: http.StatusOK,
+			wantBody: `{"id":7,"projectId":1,"name":"Mango","description":"","priority":1,
+"removed":false,"createdAt":"0001-01-01T00:00:00Z","version":1}`,
+			wantCalls: []string{"GetGood 1/7"},
+		},
+		{
+			name:       "update",
+			method:     http.MethodPatch,
+			target:     "/v2/projects/1/goods/7",
+			body:       `{"name":"Papaya"}`,
+			wantStatus: http.StatusOK,
+			wantBody: `{"id":7,"projectId":1,"name":"Papaya","description":"","priority":1,
+"removed":false,"createdAt":"0001-01-01T00:00:00Z","version":2}`,
+			wantCalls: []string{"UpdateGood 1/7"},
+		},
+		{
+			name:       "remove",
+			method:     http.MethodDelete,
+			target:     "/v2/projects/1/goods/7",
+			wantStatus: http.StatusNoContent,
+			wantCalls:  []string{"DeleteGood 1/7"},
+		},
+		{
+			name:       "reprioritize",
+			method:     http.MethodPost,
+			target:     "/v2/projects/1/goods/7:reprioritize",
+			body:       `{"newPriority":2}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"priorities":[{"id":7,"priority":2},{"id":8,"priority":1}]}`,
+			wantCalls:  []string{"UpdateGoodsPriority 1/7"},
+		},
+		{
+			name:       "reorder",
+			method:     http.MethodPost,
+			target:     "/v2/projects/1/goods:reorder",
+			body:       `{"ids":[8,7]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"priorities":[{"id":8,"priority":1},{"id":7,"priority":2}]}`,
+			wantCalls:  []string{"ReorderGoods 1"},
+		},
+		{
+			name:         "move",
+			method:       http.MethodPost,
+			target:       "/v2/projects/1/goods/7:move",
+			body:         `{"targetProjectId":2}`,
+			wantStatus:   http.StatusOK,
+			wantLocation: "/v2/projects/2/goods/7",
+			wantBody: `{"id":7,"projectId":2,"name":"Mango","description":"","priority":3,
+"removed":false,"createdAt":"0001-01-01T00:00:00Z","version":1}`,
+			wantCalls: []string{"MoveGood 1/7"},
+		},
+		{
+			name:       "unknown good",
+			method:     http.MethodDelete,
+			target:     "/v2/projects/1/goods/9",
+			wantStatus: http.StatusNotFound,
+			wantBody: `{"type":"urn:hezzl:problem:not_found","title":"Resource not found","status":404,
+"instance":"/v2/projects/1/goods/9","code":"not_found"}`,
+			wantCalls: []string{"DeleteGood 1/9"},
+		},
+		{
+			name:       "invalid id",
+			method:     http.MethodPatch,
+			target:     "/v2/projects/1/goods/mango",
+			body:       `{"name":"Papaya"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"urn:hezzl:problem:invalid_request","title":"Invalid request","status":400,
+"detail":"invalid url params","instance":"/v2/projects/1/goods/mango","code":"invalid_request"}`,
+		},
+		{
+			name:       "invalid project",
+			method:     http.MethodPost,
+			target:     "/v2/projects/0/goods",
+			body:       `{"name":"Kiwi"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"urn:hezzl:problem:invalid_request","title":"Invalid request","status":400,
+"detail":"invalid url params","instance":"/v2/projects/0/goods","code":"invalid_request"}`,
+		},
+		{
+			name:       "move within project",
+			method:     http.MethodPost,
+			target:     "/v2/projects/1/goods/7:move",
+			body:       `{"targetProjectId":1}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"urn:hezzl:problem:invalid_request","title":"Invalid request","status":400,
+"detail":"targetProjectId must differ from projectId","instance":"/v2/projects/1/goods/7:move","code":"invalid_request"}`,
+		},
+		{
+			name:       "move to unwritable project",
+			method:     http.MethodPost,
+			target:     "/v2/projects/1/goods/7:move",
+			body:       `{"targetProjectId":3}`,
+			wantStatus: http.StatusForbidden,
+			wantBody: `{"type":"urn:hezzl:problem:forbidden","title":"Forbidden","status":403,
+"instance":"/v2/projects/1/goods/7:move","code":"forbidden"}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage := &fakeStorage{}
+
+			rr := serve(t, storage, writer, tc.method, tc.target, tc.body)
+
+			require.Equal(t, tc.wantStatus, rr.Code, rr.Body.String())
+			require.Equal(t, tc.wantLocation, rr.Header().Get("Location"))
+			require.Equal(t, tc.wantCalls, storage.calls)
+
+			if tc.wantBody == "" {
+				require.Empty(t, rr.Body.String())
+
+				return
+			}
+
+			require.JSONEq(t, tc.wantBody, rr.Body.String())
+		})
+	}
+}
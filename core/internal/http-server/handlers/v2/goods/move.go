@@ -0,0 +1,96 @@
+package goods
+
+import (
+	"errors"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/move"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Move handles POST /v2/projects/{projectId}/goods/{id}:move. Location is
+// the URL of the good in the target project.
+func Move(
+	log *slog.Logger,
+	goodMover move.GoodMover,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.v2.goods.Move"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		id, projectID, err := goodParams(r)
+		if err != nil {
+			log.Info("url params are invalid", sl.Err(err))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, err.Error()))
+
+			return
+		}
+
+		var req move.Request
+
+		err = request.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Decode(err))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+
+			problem.Render(w, r, problem.Validation(validateErr))
+
+			return
+		}
+
+		targetProjectID := strconv.Itoa(req.TargetProjectID)
+		if targetProjectID == projectID {
+			log.Info("good is already in the target project")
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "targetProjectId must differ from projectId"))
+
+			return
+		}
+
+		principal, ok := auth.FromContext(r.Context())
+		if !ok || !principal.Can(req.TargetProjectID, auth.ScopeWrite) {
+			log.Info("no write access to the target project", slog.Int("target_project_id", req.TargetProjectID))
+
+			problem.Render(w, r, problem.New(problem.CodeForbidden, ""))
+
+			return
+		}
+
+		good, err := goodMover.Move(r.Context(), id, projectID, targetProjectID)
+		if err != nil {
+			log.Error("failed to move good", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("good moved", slog.Any("good", good), slog.String("from_project_id", projectID))
+
+		w.Header().Set("Location", Location(good.ProjectID, good.ID))
+		render.JSON(w, r, good)
+	}
+}
@@ -0,0 +1,51 @@
+package goods
+
+import (
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/remove"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+// Remove handles DELETE /v2/projects/{projectId}/goods/{id} and answers
+// 204 No Content.
+func Remove(
+	log *slog.Logger,
+	goodDeleter remove.GoodDeleter,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.v2.goods.Remove"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		id, projectID, err := goodParams(r)
+		if err != nil {
+			log.Info("url params are invalid", sl.Err(err))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, err.Error()))
+
+			return
+		}
+
+		_, err = goodDeleter.Remove(r.Context(), id, projectID)
+		if err != nil {
+			log.Error("failed to delete good", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("good deleted successfully")
+
+		render.NoContent(w, r)
+	}
+}
@@ -0,0 +1,76 @@
+package goods
+
+import (
+	"errors"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/bulkreorder"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+)
+
+// Reorder handles POST /v2/projects/{projectId}/goods:reorder.
+func Reorder(
+	log *slog.Logger,
+	goodsReorderer bulkreorder.GoodsReorderer,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.v2.goods.Reorder"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("actor", auth.Actor(r.Context())),
+		)
+
+		projectID, err := projectParam(r)
+		if err != nil {
+			log.Info("url params are invalid", sl.Err(err))
+
+			problem.Render(w, r, problem.New(problem.CodeInvalidRequest, err.Error()))
+
+			return
+		}
+
+		var req bulkreorder.Request
+
+		err = request.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			problem.Render(w, r, problem.Decode(err))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+
+			problem.Render(w, r, problem.Validation(validateErr))
+
+			return
+		}
+
+		goods, err := goodsReorderer.Reorder(r.Context(), projectID, req.IDs)
+		if err != nil {
+			log.Error("failed to reorder goods", sl.Err(err))
+
+			problem.Error(w, r, err)
+
+			return
+		}
+
+		log.Info("goods reordered successfully", slog.Int("listed", len(req.IDs)), slog.Int("changed", len(goods)))
+
+		render.JSON(w, r, reprioritize.Response{Priorities: bulkreorder.Priorities(req.IDs)})
+	}
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	libauth "github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// QueryTicket is the query parameter Ticket reads.
+const QueryTicket = "ticket"
+
+// TicketRedeemer is implemented by libauth.Tickets.
+type TicketRedeemer interface {
+	Redeem(ctx context.Context, ticket string) (*libauth.Principal, error)
+}
+
+// New authenticates requests by a bearer token in the Authorization header
+// or by the X-API-Key header and stores the resulting principal in the
+// request context. Requests without valid credentials are rejected with
+// 401. Authorization is left to RequireProject and RequireAdmin.
+// Bearer tokens are rejected when jwtVerifier is nil. A principal already
+// in the context, put there by Ticket, is kept.
+func New(
+	log *slog.Logger,
+	keyFinder libauth.KeyFinder,
+	jwtVerifier *libauth.JWTVerifier,
+	cfg config.Auth,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		if !cfg.Enabled {
+			log.Warn("authentication is disabled, every request acts as admin")
+
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := libauth.WithPrincipal(r.Context(), &libauth.Principal{Actor: libauth.ActorAnonymous, Admin: true})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		}
+
+		log.Info("auth middleware enabled", slog.Bool("bearer_tokens", jwtVerifier != nil))
+
+		authenticator := libauth.NewAuthenticator(keyFinder, jwtVerifier, cfg.AdminKey)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := libauth.FromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			log := log.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+
+			var (
+				principal *libauth.Principal
+				err       error
+			)
+
+			if token, ok := libauth.BearerToken(r.Header.Get("Authorization")); ok {
+				principal, err = authenticator.Token(token)
+				if err != nil {
+					log.Info("invalid bearer token", sl.Err(err))
+
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					problem.Render(w, r, problem.New(problem.CodeUnauthorized, ""))
+
+					return
+				}
+			} else {
+				principal, err = authenticator.Key(r.Context(), r.Header.Get(HeaderAPIKey))
+				if errors.Is(err, libauth.ErrUnauthenticated) {
+					log.Info("api key is missing, unknown or revoked")
+
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					problem.Render(w, r, problem.New(problem.CodeUnauthorized, ""))
+
+					return
+				}
+
+				if err != nil {
+					log.Error("failed to find api key", sl.Err(err))
+
+					problem.Error(w, r, err)
+
+					return
+				}
+			}
+
+			log.Debug("request authenticated", slog.String("actor", principal.Actor))
+
+			next.ServeHTTP(w, r.WithContext(libauth.WithPrincipal(r.Context(), principal)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Ticket authenticates requests by a ticket from /auth/tickets in the
+// ticket query parameter. Browsers cannot send headers when they open an
+// EventSource or a WebSocket, so the streams put it before New. Requests
+// without a ticket are left to New, an invalid ticket is rejected with 401.
+func Ticket(log *slog.Logger, ticketRedeemer TicketRedeemer) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ticket := r.URL.Query().Get(QueryTicket)
+			if ticket == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			log := log.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+
+			principal, err := ticketRedeemer.Redeem(r.Context(), ticket)
+			if errors.Is(err, libauth.ErrUnauthenticated) {
+				log.Info("ticket is unknown, used or expired")
+
+				problem.Render(w, r, problem.New(problem.CodeUnauthorized, ""))
+
+				return
+			}
+
+			if err != nil {
+				log.Error("failed to redeem ticket", sl.Err(err))
+
+				problem.Error(w, r, err)
+
+				return
+			}
+
+			log.Debug("request authenticated by ticket", slog.String("actor", principal.Actor))
+
+			next.ServeHTTP(w, r.WithContext(libauth.WithPrincipal(r.Context(), principal)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireProject lets the request through only if the principal holds
+// the scope on the project given by the projectId path parameter, or by
+// the projectId query parameter on routes without one.
+func RequireProject(scope libauth.Scope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := libauth.FromContext(r.Context())
+			if !ok {
+				problem.Render(w, r, problem.New(problem.CodeUnauthorized, ""))
+
+				return
+			}
+
+			// Only admins may list goods across all projects.
+			projectIDStr := chi.URLParam(r, "projectId")
+			if projectIDStr == "" {
+				projectIDStr = r.URL.Query().Get("projectId")
+			}
+
+			if projectIDStr == "" && principal.Admin {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			projectID, err := request.ParseID(projectIDStr)
+			if err != nil {
+				problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid url params"))
+
+				return
+			}
+
+			if !principal.Can(projectID, scope) {
+				problem.Render(w, r, problem.New(problem.CodeForbidden, ""))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func RequireAdmin(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := libauth.FromContext(r.Context())
+		if !ok || !principal.Admin {
+			problem.Render(w, r, problem.New(problem.CodeForbidden, ""))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
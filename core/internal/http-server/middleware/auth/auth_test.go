@@ -0,0 +1,223 @@
+package auth_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	mwAuth "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/auth"
+	libauth "github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	adminKey = "admin-secret"
+	validKey = "hzk_valid"
+)
+
+type keyFinder struct{}
+
+func (keyFinder) GetAPIKeyByHash(_ context.Context, keyHash string) (*models.APIKey, error) {
+	if keyHash != libauth.HashKey(validKey) {
+		return nil, fmt.Errorf("get key: %w", storageerr.ErrNotFound)
+	}
+
+	return &models.APIKey{
+		ID: 7,
+		Projects: []models.ProjectScope{
+			{ProjectID: 1, Scope: string(libauth.ScopeWrite)},
+			{ProjectID: 2, Scope: string(libauth.ScopeRead)},
+		},
+	}, nil
+}
+
+func TestRequireProject(t *testing.T) {
+	cases := []struct {
+		name       string
+		key        string
+		scope      libauth.Scope
+		projectID  string
+		wantStatus int
+	}{
+		{name: "Missing key", key: "", scope: libauth.ScopeRead, projectID: "1", wantStatus: http.StatusUnauthorized},
+		{name: "Unknown key", key: "hzk_other", scope: libauth.ScopeRead, projectID: "1", wantStatus: http.StatusUnauthorized},
+		{name: "Write on write project", key: validKey, scope: libauth.ScopeWrite, projectID: "1", wantStatus: http.StatusOK},
+		{name: "Read on write project", key: validKey, scope: libauth.ScopeRead, projectID: "1", wantStatus: http.StatusOK},
+		{name: "Read on read project", key: validKey, scope: libauth.ScopeRead, projectID: "2", wantStatus: http.StatusOK},
+		{name: "Write on read project", key: validKey, scope: libauth.ScopeWrite, projectID: "2", wantStatus: http.StatusForbidden},
+		{name: "Foreign project", key: validKey, scope: libauth.ScopeRead, projectID: "3", wantStatus: http.StatusForbidden},
+		{name: "All projects", key: validKey, scope: libauth.ScopeRead, projectID: "", wantStatus: http.StatusBadRequest},
+		{name: "Admin on any project", key: adminKey, scope: libauth.ScopeWrite, projectID: "3", wantStatus: http.StatusOK},
+		{name: "Admin on all projects", key: adminKey, scope: libauth.ScopeRead, projectID: "", wantStatus: http.StatusOK},
+	}
+
+	authn := mwAuth.New(slogdiscard.NewDiscardLogger(), keyFinder{}, nil, config.Auth{Enabled: true, AdminKey: adminKey})
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := authn(mwAuth.RequireProject(tc.scope)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/goods/list?projectId="+tc.projectID, nil)
+			if tc.key != "" {
+				req.Header.Set(mwAuth.HeaderAPIKey, tc.key)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+		})
+	}
+}
+
+func TestRequireProjectFromPath(t *testing.T) {
+	authn := mwAuth.New(slogdiscard.NewDiscardLogger(), keyFinder{}, nil, config.Auth{Enabled: true, AdminKey: adminKey})
+
+	router := chi.NewRouter()
+	router.Use(authn)
+	router.With(mwAuth.RequireProject(libauth.ScopeWrite)).Patch("/v2/projects/{projectId}/goods/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	patch := func(url string) int {
+		req := httptest.NewRequest(http.MethodPatch, url, nil)
+		req.Header.Set(mwAuth.HeaderAPIKey, validKey)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusOK, patch("/v2/projects/1/goods/5"))
+	require.Equal(t, http.StatusForbidden, patch("/v2/projects/2/goods/5"))
+	require.Equal(t, http.StatusForbidden, patch("/v2/projects/2/goods/5?projectId=1"), "path wins over query")
+}
+
+func TestBearerToken(t *testing.T) {
+	const secret = "jwt-secret"
+
+	verifier, err := libauth.NewJWTVerifier(config.JWT{Secret: secret})
+	require.NoError(t, err)
+
+	signAs := func(subject, key string, roles ...string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, libauth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   subject,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Projects: []int{1},
+			Roles:    roles,
+		}).SignedString([]byte(key))
+		require.NoError(t, err)
+
+		return token
+	}
+
+	sign := func(key string, roles ...string) string {
+		return signAs("42", key, roles...)
+	}
+
+	cases := []struct {
+		name       string
+		token      string
+		scope      libauth.Scope
+		projectID  string
+		wantStatus int
+		wantActor  string
+	}{
+		{name: "Viewer reads", token: sign(secret, libauth.RoleViewer), scope: libauth.ScopeRead, projectID: "1", wantStatus: http.StatusOK, wantActor: "user:42"},
+		{name: "Viewer writes", token: sign(secret, libauth.RoleViewer), scope: libauth.ScopeWrite, projectID: "1", wantStatus: http.StatusForbidden},
+		{name: "Editor writes", token: sign(secret, libauth.RoleEditor), scope: libauth.ScopeWrite, projectID: "1", wantStatus: http.StatusOK, wantActor: "user:42"},
+		{name: "Editor on foreign project", token: sign(secret, libauth.RoleEditor), scope: libauth.ScopeRead, projectID: "2", wantStatus: http.StatusForbidden},
+		{name: "Admin on foreign project", token: sign(secret, libauth.RoleAdmin), scope: libauth.ScopeWrite, projectID: "2", wantStatus: http.StatusOK, wantActor: "user:42"},
+		{name: "Wrong signature", token: sign("other", libauth.RoleAdmin), scope: libauth.ScopeRead, projectID: "1", wantStatus: http.StatusUnauthorized},
+		{name: "No subject", token: signAs("", secret, libauth.RoleAdmin), scope: libauth.ScopeRead, projectID: "1", wantStatus: http.StatusUnauthorized},
+	}
+
+	authn := mwAuth.New(slogdiscard.NewDiscardLogger(), keyFinder{}, verifier, config.Auth{Enabled: true})
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var actor string
+
+			handler := authn(mwAuth.RequireProject(tc.scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actor = libauth.Actor(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/goods/list?projectId="+tc.projectID, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+			require.Equal(t, tc.wantActor, actor)
+		})
+	}
+}
+
+// ticketRedeemer knows a single ticket, which works once.
+type ticketRedeemer struct {
+	used bool
+}
+
+func (r *ticketRedeemer) Redeem(_ context.Context, ticket string) (*libauth.Principal, error) {
+	if ticket != "hzt_valid" || r.used {
+		return nil, libauth.ErrUnauthenticated
+	}
+
+	r.used = true
+
+	return &libauth.Principal{Actor: "api_key:7", Projects: map[int]libauth.Scope{1: libauth.ScopeRead}}, nil
+}
+
+func TestTicket(t *testing.T) {
+	redeemer := &ticketRedeemer{}
+
+	authn := mwAuth.New(slogdiscard.NewDiscardLogger(), keyFinder{}, nil, config.Auth{Enabled: true, AdminKey: adminKey})
+	handler := mwAuth.Ticket(slogdiscard.NewDiscardLogger(), redeemer)(authn(
+		mwAuth.RequireProject(libauth.ScopeRead)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})),
+	))
+
+	cases := []struct {
+		name       string
+		query      string
+		key        string
+		wantStatus int
+	}{
+		{name: "No ticket", query: "projectId=1", wantStatus: http.StatusUnauthorized},
+		{name: "Key without ticket", query: "projectId=2", key: validKey, wantStatus: http.StatusOK},
+		{name: "Unknown ticket", query: "projectId=1&ticket=hzt_other", key: validKey, wantStatus: http.StatusUnauthorized},
+		{name: "Ticket", query: "projectId=1&ticket=hzt_valid", wantStatus: http.StatusOK},
+		{name: "Used ticket", query: "projectId=1&ticket=hzt_valid", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/goods/events?"+tc.query, nil)
+			if tc.key != "" {
+				req.Header.Set(mwAuth.HeaderAPIKey, tc.key)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+type Store interface {
+	// Reserve saves rec under key unless the key is taken. It returns the
+	// record already stored under the key, or nil if the key was reserved.
+	Reserve(
+		ctx context.Context,
+		key string,
+		rec models.IdempotencyRecord,
+		ttl time.Duration,
+	) (*models.IdempotencyRecord, error)
+	Complete(ctx context.Context, key string, rec models.IdempotencyRecord, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+// New replays the stored response of a request retried with the same
+// Idempotency-Key instead of running it again. Keys are scoped to the
+// client, so it must run after the auth middleware. Reusing a key for a
+// different request is rejected with 422, retrying while the first request
+// is in progress with 409. Server errors are not stored, so such requests
+// may be retried. When the store fails the request is let through.
+func New(log *slog.Logger, store Store, cfg config.Idempotency) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/idempotency"),
+		)
+
+		log.Info("idempotency middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			idemKey := r.Header.Get(HeaderKey)
+			if idemKey == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			log := log.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+				slog.String("idempotency_key", idemKey),
+			)
+
+			if len(idemKey) > maxKeyLength {
+				log.Info("idempotency key is too long")
+
+				problem.Render(w, r, problem.New(problem.CodeInvalidRequest, "invalid idempotency key"))
+
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				log.Error("failed to read request body", sl.Err(err))
+
+				problem.Render(w, r, problem.Decode(err))
+
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key := auth.Actor(r.Context()) + ":" + idemKey
+			hash := requestHash(r, body)
+
+			// Bookkeeping must outlive a client that hangs up mid-request.
+			ctx := context.WithoutCancel(r.Context())
+
+			stored, err := store.Reserve(ctx, key, models.IdempotencyRecord{RequestHash: hash}, cfg.LockTimeout)
+			if err != nil {
+				log.Warn("failed to reserve idempotency key", sl.Err(err))
+
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			if stored != nil {
+				replay(log, w, r, stored, hash)
+
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			buf := &bytes.Buffer{}
+			ww.Tee(buf)
+
+			completed := false
+
+			defer func() {
+				if completed {
+					return
+				}
+
+				if err := store.Release(ctx, key); err != nil {
+					log.Warn("failed to release idempotency key", sl.Err(err))
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+
+			if ww.Status() >= http.StatusInternalServerError {
+				return
+			}
+
+			err = store.Complete(ctx, key, models.IdempotencyRecord{
+				RequestHash: hash,
+				Done:        true,
+				Status:      ww.Status(),
+				ContentType: ww.Header().Get("Content-Type"),
+				Body:        buf.Bytes(),
+			}, cfg.TTL)
+			if err != nil {
+				log.Warn("failed to save idempotent response", sl.Err(err))
+
+				return
+			}
+
+			completed = true
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func replay(log *slog.Logger, w http.ResponseWriter, r *http.Request, stored *models.IdempotencyRecord, hash string) {
+	if stored.RequestHash != hash {
+		log.Info("idempotency key reused with a different request")
+
+		problem.Render(w, r, problem.New(problem.CodeIdempotencyKeyReused, ""))
+
+		return
+	}
+
+	if !stored.Done {
+		log.Info("request with the same idempotency key is in progress")
+
+		problem.Render(w, r, problem.New(problem.CodeIdempotencyInProgress, ""))
+
+		return
+	}
+
+	log.Info("replaying stored response", slog.Int("status", stored.Status))
+
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+
+	w.Header().Set(HeaderReplayed, strconv.FormatBool(true))
+	w.WriteHeader(stored.Status)
+
+	//nolint: errcheck
+	w.Write(stored.Body)
+}
+
+// requestHash identifies the request a key was first used with: its method,
+// URL and body.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
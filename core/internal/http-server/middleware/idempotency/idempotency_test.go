@@ -0,0 +1,112 @@
+package idempotency_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/idempotency"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	store := redis.New(config.RedisStorage{Addr: mr.Addr()})
+	t.Cleanup(func() { store.Close() }) //nolint: errcheck
+
+	var calls atomic.Int64
+
+	inProgress := make(chan struct{})
+	release := make(chan struct{})
+
+	router := chi.NewRouter()
+	router.Use(idempotency.New(slogdiscard.NewDiscardLogger(), store, config.Idempotency{
+		TTL:         time.Hour,
+		LockTimeout: time.Minute,
+	}))
+	router.Post("/good/create", func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+
+		if r.URL.Query().Get("slow") != "" {
+			close(inProgress)
+			<-release
+		}
+
+		if r.URL.Query().Get("fail") != "" && n == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"n":` + strconv.FormatInt(n, 10) + `}`)) //nolint: errcheck
+	})
+
+	do := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotency.HeaderKey, key)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	first := do("/good/create?projectId=1", "k1", `{"name":"a"}`)
+	require.Equal(t, http.StatusOK, first.Code)
+	require.JSONEq(t, `{"n":1}`, first.Body.String())
+
+	replayed := do("/good/create?projectId=1", "k1", `{"name":"a"}`)
+	require.Equal(t, http.StatusOK, replayed.Code)
+	require.JSONEq(t, `{"n":1}`, replayed.Body.String())
+	require.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+	require.Equal(t, "true", replayed.Header().Get(idempotency.HeaderReplayed))
+	require.EqualValues(t, 1, calls.Load(), "replays must not reach the handler")
+
+	rr := do("/good/create?projectId=1", "k1", `{"name":"b"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	rr = do("/good/create?projectId=2", "k1", `{"name":"a"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	rr = do("/good/create?projectId=1", "", `{"name":"a"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.EqualValues(t, 2, calls.Load(), "requests without a key are not deduplicated")
+
+	t.Run("server errors are not stored", func(t *testing.T) {
+		calls.Store(1)
+
+		rr := do("/good/create?fail=1", "k2", `{}`)
+		require.Equal(t, http.StatusInternalServerError, rr.Code)
+
+		rr = do("/good/create?fail=1", "k2", `{}`)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Empty(t, rr.Header().Get(idempotency.HeaderReplayed))
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- do("/good/create?slow=1", "k3", `{}`) }()
+
+		<-inProgress
+
+		rr := do("/good/create?slow=1", "k3", `{}`)
+		require.Equal(t, http.StatusConflict, rr.Code)
+
+		close(release)
+		require.Equal(t, http.StatusOK, (<-done).Code)
+	})
+}
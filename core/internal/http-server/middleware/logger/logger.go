@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func New(log *slog.Logger) func(next http.Handler) http.Handler {
@@ -24,6 +25,10 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 				slog.String("user_agent", r.UserAgent()),
 				slog.String("request_id", middleware.GetReqID(r.Context())),
 			)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				entry = entry.With(slog.String("trace_id", sc.TraceID().String()))
+			}
+
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
 			t1 := time.Now()
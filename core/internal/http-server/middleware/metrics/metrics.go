@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// routeUnmatched labels requests that matched no route, so random paths do
+// not blow up the number of series.
+const routeUnmatched = "unmatched"
+
+// New counts requests and measures their latency per route pattern and
+// status. It must run outside middleware.Recoverer to see panics as 500.
+func New(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/metrics"),
+		)
+
+		log.Info("metrics middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			t1 := time.Now()
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				labels := []string{r.Method, routePattern(r), strconv.Itoa(status)}
+
+				metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+				metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(t1).Seconds())
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// routePattern is only known once the request has been routed.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return routeUnmatched
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/ratelimit"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit config.Limit) (*models.RateLimitResult, error)
+}
+
+// New limits requests per route and client. It must run after the auth
+// middleware, so authenticated clients are limited by their actor rather
+// than by IP. When the limiter fails the request is let through.
+func New(log *slog.Logger, limiter Limiter, cfg config.RateLimit) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/ratelimit"),
+		)
+
+		log.Info("rate limit middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			route := r.Method + " " + routePattern(r)
+
+			limit, ok := cfg.Routes[route]
+			if !ok {
+				limit = cfg.Default
+			}
+
+			if limit.Requests <= 0 || limit.Window <= 0 {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			res, err := limiter.Allow(r.Context(), route+" "+ratelimit.ClientKey(r.Context(), r.RemoteAddr), limit)
+			if err != nil {
+				log.Warn("failed to check rate limit",
+					sl.Err(err),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+				)
+
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ratelimit.Seconds(res.Reset)))
+
+			if !res.Allowed {
+				log.Info("rate limit exceeded",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("route", route),
+					slog.String("client", ratelimit.ClientKey(r.Context(), r.RemoteAddr)),
+				)
+
+				w.Header().Set("Retry-After", strconv.Itoa(ratelimit.Seconds(res.RetryAfter)))
+				problem.Render(w, r, problem.New(problem.CodeRateLimited, ""))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return r.URL.Path
+}
@@ -0,0 +1,63 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/ratelimit"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	limiter := redis.New(config.RedisStorage{Addr: mr.Addr()})
+	t.Cleanup(func() { limiter.Close() }) //nolint: errcheck
+
+	router := chi.NewRouter()
+	router.Use(ratelimit.New(slogdiscard.NewDiscardLogger(), limiter, config.RateLimit{
+		Default: config.Limit{Requests: 100, Window: time.Minute},
+		Routes: map[string]config.Limit{
+			"POST /good/create": {Requests: 2, Window: time.Minute},
+		},
+	}))
+
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.Post("/good/create", ok)
+	router.Get("/goods/list", ok)
+
+	do := func(method, path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	for i := range 2 {
+		rr := do(http.MethodPost, "/good/create", "10.0.0.1:1234")
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "2", rr.Header().Get("X-RateLimit-Limit"))
+		require.Equal(t, []string{"1", "0"}[i], rr.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	rr := do(http.MethodPost, "/good/create", "10.0.0.1:1234")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "30", rr.Header().Get("Retry-After"))
+
+	rr = do(http.MethodPost, "/good/create", "10.0.0.2:1234")
+	require.Equal(t, http.StatusOK, rr.Code, "other clients keep their own limit")
+
+	rr = do(http.MethodGet, "/goods/list", "10.0.0.1:1234")
+	require.Equal(t, http.StatusOK, rr.Code, "other routes keep their own limit")
+	require.Equal(t, "100", rr.Header().Get("X-RateLimit-Limit"))
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/tracing"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New starts a server span for every request, continuing the trace of the
+// caller if it sent one. The span is renamed after the route pattern once
+// the request has been routed.
+func New(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/tracing"),
+		)
+
+		log.Info("tracing middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			rctx := chi.RouteContext(r.Context())
+			if rctx == nil || rctx.RoutePattern() == "" {
+				return
+			}
+
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+		}
+
+		return otelhttp.NewHandler(http.HandlerFunc(fn), tracing.ServiceName,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method
+			}),
+		)
+	}
+}
@@ -0,0 +1,145 @@
+package validate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/openapi"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/problem"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// New validates requests and responses of the routes in the spec. Requests
+// failing validation are rejected with 400 before they reach the handler.
+// Responses failing validation are logged. Routes missing from the spec
+// are passed through, as are the responses of event streams and
+// WebSockets, which never end. Authentication is left to the auth middleware.
+func New(log *slog.Logger, spec *openapi.Spec, cfg config.OpenAPI) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/validate"),
+		)
+
+		log.Info("openapi validation middleware enabled",
+			slog.Bool("requests", cfg.ValidateRequests),
+			slog.Bool("responses", cfg.ValidateResponses),
+		)
+
+		options := &openapi3filter.Options{
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+		}
+		options.WithCustomSchemaErrorFunc(schemaError)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := spec.Router.FindRoute(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			log := log.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+				slog.String("operation", route.Operation.OperationID),
+			)
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    options,
+			}
+
+			if cfg.ValidateRequests {
+				if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+					log.Info("request does not match openapi spec", sl.Err(err))
+
+					var tooLarge *http.MaxBytesError
+					if errors.As(err, &tooLarge) {
+						problem.Render(w, r, problem.Decode(err))
+
+						return
+					}
+
+					problem.Render(w, r, problem.New(problem.CodeValidationFailed, err.Error()))
+
+					return
+				}
+			}
+
+			if !cfg.ValidateResponses || streams(route.Operation) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			buf := &bytes.Buffer{}
+			ww.Tee(buf)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 status,
+				Header:                 ww.Header(),
+				Body:                   io.NopCloser(buf),
+				Options:                options,
+			})
+			if err != nil {
+				log.Error("response does not match openapi spec",
+					sl.Err(err),
+					slog.Int("status", status),
+				)
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// schemaError describes a value that does not match its schema. Request
+// errors are returned to clients, so unlike SchemaError.Error it leaves
+// out the dumps of the schema and the value, which would only bloat them.
+func schemaError(err *openapi3.SchemaError) string {
+	reason := err.Reason
+	if err.Origin != nil {
+		reason = err.Origin.Error()
+	} else if reason == "" {
+		reason = fmt.Sprintf("doesn't match schema %q", err.SchemaField)
+	}
+
+	if path := err.JSONPointer(); len(path) > 0 {
+		return fmt.Sprintf("Error at %q: %s", "/"+strings.Join(path, "/"), reason)
+	}
+
+	return reason
+}
+
+func streams(op *openapi3.Operation) bool {
+	if op.Responses.Status(http.StatusSwitchingProtocols) != nil {
+		return true
+	}
+
+	ok := op.Responses.Status(http.StatusOK)
+	if ok == nil || ok.Value == nil {
+		return false
+	}
+
+	return ok.Value.Content.Get("text/event-stream") != nil
+}
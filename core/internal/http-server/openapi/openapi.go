@@ -0,0 +1,56 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+// Spec is the OpenAPI specification of core, parsed from openapi.yaml.
+type Spec struct {
+	Doc    *openapi3.T
+	Router routers.Router
+
+	json []byte
+}
+
+func Load() (*Spec, error) {
+	const op = "openapi.Load"
+
+	doc, err := openapi3.NewLoader().LoadFromData(specYAML)
+	if err != nil {
+		return nil, fmt.Errorf("%s: load spec: %w", op, err)
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("%s: invalid spec: %w", op, err)
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: create router: %w", op, err)
+	}
+
+	data, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("%s: marshal spec: %w", op, err)
+	}
+
+	return &Spec{Doc: doc, Router: router, json: data}, nil
+}
+
+// Handler serves the spec as JSON.
+func (s *Spec) Handler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	//nolint: errcheck
+	w.Write(s.json)
+}
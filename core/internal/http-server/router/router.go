@@ -0,0 +1,169 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/apikey/issue"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/apikey/revoke"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/bulkreorder"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/compact"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/create"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/events"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/get"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/graphql"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/health"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/move"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/remove"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reorder"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reprioritize"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/ticket"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/update"
+	v2goods "github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/v2/goods"
+	mwAuth "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/auth"
+	mwIdempotency "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/idempotency"
+	mwLogger "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/logger"
+	mwMetrics "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/metrics"
+	mwRateLimit "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/ratelimit"
+	mwTracing "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/tracing"
+	mwValidate "github.com/Gonnekone/hezzl-test/core/internal/http-server/middleware/validate"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/openapi"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Storage is everything the handlers read from storage.Storage.
+type Storage interface {
+	get.GoodGetter
+	list.GoodLister
+	graphql.ProjectGetter
+	graphql.GoodPager
+	issue.KeySaver
+	revoke.KeyRevoker
+	auth.KeyFinder
+}
+
+// Goods is implemented by goods.Service, every write of goods goes
+// through it.
+type Goods interface {
+	create.GoodCreator
+	update.GoodUpdater
+	remove.GoodDeleter
+	reprioritize.GoodPriorityUpdater
+	reorder.GoodMover
+	bulkreorder.GoodsReorderer
+	move.GoodMover
+	compact.Compactor
+}
+
+// SharedState is kept in Redis, so every core instance sees the same.
+type SharedState interface {
+	mwRateLimit.Limiter
+	mwIdempotency.Store
+	auth.TicketStore
+}
+
+type Deps struct {
+	Storage Storage
+	Goods   Goods
+	// History is nil when history is disabled.
+	History     graphql.HistoryReader
+	SharedState SharedState
+	Events      events.Subscriber
+	JWTVerifier *auth.JWTVerifier
+	Probes      *health.Health
+	Spec        *openapi.Spec
+}
+
+// New returns the HTTP API of core.
+func New(log *slog.Logger, cfg *config.Config, deps Deps) http.Handler {
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(mwTracing.New(log))
+	router.Use(mwLogger.New(log))
+	router.Use(mwMetrics.New(log))
+	router.Use(middleware.Recoverer)
+	router.Use(middleware.URLFormat)
+	if cfg.HTTPServer.MaxBodySize > 0 {
+		router.Use(middleware.RequestSize(cfg.HTTPServer.MaxBodySize))
+	}
+
+	router.Get("/healthz", deps.Probes.Live)
+	router.Get("/readyz", deps.Probes.Ready)
+	router.Method(http.MethodGet, "/metrics", promhttp.Handler())
+	// URLFormat routes /openapi.json by its path without the extension.
+	router.Get("/openapi", deps.Spec.Handler)
+
+	tickets := auth.NewTickets(deps.SharedState, cfg.Auth.TicketTTL)
+
+	authenticated := func(r chi.Router) {
+		r.Use(mwAuth.New(log, deps.Storage, deps.JWTVerifier, cfg.Auth))
+		if cfg.RateLimit.Enabled {
+			r.Use(mwRateLimit.New(log, deps.SharedState, cfg.RateLimit))
+		}
+		r.Use(mwValidate.New(log, deps.Spec, cfg.OpenAPI))
+	}
+
+	// Browsers open the streams without headers, so they also take a
+	// ticket from /auth/tickets.
+	router.Group(func(r chi.Router) {
+		r.Use(mwAuth.Ticket(log, tickets))
+		authenticated(r)
+
+		read := r.With(mwAuth.RequireProject(auth.ScopeRead))
+		read.Get("/goods/events", events.New(log, deps.Events, cfg.Events.Heartbeat))
+		// Moves sent over the socket check the write scope themselves.
+		read.Get("/goods/ws", reorder.New(
+			log,
+			deps.Goods,
+			deps.Storage,
+			deps.Events,
+			cfg.Events.Heartbeat,
+			cfg.Events.AllowedOrigins,
+		))
+	})
+
+	router.Group(func(r chi.Router) {
+		authenticated(r)
+
+		r.Post("/auth/tickets", ticket.New(log, tickets))
+
+		write := r.With(mwAuth.RequireProject(auth.ScopeWrite))
+		if cfg.Idempotency.Enabled {
+			write = write.With(mwIdempotency.New(log, deps.SharedState, cfg.Idempotency))
+		}
+
+		write.Post("/good/create", create.New(log, deps.Goods))
+		write.Patch("/good/update", update.New(log, deps.Goods))
+		write.Delete("/good/remove", remove.New(log, deps.Goods))
+		write.Patch("/good/reprioritize", reprioritize.New(log, deps.Goods))
+		write.Patch("/goods/reorder", bulkreorder.New(log, deps.Goods))
+		// The target project is checked by the handler.
+		write.Post("/good/move", move.New(log, deps.Goods))
+		write.Post("/v2/projects/{projectId}/goods", v2goods.Create(log, deps.Goods))
+		write.Patch("/v2/projects/{projectId}/goods/{id}", v2goods.Update(log, deps.Goods))
+		write.Delete("/v2/projects/{projectId}/goods/{id}", v2goods.Remove(log, deps.Goods))
+		write.Post("/v2/projects/{projectId}/goods/{id}:reprioritize", v2goods.Reprioritize(log, deps.Goods))
+		write.Post("/v2/projects/{projectId}/goods:reorder", v2goods.Reorder(log, deps.Goods))
+		write.Post("/v2/projects/{projectId}/goods/{id}:move", v2goods.Move(log, deps.Goods))
+
+		read := r.With(mwAuth.RequireProject(auth.ScopeRead))
+		read.Get("/good", get.New(log, deps.Storage))
+		read.Get("/goods/list", list.New(log, deps.Storage, cfg.RedisStorage.StaleWhileRevalidate))
+		read.Get("/v2/projects/{projectId}/goods/{id}", v2goods.Get(log, deps.Storage))
+
+		// Resolvers check access to each project themselves.
+		r.Post("/graphql", graphql.New(log, deps.Storage, deps.Goods, deps.History))
+
+		admin := r.With(mwAuth.RequireAdmin)
+		admin.Post("/admin/keys", issue.New(log, deps.Storage))
+		admin.Delete("/admin/keys", revoke.New(log, deps.Storage))
+		admin.Post("/admin/compact", compact.New(log, deps.Goods))
+	})
+
+	return router
+}
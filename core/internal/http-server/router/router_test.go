@@ -0,0 +1,578 @@
+package router_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/events"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/health"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/reorder"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/openapi"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/router"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	goodsService "github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/redis"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// missingID is the id of a good or key that does not exist.
+	missingID = "404"
+	// brokenID is the id of a good the storage fails to read.
+	brokenID = "500"
+)
+
+var createdAt = time.Date(2025, 6, 16, 19, 0, 41, 0, time.UTC)
+
+type fakeStorage struct{}
+
+func (fakeStorage) good(id, projectID string) (*models.Good, error) {
+	switch id {
+	case missingID:
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrNotFound)
+	case brokenID:
+		return nil, errors.New("fake: connection refused")
+	}
+
+	return &models.Good{ID: 1, ProjectID: 1, Name: "Mango", Priority: 1, CreatedAt: createdAt, Version: 1}, nil
+}
+
+func (s fakeStorage) SaveGood(_ context.Context, _ string, projectID string) (*models.Good, error) {
+	return s.good("1", projectID)
+}
+
+func (s fakeStorage) UpdateGood(_ context.Context, id, projectID, _, _ string) (*models.Good, error) {
+	return s.good(id, projectID)
+}
+
+func (s fakeStorage) DeleteGood(_ context.Context, id, projectID string) (*models.Good, error) {
+	return s.good(id, projectID)
+}
+
+func (s fakeStorage) UpdateGoodsPriority(_ context.Context, id, projectID string, _ int) ([]models.Good, error) {
+	good, err := s.good(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.Good{*good}, nil
+}
+
+func (s fakeStorage) UpdateGoodsPriorityIfVersion(_ context.Context, id, projectID string, _, version int) ([]models.Good, error) {
+	good, err := s.good(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != good.Version {
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrVersionConflict)
+	}
+
+	good.Priority++
+	good.Version++
+
+	return []models.Good{*good}, nil
+}
+
+func (s fakeStorage) ReorderGoods(_ context.Context, projectID string, ids []int) ([]models.Good, error) {
+	if !slices.Equal(ids, []int{2, 1}) {
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrIncompleteOrder)
+	}
+
+	good, err := s.good("1", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.Priority = 2
+
+	return []models.Good{*good}, nil
+}
+
+func (s fakeStorage) MoveGood(_ context.Context, id, projectID, targetProjectID string) (*models.Good, error) {
+	if projectID == missingID || targetProjectID == missingID {
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrProjectNotFound)
+	}
+
+	good, err := s.good(id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	good.ProjectID, _ = strconv.Atoi(targetProjectID)
+	good.Version++
+
+	return good, nil
+}
+
+func (s fakeStorage) CompactGoods(_ context.Context, projectID string) ([]models.Good, error) {
+	if projectID == missingID {
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrProjectNotFound)
+	}
+
+	return []models.Good{}, nil
+}
+
+func (fakeStorage) ListProjectsToCompact(context.Context) ([]int, error) {
+	return nil, nil
+}
+
+func (s fakeStorage) GetGood(_ context.Context, id, projectID string) (*models.Good, error) {
+	return s.good(id, projectID)
+}
+
+func (s fakeStorage) ListGoods(context.Context, string, int, int) (*list.GoodListResponse, error) {
+	good, _ := s.good("1", "1")
+
+	return &list.GoodListResponse{
+		Meta:  list.GoodMetaListResponse{Total: 1, Limit: 10, Offset: 1},
+		Goods: []models.Good{*good},
+	}, nil
+}
+
+func (fakeStorage) GetProjects(_ context.Context, ids []int) ([]models.Project, error) {
+	projects := make([]models.Project, 0, len(ids))
+	for _, id := range ids {
+		projects = append(projects, models.Project{ID: id, Name: "First record", CreatedAt: createdAt})
+	}
+
+	return projects, nil
+}
+
+func (s fakeStorage) ListProjectGoods(_ context.Context, projectIDs []int, _ models.GoodFilter) (map[int]*models.GoodPage, error) {
+	good, _ := s.good("1", "1")
+
+	pages := make(map[int]*models.GoodPage, len(projectIDs))
+	for _, id := range projectIDs {
+		pages[id] = &models.GoodPage{Goods: []models.Good{*good}, Total: 1}
+	}
+
+	return pages, nil
+}
+
+func (fakeStorage) SaveAPIKey(_ context.Context, name, _ string, projects []models.ProjectScope) (*models.APIKey, error) {
+	return &models.APIKey{ID: 1, Name: name, Projects: projects, CreatedAt: createdAt}, nil
+}
+
+func (fakeStorage) RevokeAPIKey(_ context.Context, id string) (*models.APIKey, error) {
+	if id == missingID {
+		return nil, fmt.Errorf("fake: %w", storageerr.ErrNotFound)
+	}
+
+	return &models.APIKey{ID: 1, CreatedAt: createdAt, RevokedAt: &createdAt}, nil
+}
+
+func (fakeStorage) GetAPIKeyByHash(context.Context, string) (*models.APIKey, error) {
+	return nil, fmt.Errorf("fake: %w", storageerr.ErrNotFound)
+}
+
+func (fakeStorage) InvalidList(context.Context, string) error                 { return nil }
+func (fakeStorage) InvalidGoods(context.Context, ...models.Good) error        { return nil }
+func (fakeStorage) SaveGoodInCache(context.Context, int64, models.Good) error { return nil }
+func (fakeStorage) SaveListInCache(context.Context, string, int64, list.Page) error {
+	return nil
+}
+
+func (fakeStorage) GetCachedGood(context.Context, string, string) (*models.CachedGood, error) {
+	return nil, nil
+}
+
+func (fakeStorage) GetCachedList(context.Context, string, int, int) (*list.CachedList, error) {
+	return nil, nil
+}
+
+type fakeEvents struct{}
+
+// Subscribe delivers one event of project 1 after the event with id after.
+func (fakeEvents) Subscribe(_ context.Context, after uint64) (<-chan events.Event, error) {
+	ch := make(chan events.Event, 1)
+	ch <- events.Event{ID: after + 1, Type: models.EventCreated, ProjectID: 1, Data: []byte(`{"id":1}`)}
+
+	return ch, nil
+}
+
+type fakeProducer struct{}
+
+func (fakeProducer) Send(context.Context, []byte) error      { return nil }
+func (fakeProducer) SendAsync(context.Context, []byte) error { return nil }
+
+func newRouter(t *testing.T, cfg *config.Config) (http.Handler, *openapi.Spec) {
+	t.Helper()
+
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	mr := miniredis.RunT(t)
+
+	shared := redis.New(config.RedisStorage{Addr: mr.Addr()})
+	t.Cleanup(func() { shared.Close() }) //nolint: errcheck
+
+	log := slogdiscard.NewDiscardLogger()
+	goods := goodsService.New(log, fakeStorage{}, fakeProducer{})
+
+	return router.New(log, cfg, router.Deps{
+		Storage:     fakeStorage{},
+		Goods:       goods,
+		SharedState: shared,
+		Events:      fakeEvents{},
+		Probes:      health.New(log, nil),
+		Spec:        spec,
+	}), spec
+}
+
+// TestRoutesMatchSpec fails when a route is added without documenting it,
+// or documented without being served.
+func TestRoutesMatchSpec(t *testing.T) {
+	handler, spec := newRouter(t, &config.Config{})
+
+	var served []string
+
+	err := chi.Walk(handler.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		served = append(served, method+" "+route)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	var documented []string
+
+	for p, item := range spec.Doc.Paths.Map() {
+		// middleware.URLFormat routes paths without their extension.
+		p = strings.TrimSuffix(p, path.Ext(p))
+
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+p)
+		}
+	}
+
+	sort.Strings(served)
+	sort.Strings(documented)
+
+	require.Equal(t, documented, served)
+}
+
+// TestResponsesMatchSpec fails when a handler responds with a status or a
+// body the spec does not describe.
+func TestResponsesMatchSpec(t *testing.T) {
+	cfg := &config.Config{
+		HTTPServer:  config.HTTPServer{MaxBodySize: 1 << 10},
+		Auth:        config.Auth{Enabled: false},
+		Idempotency: config.Idempotency{Enabled: true, TTL: time.Hour, LockTimeout: time.Minute},
+		OpenAPI:     config.OpenAPI{ValidateRequests: true},
+	}
+
+	authCfg := &config.Config{
+		Auth:    config.Auth{Enabled: true, AdminKey: "admin"},
+		OpenAPI: config.OpenAPI{ValidateRequests: true},
+	}
+
+	cases := []struct {
+		name   string
+		cfg    *config.Config
+		method string
+		url    string
+		header http.Header
+		body   string
+		status int
+	}{
+		{name: "create", method: http.MethodPost, url: "/good/create?projectId=1", body: `{"name":"Mango"}`, status: http.StatusOK},
+		{name: "create without name", method: http.MethodPost, url: "/good/create?projectId=1", body: `{}`, status: http.StatusBadRequest},
+		{name: "create with invalid project", method: http.MethodPost, url: "/good/create?projectId=x", body: `{"name":"Mango"}`, status: http.StatusBadRequest},
+		{name: "create replayed", method: http.MethodPost, url: "/good/create?projectId=1", header: http.Header{"Idempotency-Key": {"k"}}, body: `{"name":"Mango"}`, status: http.StatusOK},
+		{name: "create with reused key", method: http.MethodPost, url: "/good/create?projectId=1", header: http.Header{"Idempotency-Key": {"k"}}, body: `{"name":"Banana"}`, status: http.StatusUnprocessableEntity},
+		{name: "create with unknown field", method: http.MethodPost, url: "/good/create?projectId=1", body: `{"name":"Mango","price":1}`, status: http.StatusBadRequest},
+		{name: "create with too long name", method: http.MethodPost, url: "/good/create?projectId=1", body: `{"name":"` + strings.Repeat("a", 256) + `"}`, status: http.StatusBadRequest},
+		{name: "create with too large body", method: http.MethodPost, url: "/good/create?projectId=1", body: `{"name":"` + strings.Repeat("a", 2<<10) + `"}`, status: http.StatusRequestEntityTooLarge},
+		{name: "create in project 0", method: http.MethodPost, url: "/good/create?projectId=0", body: `{"name":"Mango"}`, status: http.StatusBadRequest},
+		{name: "update", method: http.MethodPatch, url: "/good/update?id=1&projectId=1", body: `{"name":"Mango","description":"ripe"}`, status: http.StatusOK},
+		{name: "update missing", method: http.MethodPatch, url: "/good/update?id=404&projectId=1", body: `{"name":"Mango"}`, status: http.StatusNotFound},
+		{name: "remove", method: http.MethodDelete, url: "/good/remove?id=1&projectId=1", status: http.StatusOK},
+		{name: "remove missing", method: http.MethodDelete, url: "/good/remove?id=404&projectId=1", status: http.StatusNotFound},
+		{name: "reprioritize", method: http.MethodPatch, url: "/good/reprioritize?id=1&projectId=1", body: `{"newPriority":2}`, status: http.StatusOK},
+		{name: "reprioritize missing", method: http.MethodPatch, url: "/good/reprioritize?id=404&projectId=1", body: `{"newPriority":2}`, status: http.StatusNotFound},
+		{name: "reorder", method: http.MethodPatch, url: "/goods/reorder?projectId=1", body: `{"ids":[2,1]}`, status: http.StatusOK},
+		{name: "reorder with missing good", method: http.MethodPatch, url: "/goods/reorder?projectId=1", body: `{"ids":[2]}`, status: http.StatusBadRequest},
+		{name: "reorder with duplicate ids", method: http.MethodPatch, url: "/goods/reorder?projectId=1", body: `{"ids":[2,2]}`, status: http.StatusBadRequest},
+		{name: "move", method: http.MethodPost, url: "/good/move?id=1&projectId=1", body: `{"targetProjectId":2}`, status: http.StatusOK},
+		{name: "move missing", method: http.MethodPost, url: "/good/move?id=404&projectId=1", body: `{"targetProjectId":2}`, status: http.StatusNotFound},
+		{name: "move from missing project", method: http.MethodPost, url: "/good/move?id=1&projectId=404", body: `{"targetProjectId":2}`, status: http.StatusNotFound},
+		{name: "move to missing project", method: http.MethodPost, url: "/good/move?id=1&projectId=1", body: `{"targetProjectId":404}`, status: http.StatusNotFound},
+		{name: "move to same project", method: http.MethodPost, url: "/good/move?id=1&projectId=1", body: `{"targetProjectId":1}`, status: http.StatusBadRequest},
+		{name: "move without target", method: http.MethodPost, url: "/good/move?id=1&projectId=1", body: `{}`, status: http.StatusBadRequest},
+		{name: "get", method: http.MethodGet, url: "/good?id=1&projectId=1", status: http.StatusOK},
+		{name: "get missing", method: http.MethodGet, url: "/good?id=404&projectId=1", status: http.StatusNotFound},
+		{name: "list", method: http.MethodGet, url: "/goods/list?projectId=1", status: http.StatusOK},
+		{name: "list of all projects", method: http.MethodGet, url: "/goods/list?limit=5&offset=0", status: http.StatusOK},
+		{name: "list with invalid project", method: http.MethodGet, url: "/goods/list?projectId=x", status: http.StatusBadRequest},
+		{name: "list with zero limit", method: http.MethodGet, url: "/goods/list?projectId=1&limit=0", status: http.StatusBadRequest},
+		{name: "list with too large limit", method: http.MethodGet, url: "/goods/list?projectId=1&limit=101", status: http.StatusBadRequest},
+		{name: "list with negative offset", method: http.MethodGet, url: "/goods/list?projectId=1&offset=-1", status: http.StatusBadRequest},
+		{name: "v2 create", method: http.MethodPost, url: "/v2/projects/1/goods", body: `{"name":"Mango"}`, status: http.StatusCreated},
+		{name: "v2 create with invalid project", method: http.MethodPost, url: "/v2/projects/x/goods", body: `{"name":"Mango"}`, status: http.StatusBadRequest},
+		{name: "v2 get", method: http.MethodGet, url: "/v2/projects/1/goods/1", status: http.StatusOK},
+		{name: "v2 get missing", method: http.MethodGet, url: "/v2/projects/1/goods/404", status: http.StatusNotFound},
+		{name: "v2 update", method: http.MethodPatch, url: "/v2/projects/1/goods/1", body: `{"name":"Mango"}`, status: http.StatusOK},
+		{name: "v2 update missing", method: http.MethodPatch, url: "/v2/projects/1/goods/404", body: `{"name":"Mango"}`, status: http.StatusNotFound},
+		{name: "v2 remove", method: http.MethodDelete, url: "/v2/projects/1/goods/1", status: http.StatusNoContent},
+		{name: "v2 remove missing", method: http.MethodDelete, url: "/v2/projects/1/goods/404", status: http.StatusNotFound},
+		{name: "v2 reprioritize", method: http.MethodPost, url: "/v2/projects/1/goods/1:reprioritize", body: `{"newPriority":2}`, status: http.StatusOK},
+		{name: "v2 reprioritize missing", method: http.MethodPost, url: "/v2/projects/1/goods/404:reprioritize", body: `{"newPriority":2}`, status: http.StatusNotFound},
+		{name: "v2 reorder", method: http.MethodPost, url: "/v2/projects/1/goods:reorder", body: `{"ids":[2,1]}`, status: http.StatusOK},
+		{name: "v2 reorder without ids", method: http.MethodPost, url: "/v2/projects/1/goods:reorder", body: `{"ids":[]}`, status: http.StatusBadRequest},
+		{name: "v2 move", method: http.MethodPost, url: "/v2/projects/1/goods/1:move", body: `{"targetProjectId":2}`, status: http.StatusOK},
+		{name: "v2 move missing", method: http.MethodPost, url: "/v2/projects/1/goods/404:move", body: `{"targetProjectId":2}`, status: http.StatusNotFound},
+		{name: "v2 storage failure", method: http.MethodGet, url: "/v2/projects/1/goods/500", status: http.StatusInternalServerError},
+		{name: "graphql", method: http.MethodPost, url: "/graphql", body: `{"query":"{ project(id: 1) { name goods { totalCount edges { node { name } } } } }"}`, status: http.StatusOK},
+		{name: "graphql with invalid query", method: http.MethodPost, url: "/graphql", body: `{"query":"{ project }"}`, status: http.StatusOK},
+		{name: "graphql without query", method: http.MethodPost, url: "/graphql", body: `{}`, status: http.StatusBadRequest},
+		{name: "graphql storage failure", method: http.MethodPost, url: "/graphql", body: `{"query":"{ good(projectId: 1, id: 500) { name } }"}`, status: http.StatusOK},
+		{name: "events with invalid Last-Event-ID", method: http.MethodGet, url: "/goods/events?projectId=1", header: http.Header{"Last-Event-Id": {"x"}}, status: http.StatusBadRequest},
+		{name: "ws without upgrade", method: http.MethodGet, url: "/goods/ws?projectId=1", status: http.StatusBadRequest},
+		{name: "ws without project", method: http.MethodGet, url: "/goods/ws", status: http.StatusBadRequest},
+		{name: "issue key", method: http.MethodPost, url: "/admin/keys", body: `{"name":"dashboard","projects":[{"projectId":1,"scope":"read"}]}`, status: http.StatusOK},
+		{name: "issue key with unknown scope", method: http.MethodPost, url: "/admin/keys", body: `{"name":"dashboard","projects":[{"projectId":1,"scope":"all"}]}`, status: http.StatusBadRequest},
+		{name: "revoke key", method: http.MethodDelete, url: "/admin/keys?id=1", status: http.StatusOK},
+		{name: "revoke missing key", method: http.MethodDelete, url: "/admin/keys?id=404", status: http.StatusNotFound},
+		{name: "compact", method: http.MethodPost, url: "/admin/compact?projectId=1", status: http.StatusOK},
+		{name: "compact missing project", method: http.MethodPost, url: "/admin/compact?projectId=404", status: http.StatusNotFound},
+		{name: "compact without admin", cfg: authCfg, method: http.MethodPost, url: "/admin/compact?projectId=1", status: http.StatusUnauthorized},
+		{name: "unauthorized", cfg: authCfg, method: http.MethodGet, url: "/good?id=1&projectId=1", status: http.StatusUnauthorized},
+		{name: "admin", cfg: authCfg, method: http.MethodGet, url: "/good?id=1&projectId=1", header: http.Header{"X-Api-Key": {"admin"}}, status: http.StatusOK},
+		{name: "ticket", cfg: authCfg, method: http.MethodPost, url: "/auth/tickets", header: http.Header{"X-Api-Key": {"admin"}}, status: http.StatusOK},
+		{name: "ticket without credentials", cfg: authCfg, method: http.MethodPost, url: "/auth/tickets", status: http.StatusUnauthorized},
+		{name: "events with unknown ticket", cfg: authCfg, method: http.MethodGet, url: "/goods/events?projectId=1&ticket=hzt_unknown", status: http.StatusUnauthorized},
+		{name: "healthz", method: http.MethodGet, url: "/healthz", status: http.StatusOK},
+		{name: "readyz", method: http.MethodGet, url: "/readyz", status: http.StatusOK},
+		{name: "metrics", method: http.MethodGet, url: "/metrics", status: http.StatusOK},
+		{name: "openapi", method: http.MethodGet, url: "/openapi.json", status: http.StatusOK},
+	}
+
+	handler, spec := newRouter(t, cfg)
+	authHandler, _ := newRouter(t, authCfg)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := handler
+			if tc.cfg == authCfg {
+				h = authHandler
+			}
+
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code, rr.Body.String())
+			require.NotContains(t, rr.Body.String(), "fake:", "storage error text reached the client")
+
+			route, pathParams, err := spec.Router.FindRoute(req)
+			require.NoError(t, err)
+
+			err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request:    req,
+					PathParams: pathParams,
+					Route:      route,
+				},
+				Status: rr.Code,
+				Header: rr.Header(),
+				Body:   io.NopCloser(bytes.NewReader(rr.Body.Bytes())),
+				Options: &openapi3filter.Options{
+					IncludeResponseStatus: true,
+				},
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestValidationErrorsLeaveOutSchema fails when a request error dumps the
+// schema and the value to the client.
+func TestValidationErrorsLeaveOutSchema(t *testing.T) {
+	handler, _ := newRouter(t, &config.Config{
+		OpenAPI: config.OpenAPI{ValidateRequests: true},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/good/create?projectId=1", strings.NewReader(`{"name":1}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), `Error at \"/name\"`)
+	require.NotContains(t, rr.Body.String(), "Schema:")
+}
+
+// TestEventsAreFlushed fails when a middleware buffers the event stream.
+func TestEventsAreFlushed(t *testing.T) {
+	handler, _ := newRouter(t, &config.Config{
+		Auth:    config.Auth{Enabled: false},
+		OpenAPI: config.OpenAPI{ValidateRequests: true, ValidateResponses: true},
+		Events:  config.Events{Heartbeat: time.Minute},
+	})
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/goods/events?projectId=1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "41")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() }) //nolint: errcheck
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	r := bufio.NewReader(resp.Body)
+
+	var lines []string
+	for len(lines) < 5 {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		lines = append(lines, line)
+	}
+
+	require.Equal(t, []string{": connected\n", "\n", "id: 42\n", "event: created\n", "data: {\"id\":1}\n"}, lines)
+}
+
+// TestEventsWithTicket fails when a browser, which cannot send headers
+// on an EventSource, has no way to open the stream.
+func TestEventsWithTicket(t *testing.T) {
+	handler, _ := newRouter(t, &config.Config{
+		Auth:    config.Auth{Enabled: true, AdminKey: "admin", TicketTTL: time.Minute},
+		OpenAPI: config.OpenAPI{ValidateRequests: true, ValidateResponses: true},
+		Events:  config.Events{Heartbeat: time.Minute},
+	})
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/auth/tickets", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", "admin")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	var issued struct {
+		Ticket string `json:"ticket"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&issued))
+	resp.Body.Close() //nolint: errcheck
+
+	resp, err = http.Get(srv.URL + "/goods/events?projectId=1&ticket=" + issued.Ticket)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() }) //nolint: errcheck
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, ": connected\n", line)
+
+	// The ticket works once.
+	again, err := http.Get(srv.URL + "/goods/events?projectId=1&ticket=" + issued.Ticket)
+	require.NoError(t, err)
+	again.Body.Close() //nolint: errcheck
+
+	require.Equal(t, http.StatusUnauthorized, again.StatusCode)
+}
+
+func TestReorderWithTicket(t *testing.T) {
+	handler, _ := newRouter(t, &config.Config{
+		Auth:    config.Auth{Enabled: true, AdminKey: "admin", TicketTTL: time.Minute},
+		OpenAPI: config.OpenAPI{ValidateRequests: true, ValidateResponses: true},
+		Events:  config.Events{Heartbeat: time.Minute},
+	})
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/auth/tickets", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", "admin")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	var issued struct {
+		Ticket string `json:"ticket"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&issued))
+	resp.Body.Close() //nolint: errcheck
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/goods/ws?projectId=1&ticket=" + issued.Ticket
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) //nolint: errcheck
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// The ticket works once.
+	_, resp, err = websocket.DefaultDialer.Dial(url, nil)
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	resp.Body.Close() //nolint: errcheck
+
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// TestReorderOverWebSocket fails when a middleware keeps the connection
+// from being hijacked.
+func TestReorderOverWebSocket(t *testing.T) {
+	handler, _ := newRouter(t, &config.Config{
+		Auth:    config.Auth{Enabled: false},
+		OpenAPI: config.OpenAPI{ValidateRequests: true, ValidateResponses: true},
+		Events:  config.Events{Heartbeat: time.Minute},
+	})
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/goods/ws?projectId=1", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) //nolint: errcheck
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	require.NoError(t, conn.WriteJSON(reorder.Command{Type: reorder.CommandReorder, RequestID: "r1", ID: 1, NewPriority: 2, Version: 1}))
+
+	// The good created in the project may arrive before the ack.
+	var msg reorder.Message
+	for msg.Type == "" || msg.Type == reorder.MessagePriorities {
+		require.NoError(t, conn.ReadJSON(&msg))
+	}
+
+	require.Equal(t, reorder.Message{
+		Type:       reorder.MessageAck,
+		RequestID:  "r1",
+		Priorities: []reorder.Priority{{ID: 1, Priority: 2, Version: 2}},
+	}, msg)
+}
@@ -0,0 +1,152 @@
+// Package problem writes errors as RFC 7807 problem details. Every problem
+// carries a stable machine-readable code, clients should match on it
+// rather than on title or detail.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+)
+
+const ContentType = "application/problem+json"
+
+type Code string
+
+const (
+	CodeInvalidRequest        Code = "invalid_request"
+	CodeValidationFailed      Code = "validation_failed"
+	CodeUnauthorized          Code = "unauthorized"
+	CodeForbidden             Code = "forbidden"
+	CodeNotFound              Code = "not_found"
+	CodeProjectNotFound       Code = "project_not_found"
+	CodeConflict              Code = "conflict"
+	CodeVersionConflict       Code = "version_conflict"
+	CodeRequestTooLarge       Code = "request_too_large"
+	CodeIdempotencyInProgress Code = "idempotency_in_progress"
+	CodeIdempotencyKeyReused  Code = "idempotency_key_reused"
+	CodeRateLimited           Code = "rate_limited"
+	CodeInternal              Code = "internal"
+)
+
+type kind struct {
+	status int
+	title  string
+}
+
+var kinds = map[Code]kind{
+	CodeInvalidRequest:        {http.StatusBadRequest, "Invalid request"},
+	CodeValidationFailed:      {http.StatusBadRequest, "Validation failed"},
+	CodeUnauthorized:          {http.StatusUnauthorized, "Unauthorized"},
+	CodeForbidden:             {http.StatusForbidden, "Forbidden"},
+	CodeNotFound:              {http.StatusNotFound, "Resource not found"},
+	CodeProjectNotFound:       {http.StatusNotFound, "Project not found"},
+	CodeConflict:              {http.StatusConflict, "Resource already exists"},
+	CodeVersionConflict:       {http.StatusConflict, "Resource was changed by another request"},
+	CodeRequestTooLarge:       {http.StatusRequestEntityTooLarge, "Request body too large"},
+	CodeIdempotencyInProgress: {http.StatusConflict, "Request with this Idempotency-Key is in progress"},
+	CodeIdempotencyKeyReused:  {http.StatusUnprocessableEntity, "Idempotency-Key was used with another request"},
+	CodeRateLimited:           {http.StatusTooManyRequests, "Too many requests"},
+	CodeInternal:              {http.StatusInternalServerError, "Internal error"},
+}
+
+// Problem is an RFC 7807 problem details object with the code and the
+// request id as extension members.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// New returns the problem of the code. Detail is sent to the client as
+// is, it must not contain internal error text.
+func New(code Code, detail string) *Problem {
+	k, ok := kinds[code]
+	if !ok {
+		panic(fmt.Sprintf("problem: unknown code %q", code))
+	}
+
+	return &Problem{
+		Type:   "urn:hezzl:problem:" + string(code),
+		Title:  k.title,
+		Status: k.status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// FromError maps storage errors to their problems. Any other error is
+// reported as internal without its text.
+func FromError(err error) *Problem {
+	switch {
+	case errors.Is(err, storageerr.ErrNotFound):
+		return New(CodeNotFound, "")
+	case errors.Is(err, storageerr.ErrProjectNotFound):
+		return New(CodeProjectNotFound, "")
+	case errors.Is(err, storageerr.ErrConflict):
+		return New(CodeConflict, "")
+	case errors.Is(err, storageerr.ErrVersionConflict):
+		return New(CodeVersionConflict, "")
+	case errors.Is(err, storageerr.ErrIncompleteOrder):
+		return New(CodeValidationFailed, "ids must list every good of the project that is not removed")
+	default:
+		return New(CodeInternal, "")
+	}
+}
+
+// Decode returns the problem of a request body that could not be read or
+// decoded. Decoding errors only describe the client's own input, so they
+// are sent as detail.
+func Decode(err error) *Problem {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return New(CodeRequestTooLarge, fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit))
+	}
+
+	return New(CodeInvalidRequest, err.Error())
+}
+
+// Validation returns the problem of a request body failing validation.
+func Validation(errs validator.ValidationErrors) *Problem {
+	msgs := make([]string, 0, len(errs))
+
+	for _, err := range errs {
+		switch err.ActualTag() {
+		case "required":
+			msgs = append(msgs, fmt.Sprintf("field %s is a required field", err.Field()))
+		case "max":
+			msgs = append(msgs, fmt.Sprintf("field %s must be at most %s characters long", err.Field(), err.Param()))
+		default:
+			msgs = append(msgs, fmt.Sprintf("field %s is not valid", err.Field()))
+		}
+	}
+
+	return New(CodeValidationFailed, strings.Join(msgs, ", "))
+}
+
+// Render writes the problem with its status.
+func Render(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+
+	//nolint: errcheck
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error writes the problem FromError maps err to.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	Render(w, r, FromError(err))
+}
@@ -0,0 +1,86 @@
+// Package request decodes and checks client input before it reaches
+// storage.
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrEmptyBody    = errors.New("request body is empty")
+	ErrTrailingData = errors.New("request body must contain a single JSON object")
+	ErrInvalidID    = errors.New("id must be a positive integer")
+)
+
+// Sanitizer is implemented by request bodies that clean up their fields
+// after decoding.
+type Sanitizer interface {
+	Sanitize()
+}
+
+// DecodeJSON decodes a single JSON value into v, rejecting unknown fields
+// and anything after the value. The body size is limited by the
+// RequestSize middleware, reading past it fails with *http.MaxBytesError.
+// If v is a Sanitizer it is sanitized.
+func DecodeJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return ErrEmptyBody
+		}
+
+		return err
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+
+		return ErrTrailingData
+	}
+
+	if s, ok := v.(Sanitizer); ok {
+		s.Sanitize()
+	}
+
+	return nil
+}
+
+// ParseID returns the id if s is a positive integer.
+func ParseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+
+	return id, nil
+}
+
+// Text normalizes s to NFC, drops control characters except line breaks
+// and tabs and trims surrounding whitespace.
+func Text(s string) string {
+	s = norm.NFC.String(s)
+
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+
+		return r
+	}, s)
+
+	return strings.TrimSpace(s)
+}
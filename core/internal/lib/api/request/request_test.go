@@ -0,0 +1,65 @@
+package request_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/api/request"
+	"github.com/stretchr/testify/require"
+)
+
+type body struct {
+	Name string `json:"name"`
+}
+
+func (b *body) Sanitize() {
+	b.Name = request.Text(b.Name)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "Valid", body: `{"name":"Mango"}`, want: "Mango"},
+		{name: "Sanitized", body: "{\"name\":\"  Cafe\u0301\\u0000 \"}", want: "Caf\u00e9"},
+		{name: "Empty", body: ``, wantErr: true},
+		{name: "Unknown field", body: `{"name":"Mango","price":1}`, wantErr: true},
+		{name: "Trailing data", body: `{"name":"Mango"} {"name":"Banana"}`, wantErr: true},
+		{name: "Too large", body: `{"name":"` + strings.Repeat("a", 64) + `"}`, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader(tc.body)), 32)
+
+			var b body
+
+			err := request.DecodeJSON(r, &b)
+			if tc.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, b.Name)
+		})
+	}
+}
+
+func TestParseID(t *testing.T) {
+	for _, s := range []string{"", "0", "-1", "1.5", "1; DROP TABLE goods"} {
+		_, err := request.ParseID(s)
+		require.ErrorIs(t, err, request.ErrInvalidID, s)
+	}
+
+	id, err := request.ParseID("42")
+	require.NoError(t, err)
+	require.Equal(t, 42, id)
+}
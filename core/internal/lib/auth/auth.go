@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+)
+
+// ActorAnonymous is the actor of requests served with authentication
+// disabled.
+const ActorAnonymous = "anonymous"
+
+const (
+	keyPrefix = "hzk_"
+	keyBytes  = 32
+)
+
+func (s Scope) Valid() bool {
+	return s == ScopeRead || s == ScopeWrite
+}
+
+// Allows reports whether a grant of scope s permits an action that needs
+// scope want. Write access implies read access.
+func (s Scope) Allows(want Scope) bool {
+	return s == ScopeWrite || s == want
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Actor identifies the caller in logs and change events, e.g. "api_key:12".
+	Actor string
+	// Admin may act on every project and manage API keys.
+	Admin    bool
+	Projects map[int]Scope
+}
+
+// Can reports whether the principal may perform an action of the given
+// scope on the project.
+func (p *Principal) Can(projectID int, scope Scope) bool {
+	if p.Admin {
+		return true
+	}
+
+	granted, ok := p.Projects[projectID]
+
+	return ok && granted.Allows(scope)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+
+	return p, ok
+}
+
+// GenerateKey returns a new random API key. Only its hash is meant to be
+// stored, the key itself is shown to the caller once.
+func GenerateKey() (string, error) {
+	buf := make([]byte, keyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+
+	return keyPrefix + hex.EncodeToString(buf), nil
+}
+
+// HashKey returns the hex encoded SHA-256 of the key. Keys are random, so a
+// fast hash without salt is enough to make a leaked table useless.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Actor returns the actor of the request principal, or an empty string for
+// unauthenticated contexts.
+func Actor(ctx context.Context) string {
+	if p, ok := FromContext(ctx); ok {
+		return p.Actor
+	}
+
+	return ""
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+)
+
+// ErrUnauthenticated is returned when an API key is missing, unknown or
+// revoked.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+type KeyFinder interface {
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+}
+
+// Authenticator resolves API keys and bearer tokens to principals. It is
+// shared by the HTTP and gRPC transports.
+type Authenticator struct {
+	keyFinder   KeyFinder
+	jwtVerifier *JWTVerifier
+	adminKey    string
+}
+
+// NewAuthenticator rejects bearer tokens when jwtVerifier is nil.
+func NewAuthenticator(keyFinder KeyFinder, jwtVerifier *JWTVerifier, adminKey string) *Authenticator {
+	return &Authenticator{
+		keyFinder:   keyFinder,
+		jwtVerifier: jwtVerifier,
+		adminKey:    adminKey,
+	}
+}
+
+func (a *Authenticator) Token(token string) (*Principal, error) {
+	if a.jwtVerifier == nil {
+		return nil, ErrNoVerificationKey
+	}
+
+	return a.jwtVerifier.Verify(token)
+}
+
+// Key returns ErrUnauthenticated for unknown keys and the storage error
+// when the lookup itself fails.
+func (a *Authenticator) Key(ctx context.Context, key string) (*Principal, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	if a.adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(a.adminKey)) == 1 {
+		return &Principal{Actor: "admin", Admin: true}, nil
+	}
+
+	apiKey, err := a.keyFinder.GetAPIKeyByHash(ctx, HashKey(key))
+	if errors.Is(err, storageerr.ErrNotFound) {
+		return nil, ErrUnauthenticated
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return principalFromKey(apiKey), nil
+}
+
+// BearerToken extracts the token from an Authorization header value.
+func BearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+func principalFromKey(key *models.APIKey) *Principal {
+	projects := make(map[int]Scope, len(key.Projects))
+	for _, p := range key.Projects {
+		projects[p.ProjectID] = Scope(p.Scope)
+	}
+
+	return &Principal{
+		Actor:    "api_key:" + strconv.Itoa(key.ID),
+		Projects: projects,
+	}
+}
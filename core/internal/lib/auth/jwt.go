@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Roles carried by the roles claim of a bearer token.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+var (
+	ErrNoVerificationKey = errors.New("neither jwt secret nor jwks file is configured")
+	ErrUnknownKey        = errors.New("token is signed with an unknown key")
+	ErrNoSubject         = errors.New("token has no subject")
+)
+
+// Claims of bearer tokens issued for the admin UI.
+type Claims struct {
+	jwt.RegisteredClaims
+
+	Projects []int    `json:"projects"`
+	Roles    []string `json:"roles"`
+}
+
+type JWTVerifier struct {
+	parser  *jwt.Parser
+	keyFunc jwt.Keyfunc
+}
+
+// NewJWTVerifier returns ErrNoVerificationKey when bearer tokens are not
+// configured at all.
+func NewJWTVerifier(cfg config.JWT) (*JWTVerifier, error) {
+	const op = "lib.auth.NewJWTVerifier"
+
+	opts := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	var keyFunc jwt.Keyfunc
+
+	switch {
+	case cfg.Secret != "":
+		secret := []byte(cfg.Secret)
+		keyFunc = func(*jwt.Token) (any, error) { return secret, nil }
+		opts = append(opts, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+
+	case cfg.JWKSFile != "":
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		keyFunc = func(t *jwt.Token) (any, error) {
+			kid, _ := t.Header["kid"].(string)
+
+			key, ok := keys[kid]
+			if !ok {
+				return nil, ErrUnknownKey
+			}
+
+			return key, nil
+		}
+		opts = append(opts, jwt.WithValidMethods([]string{
+			"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512",
+		}))
+
+	default:
+		return nil, ErrNoVerificationKey
+	}
+
+	return &JWTVerifier{
+		parser:  jwt.NewParser(opts...),
+		keyFunc: keyFunc,
+	}, nil
+}
+
+// Verify checks the token and maps its roles to a principal: viewers may
+// read the listed projects, editors may also write to them, and admins may
+// act on every project. A token without a subject is rejected, the
+// subject is the actor of every change made with it.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	var claims Claims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.keyFunc); err != nil {
+		return nil, fmt.Errorf("verify token: %w", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("verify token: %w", ErrNoSubject)
+	}
+
+	var scope Scope
+
+	principal := &Principal{Actor: "user:" + claims.Subject}
+
+	for _, role := range claims.Roles {
+		switch role {
+		case RoleAdmin:
+			principal.Admin = true
+		case RoleEditor:
+			scope = ScopeWrite
+		case RoleViewer:
+			if scope == "" {
+				scope = ScopeRead
+			}
+		}
+	}
+
+	if scope != "" {
+		principal.Projects = make(map[int]Scope, len(claims.Projects))
+		for _, projectID := range claims.Projects {
+			principal.Projects[projectID] = scope
+		}
+	}
+
+	return principal, nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS reads the public RSA and EC keys of a JWK set, indexed by kid.
+func loadJWKS(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwks: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("parse jwk %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			//nolint: err113
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		//nolint: err113
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode base64url: %w", err)
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+)
+
+const ticketPrefix = "hzt_"
+
+// TicketStore keeps tickets shared by every core instance, so a ticket
+// issued by one is redeemed by another.
+type TicketStore interface {
+	SaveTicket(ctx context.Context, ticketHash string, principal []byte, ttl time.Duration) error
+	// TakeTicket returns the principal of the ticket and deletes it, or
+	// storageerr.ErrNotFound for unknown and expired tickets.
+	TakeTicket(ctx context.Context, ticketHash string) ([]byte, error)
+}
+
+// Tickets lets a browser authenticate an EventSource or a WebSocket, which
+// cannot carry the Authorization or X-API-Key header. A ticket is issued to
+// an authenticated principal, passed in the URL and works once, within ttl.
+type Tickets struct {
+	store TicketStore
+	ttl   time.Duration
+}
+
+func NewTickets(store TicketStore, ttl time.Duration) *Tickets {
+	return &Tickets{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// TTL is how long an issued ticket may be redeemed.
+func (t *Tickets) TTL() time.Duration {
+	return t.ttl
+}
+
+// Issue returns a new ticket acting as p. Like API keys, only its hash is
+// stored.
+func (t *Tickets) Issue(ctx context.Context, p *Principal) (string, error) {
+	buf := make([]byte, keyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate ticket: %w", err)
+	}
+
+	ticket := ticketPrefix + hex.EncodeToString(buf)
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("marshal principal: %w", err)
+	}
+
+	if err := t.store.SaveTicket(ctx, HashKey(ticket), data, t.ttl); err != nil {
+		return "", fmt.Errorf("save ticket: %w", err)
+	}
+
+	return ticket, nil
+}
+
+// Redeem returns the principal of the ticket and makes it unusable. It
+// returns ErrUnauthenticated for unknown, used and expired tickets and the
+// storage error when the lookup itself fails.
+func (t *Tickets) Redeem(ctx context.Context, ticket string) (*Principal, error) {
+	ticket = strings.TrimSpace(ticket)
+	if !strings.HasPrefix(ticket, ticketPrefix) {
+		return nil, ErrUnauthenticated
+	}
+
+	data, err := t.store.TakeTicket(ctx, HashKey(ticket))
+	if errors.Is(err, storageerr.ErrNotFound) {
+		return nil, ErrUnauthenticated
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var p Principal
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("unmarshal principal: %w", err)
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,104 @@
+// Package dataloader batches lookups of single keys into one storage call,
+// so resolving a field on every item of a list does not cost a query per
+// item.
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FetchFunc returns the values of the keys. Keys missing from the map
+// load as the zero value.
+type FetchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader collects keys loaded within wait of the first one, or until
+// maxBatch keys are collected, and fetches them at once. Results are
+// cached for the lifetime of the loader, which is meant to be a single
+// request.
+type Loader[K comparable, V any] struct {
+	fetch    FetchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*result[V]
+	batch *batch[K, V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	once    sync.Once
+	keys    []K
+	results []*result[V]
+}
+
+func New[K comparable, V any](fetch FetchFunc[K, V], wait time.Duration, maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		cache:    make(map[K]*result[V]),
+	}
+}
+
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+
+	res, ok := l.cache[key]
+	if !ok {
+		res = &result[V]{done: make(chan struct{})}
+		l.cache[key] = res
+
+		b := l.batch
+		if b == nil {
+			b = &batch[K, V]{}
+			l.batch = b
+
+			time.AfterFunc(l.wait, func() { l.dispatch(ctx, b) })
+		}
+
+		b.keys = append(b.keys, key)
+		b.results = append(b.results, res)
+
+		if len(b.keys) >= l.maxBatch {
+			l.batch = nil
+
+			go l.dispatch(ctx, b)
+		}
+	}
+
+	l.mu.Unlock()
+
+	select {
+	case <-res.done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero V
+
+		return zero, ctx.Err()
+	}
+}
+
+func (l *Loader[K, V]) dispatch(ctx context.Context, b *batch[K, V]) {
+	b.once.Do(func() {
+		l.mu.Lock()
+		if l.batch == b {
+			l.batch = nil
+		}
+		l.mu.Unlock()
+
+		values, err := l.fetch(ctx, b.keys)
+
+		for i, res := range b.results {
+			res.value, res.err = values[b.keys[i]], err
+			close(res.done)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package dataloader_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/dataloader"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (r *recorder) fetch(_ context.Context, keys []int) (map[int]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sorted := append([]int(nil), keys...)
+	sort.Ints(sorted)
+	r.batches = append(r.batches, sorted)
+
+	values := make(map[int]string, len(keys))
+	for _, k := range keys {
+		if k%2 == 0 {
+			values[k] = "even"
+		}
+	}
+
+	return values, nil
+}
+
+func loadAll(t *testing.T, l *dataloader.Loader[int, string], keys ...int) []string {
+	t.Helper()
+
+	values := make([]string, len(keys))
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			values[i], errs[i] = l.Load(context.Background(), k)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	return values
+}
+
+func TestLoaderBatches(t *testing.T) {
+	var rec recorder
+	l := dataloader.New(rec.fetch, 10*time.Millisecond, 100)
+
+	values := loadAll(t, l, 1, 2, 3, 2)
+
+	require.Equal(t, []string{"", "even", "", "even"}, values)
+	require.Equal(t, [][]int{{1, 2, 3}}, rec.batches)
+
+	// Cached keys are not fetched again.
+	loadAll(t, l, 2, 4)
+	require.Equal(t, [][]int{{1, 2, 3}, {4}}, rec.batches)
+}
+
+func TestLoaderMaxBatch(t *testing.T) {
+	var rec recorder
+	l := dataloader.New(rec.fetch, time.Hour, 2)
+
+	loadAll(t, l, 1, 2)
+
+	require.Equal(t, [][]int{{1, 2}}, rec.batches)
+}
+
+func TestLoaderError(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	l := dataloader.New(func(context.Context, []int) (map[int]string, error) {
+		return nil, errFetch
+	}, time.Millisecond, 10)
+
+	_, err := l.Load(context.Background(), 1)
+	require.ErrorIs(t, err, errFetch)
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "core"
+
+// Label values of CacheRequests.
+const (
+	CacheHit   = "hit"
+	CacheStale = "stale"
+	CacheMiss  = "miss"
+)
+
+// Label values of ProducerMessages.
+const (
+	ProducerSuccess = "success"
+	ProducerFailure = "failure"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latencies by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	PostgresDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "postgres",
+		Name:      "query_duration_seconds",
+		Help:      "Postgres query latencies by storage method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"op"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache lookups by cache and result: hit, stale or miss.",
+	}, []string{"cache", "result"})
+
+	ProducerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "producer",
+		Name:      "messages_total",
+		Help:      "Messages published to NATS by mode and result.",
+	}, []string{"mode", "result"})
+)
+
+// ObservePostgres measures a storage method, use it as
+// defer metrics.ObservePostgres(op)().
+func ObservePostgres(op string) func() {
+	start := time.Now()
+
+	return func() {
+		PostgresDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	}
+}
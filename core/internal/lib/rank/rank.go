@@ -0,0 +1,150 @@
+// Package rank orders goods by strings instead of integers. A rank is a
+// base 36 fraction written without "0." and without trailing zeros, so
+// ranks compare as strings in byte order (COLLATE "C" in Postgres) and a
+// new rank fits between any two, moving a good rewrites only its own row.
+package rank
+
+import (
+	"strings"
+)
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+const (
+	// width is the number of leading digits new goods at either end of a
+	// project are counted in, step the distance between them. It leaves
+	// room for hundreds of thousands of goods added at an end one by one,
+	// and for about ten moves into the gap between two of them before the
+	// ranks get any longer.
+	width = 6
+	step  = base * base
+)
+
+// MaxLength is the length past which the ranks of a project are spread
+// again. Moves into the same gap make ranks one digit longer about every
+// five moves.
+const MaxLength = 16
+
+// Between returns a rank after prev and before next. An empty prev means
+// the start of the order, an empty next its end. Two goods moved into the
+// same gap at once share a rank; if next is not after prev, the rank goes
+// right after prev.
+func Between(prev, next string) string {
+	if next != "" && next <= prev {
+		next = prev + "1"
+	}
+
+	switch {
+	case prev == "" && next == "":
+		return midpoint("", "")
+	case next == "":
+		if v := head(prev) + step; v < span(width) {
+			return format(v, width)
+		}
+	case prev == "":
+		if v := head(next) - step; v > 0 {
+			return format(v, width)
+		}
+	}
+
+	return midpoint(prev, next)
+}
+
+// Spread returns n ranks in order, evenly spaced and as short as the
+// count allows.
+func Spread(n int) []string {
+	w := width
+	for span(w) < 2*(n+1) {
+		w++
+	}
+
+	gap := span(w) / (n + 1)
+
+	ranks := make([]string, n)
+	for i := range ranks {
+		ranks[i] = format(gap*(i+1), w)
+	}
+
+	return ranks
+}
+
+// midpoint returns the shortest rank halfway between prev and next, or
+// right after prev when their digits are adjacent.
+func midpoint(prev, next string) string {
+	var b strings.Builder
+
+	for i := 0; ; i++ {
+		p := digit(prev, i)
+
+		n := base
+		if next != "" {
+			n = digit(next, i)
+		}
+
+		if p == n {
+			b.WriteByte(digits[p])
+
+			continue
+		}
+
+		if n-p > 1 {
+			b.WriteByte(digits[(p+n)/2])
+
+			return b.String()
+		}
+
+		// The digits are adjacent. If next goes on, its digit alone is
+		// already before it and after prev.
+		if next != "" && i+1 < len(next) {
+			b.WriteByte(digits[n])
+
+			return b.String()
+		}
+
+		// Otherwise keep the digit of prev and find room after the rest of
+		// it.
+		b.WriteByte(digits[p])
+		next = ""
+	}
+}
+
+// head returns the first width digits of r as a number.
+func head(r string) int {
+	v := 0
+	for i := range width {
+		v = v*base + digit(r, i)
+	}
+
+	return v
+}
+
+func digit(r string, i int) int {
+	if i >= len(r) {
+		return 0
+	}
+
+	return strings.IndexByte(digits, r[i])
+}
+
+func span(w int) int {
+	s := 1
+	for range w {
+		s *= base
+	}
+
+	return s
+}
+
+// format writes v as w digits and drops trailing zeros, which do not
+// change the fraction.
+func format(v, w int) string {
+	buf := make([]byte, w)
+	for i := w - 1; i >= 0; i-- {
+		buf[i] = digits[v%base]
+		v /= base
+	}
+
+	return strings.TrimRight(string(buf), "0")
+}
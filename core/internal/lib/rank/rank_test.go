@@ -0,0 +1,67 @@
+package rank_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/rank"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBetween(t *testing.T) {
+	cases := []struct {
+		prev, next, want string
+	}{
+		{"", "", "i"},
+		{"i", "", "i001"},
+		{"i001", "", "i002"},
+		{"", "i", "hzzz"},
+		{"", "00001", "00000i"},
+		{"a", "b", "ai"},
+		{"a", "b1", "b"},
+		{"az", "b", "azi"},
+		{"a1", "a2", "a1i"},
+		{"zzzzzz", "", "zzzzzzi"},
+		{"a1", "a1", "a10i"},
+	}
+
+	for _, tc := range cases {
+		got := rank.Between(tc.prev, tc.next)
+		require.Equal(t, tc.want, got, "between %q and %q", tc.prev, tc.next)
+	}
+}
+
+func TestBetweenKeepsOrder(t *testing.T) {
+	// Inserting again and again right after the first rank is the worst case
+	// for the length of the ranks.
+	ranks := []string{"i", "i001"}
+	for range 200 {
+		ranks = append(ranks[:1], append([]string{rank.Between(ranks[0], ranks[1])}, ranks[1:]...)...)
+	}
+
+	for i := 1; i < len(ranks); i++ {
+		require.Less(t, ranks[i-1], ranks[i])
+		require.False(t, strings.HasSuffix(ranks[i], "0"))
+	}
+
+	require.LessOrEqual(t, len(ranks[1]), 6+200/5)
+}
+
+func TestSpread(t *testing.T) {
+	require.Empty(t, rank.Spread(0))
+	require.Equal(t, []string{"i"}, rank.Spread(1))
+
+	ranks := rank.Spread(1000)
+	require.Len(t, ranks, 1000)
+	require.True(t, sort.StringsAreSorted(ranks))
+
+	for i, r := range ranks {
+		require.LessOrEqual(t, len(r), 6)
+		require.False(t, strings.HasSuffix(r, "0"))
+
+		if i > 0 {
+			require.NotEqual(t, ranks[i-1], r)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Package ratelimit holds what the HTTP middleware and the gRPC interceptor
+// limiting requests have in common.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+)
+
+// ClientKey is the actor of the request, or the host of remoteAddr for
+// anonymous requests.
+func ClientKey(ctx context.Context, remoteAddr string) string {
+	if actor := auth.Actor(ctx); actor != "" && actor != auth.ActorAnonymous {
+		return actor
+	}
+
+	if remoteAddr == "" {
+		return "ip:unknown"
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// Seconds rounds up, so clients never retry too early.
+func Seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
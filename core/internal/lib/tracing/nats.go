@@ -0,0 +1,27 @@
+package tracing
+
+import (
+	"github.com/nats-io/nats.go"
+)
+
+// NATSHeaderCarrier adapts NATS message headers to propagation.TextMapCarrier.
+// Unlike propagation.HeaderCarrier it keeps keys as they are, since NATS
+// headers are case-sensitive.
+type NATSHeaderCarrier nats.Header
+
+func (c NATSHeaderCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+func (c NATSHeaderCarrier) Set(key, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c NATSHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
@@ -10,4 +10,106 @@ type Good struct {
 	Priority    int       `json:"priority"`
 	Removed     bool      `json:"removed"`
 	CreatedAt   time.Time `json:"createdAt"`
+	// Version grows by one on every change of the good. Moving other
+	// goods shifts its priority but not its version.
+	Version int `json:"version"`
+}
+
+// CachedGood is a good looked up in cache. Good is nil on a cache miss,
+// Version is the cache version of the project to save the good under.
+type CachedGood struct {
+	Version int64
+	Good    *Good
+}
+
+type APIKey struct {
+	ID        int            `json:"id"`
+	Name      string         `json:"name"`
+	Projects  []ProjectScope `json:"projects"`
+	CreatedAt time.Time      `json:"createdAt"`
+	RevokedAt *time.Time     `json:"revokedAt,omitempty"`
+}
+
+type ProjectScope struct {
+	ProjectID int    `json:"projectId"`
+	Scope     string `json:"scope"`
+}
+
+// RateLimitResult is the result of taking a request out of a client's
+// limit.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is the time until the next request is allowed.
+	RetryAfter time.Duration
+	// Reset is the time until the limit is fully restored.
+	Reset time.Duration
+}
+
+// IdempotencyRecord is what is stored under an idempotency key. Done is
+// false while the first request with the key is still in progress.
+type IdempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Done        bool   `json:"done"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Types of GoodEvent.
+const (
+	EventCreated       = "created"
+	EventUpdated       = "updated"
+	EventRemoved       = "removed"
+	EventReprioritized = "reprioritized"
+	EventMoved         = "moved"
+)
+
+// GoodEvent is published to NATS on every change of a good. Good is
+// embedded, so the payload stays a flat good with extra fields.
+type GoodEvent struct {
+	Good
+	Type  string `json:"type,omitempty"`
+	Actor string `json:"actor,omitempty"`
+	// FromProjectID is the project a moved good left. Together with its id
+	// it is the old identity of the good.
+	FromProjectID int `json:"fromProjectId,omitempty"`
+}
+
+// HistoryEvent is a GoodEvent as the listener stored it in ClickHouse.
+// Only the fields of the good it stores are set. Time is when it was
+// stored, to the second.
+type HistoryEvent struct {
+	GoodEvent
+	Time time.Time
+}
+
+type Project struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GoodFilter selects a page of goods of a project. Goods are ordered by
+// priority, then id, and paged by the position of the last seen good.
+type GoodFilter struct {
+	// Name matches goods whose name contains it, ignoring case.
+	Name           string
+	IncludeRemoved bool
+	First          int
+	After          *GoodCursor
+}
+
+// GoodCursor is the position of a good in the order of GoodFilter.
+type GoodCursor struct {
+	Priority int
+	ID       int
+}
+
+type GoodPage struct {
+	Goods []Good
+	// Total is the number of goods matching the filter on all pages.
+	Total   int
+	HasNext bool
 }
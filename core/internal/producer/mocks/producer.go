@@ -10,6 +10,7 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -40,29 +41,29 @@ func (m *MockProducerInterface) EXPECT() *MockProducerInterfaceMockRecorder {
 }
 
 // Send mocks base method.
-func (m *MockProducerInterface) Send(data []byte) error {
+func (m *MockProducerInterface) Send(ctx context.Context, data []byte) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Send", data)
+	ret := m.ctrl.Call(m, "Send", ctx, data)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Send indicates an expected call of Send.
-func (mr *MockProducerInterfaceMockRecorder) Send(data any) *gomock.Call {
+func (mr *MockProducerInterfaceMockRecorder) Send(ctx, data any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockProducerInterface)(nil).Send), data)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockProducerInterface)(nil).Send), ctx, data)
 }
 
 // SendAsync mocks base method.
-func (m *MockProducerInterface) SendAsync(data []byte) error {
+func (m *MockProducerInterface) SendAsync(ctx context.Context, data []byte) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendAsync", data)
+	ret := m.ctrl.Call(m, "SendAsync", ctx, data)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SendAsync indicates an expected call of SendAsync.
-func (mr *MockProducerInterfaceMockRecorder) SendAsync(data any) *gomock.Call {
+func (mr *MockProducerInterfaceMockRecorder) SendAsync(ctx, data any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendAsync", reflect.TypeOf((*MockProducerInterface)(nil).SendAsync), data)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendAsync", reflect.TypeOf((*MockProducerInterface)(nil).SendAsync), ctx, data)
 }
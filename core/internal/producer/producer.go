@@ -1,17 +1,29 @@
 package producer
 
 import (
+	"context"
 	"fmt"
 	"github.com/Gonnekone/hezzl-test/core/internal/config"
 	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/metrics"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/tracing"
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
+	"time"
 )
 
+// asyncAckTimeout bounds the wait for the ack of an async message, so every
+// message is eventually counted as published or failed.
+const asyncAckTimeout = 10 * time.Second
+
 //go:generate mockgen -source=producer.go -destination=mocks/producer.go -package=mocks
 type ProducerInterface interface {
-	Send(data []byte) error
-	SendAsync(data []byte) error
+	Send(ctx context.Context, data []byte) error
+	SendAsync(ctx context.Context, data []byte) error
 }
 
 type Producer struct {
@@ -30,7 +42,7 @@ func New(log *slog.Logger, cfg config.Nats) (*Producer, error) {
 		return nil, fmt.Errorf("%s: connect to NATS: %w", op, err)
 	}
 
-	js, err := nc.JetStream()
+	js, err := nc.JetStream(nats.PublishAsyncTimeout(asyncAckTimeout))
 	if err != nil {
 		nc.Close()
 		return nil, fmt.Errorf("%s: create JetStream context: %w", op, err)
@@ -44,15 +56,39 @@ func New(log *slog.Logger, cfg config.Nats) (*Producer, error) {
 	}, nil
 }
 
+// Ping makes a round trip to the NATS server.
+func (p *Producer) Ping(ctx context.Context) error {
+	const op = "producer.Ping"
+
+	if err := p.nc.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// JetStream is shared with the events reading the stream back.
+func (p *Producer) JetStream() nats.JetStreamContext {
+	return p.js
+}
+
 func (p *Producer) Close() {
 	if p.nc != nil {
 		p.nc.Close()
 	}
 }
 
-func (p *Producer) Send(data []byte) error {
-	ack, err := p.js.Publish(p.cfg.Subject, data)
+func (p *Producer) Send(ctx context.Context, data []byte) error {
+	ctx, span := p.startSpan(ctx)
+	defer span.End()
+
+	ack, err := p.js.PublishMsg(p.newMsg(ctx, data))
 	if err != nil {
+		metrics.ProducerMessages.WithLabelValues("sync", metrics.ProducerFailure).Inc()
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+
 		p.log.Error("failed to publish message",
 			sl.Err(err),
 			slog.String("subject", p.cfg.Subject),
@@ -60,6 +96,8 @@ func (p *Producer) Send(data []byte) error {
 		return fmt.Errorf("publish message to %s: %w", p.cfg.Subject, err)
 	}
 
+	metrics.ProducerMessages.WithLabelValues("sync", metrics.ProducerSuccess).Inc()
+
 	p.log.Debug("message published",
 		slog.String("subject", p.cfg.Subject),
 		slog.String("string", ack.Stream),
@@ -69,9 +107,17 @@ func (p *Producer) Send(data []byte) error {
 	return nil
 }
 
-func (p *Producer) SendAsync(data []byte) error {
-	_, err := p.js.PublishAsync(p.cfg.Subject, data)
+func (p *Producer) SendAsync(ctx context.Context, data []byte) error {
+	ctx, span := p.startSpan(ctx)
+
+	future, err := p.js.PublishMsgAsync(p.newMsg(ctx, data))
 	if err != nil {
+		metrics.ProducerMessages.WithLabelValues("async", metrics.ProducerFailure).Inc()
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		span.End()
+
 		p.log.Error("failed to publish async message",
 			sl.Err(err),
 			slog.String("subject", p.cfg.Subject),
@@ -79,6 +125,48 @@ func (p *Producer) SendAsync(data []byte) error {
 		return fmt.Errorf("publish async message to %s: %w", p.cfg.Subject, err)
 	}
 
+	// The span lasts until the message is acknowledged.
+	go func() {
+		defer span.End()
+
+		select {
+		case <-future.Ok():
+			metrics.ProducerMessages.WithLabelValues("async", metrics.ProducerSuccess).Inc()
+		case err := <-future.Err():
+			metrics.ProducerMessages.WithLabelValues("async", metrics.ProducerFailure).Inc()
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "not acknowledged")
+
+			p.log.Error("async message was not acknowledged",
+				sl.Err(err),
+				slog.String("subject", p.cfg.Subject),
+			)
+		}
+	}()
+
 	p.log.Debug("async message published", slog.String("subject", p.cfg.Subject))
 	return nil
 }
+
+func (p *Producer) startSpan(ctx context.Context) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, p.cfg.Subject+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("nats"),
+			semconv.MessagingDestinationName(p.cfg.Subject),
+			semconv.MessagingOperationTypePublish,
+		),
+	)
+}
+
+// newMsg carries the trace context of ctx in the message headers, so the
+// listener continues the same trace.
+func (p *Producer) newMsg(ctx context.Context, data []byte) *nats.Msg {
+	msg := nats.NewMsg(p.cfg.Subject)
+	msg.Data = data
+
+	otel.GetTextMapPropagator().Inject(ctx, tracing.NATSHeaderCarrier(msg.Header))
+
+	return msg
+}
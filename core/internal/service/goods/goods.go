@@ -0,0 +1,255 @@
+// Package goods is the write path of goods shared by every API: REST v1
+// and v2, gRPC, GraphQL, the WebSocket channel and the compaction job. A
+// change is written to storage, the cached lists and goods it outdates are
+// dropped and an event is published for every changed good.
+//
+// Once the change is committed the write succeeds. Failing to drop the
+// cache is only logged, the hard TTL of cached entries bounds how long
+// they stay stale, and the event is published anyway.
+package goods
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/auth"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/producer"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+//go:generate mockgen -source=goods.go -destination=mocks/Storage.go -package=mocks
+type Storage interface {
+	SaveGood(ctx context.Context, name string, projectID string) (*models.Good, error)
+	UpdateGood(ctx context.Context, id string, projectID string, name string, desc string) (*models.Good, error)
+	DeleteGood(ctx context.Context, id string, projectID string) (*models.Good, error)
+	UpdateGoodsPriority(ctx context.Context, id string, projectID string, priority int) ([]models.Good, error)
+	UpdateGoodsPriorityIfVersion(
+		ctx context.Context,
+		id string,
+		projectID string,
+		priority int,
+		version int,
+	) ([]models.Good, error)
+	ReorderGoods(ctx context.Context, projectID string, ids []int) ([]models.Good, error)
+	MoveGood(ctx context.Context, id string, projectID string, targetProjectID string) (*models.Good, error)
+	CompactGoods(ctx context.Context, projectID string) ([]models.Good, error)
+	InvalidList(ctx context.Context, projectID string) error
+	InvalidGoods(ctx context.Context, goods ...models.Good) error
+}
+
+type Service struct {
+	log      *slog.Logger
+	storage  Storage
+	producer producer.ProducerInterface
+}
+
+func New(log *slog.Logger, storage Storage, producer producer.ProducerInterface) *Service {
+	return &Service{
+		log:      log,
+		storage:  storage,
+		producer: producer,
+	}
+}
+
+// Create adds a good named name last in the project.
+func (s *Service) Create(ctx context.Context, name string, projectID string) (*models.Good, error) {
+	const op = "service.goods.Create"
+
+	good, err := s.storage.SaveGood(ctx, name, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.invalidate(ctx, op, []string{projectID})
+
+	s.publish(ctx, op, models.GoodEvent{Good: *good, Type: models.EventCreated})
+
+	return good, nil
+}
+
+// Update renames the good, and changes its description unless desc is
+// empty.
+func (s *Service) Update(ctx context.Context, id, projectID, name, desc string) (*models.Good, error) {
+	const op = "service.goods.Update"
+
+	good, err := s.storage.UpdateGood(ctx, id, projectID, name, desc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.invalidate(ctx, op, []string{projectID}, *good)
+
+	s.publish(ctx, op, models.GoodEvent{Good: *good, Type: models.EventUpdated})
+
+	return good, nil
+}
+
+// Remove marks the good removed.
+func (s *Service) Remove(ctx context.Context, id, projectID string) (*models.Good, error) {
+	const op = "service.goods.Remove"
+
+	good, err := s.storage.DeleteGood(ctx, id, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.invalidate(ctx, op, []string{projectID}, *good)
+
+	s.publish(ctx, op, models.GoodEvent{Good: *good, Type: models.EventRemoved})
+
+	return good, nil
+}
+
+// Reprioritize moves the good to position priority of its project, see
+// PostgresStorage.UpdateGoodsPriority. It returns the goods whose
+// priority changed.
+func (s *Service) Reprioritize(ctx context.Context, id, projectID string, priority int) ([]models.Good, error) {
+	const op = "service.goods.Reprioritize"
+
+	goods, err := s.storage.UpdateGoodsPriority(ctx, id, projectID, priority)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.reprioritized(ctx, op, projectID, goods), nil
+}
+
+// ReprioritizeIfVersion moves the good like Reprioritize while its version
+// is still version, see PostgresStorage.UpdateGoodsPriorityIfVersion.
+func (s *Service) ReprioritizeIfVersion(
+	ctx context.Context,
+	id string,
+	projectID string,
+	priority int,
+	version int,
+) ([]models.Good, error) {
+	const op = "service.goods.ReprioritizeIfVersion"
+
+	goods, err := s.storage.UpdateGoodsPriorityIfVersion(ctx, id, projectID, priority, version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.reprioritized(ctx, op, projectID, goods), nil
+}
+
+// Reorder gives the goods of the project priorities in the order of ids,
+// see PostgresStorage.ReorderGoods. It returns the goods whose priority
+// changed.
+func (s *Service) Reorder(ctx context.Context, projectID string, ids []int) ([]models.Good, error) {
+	const op = "service.goods.Reorder"
+
+	goods, err := s.storage.ReorderGoods(ctx, projectID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.reprioritized(ctx, op, projectID, goods), nil
+}
+
+// Compact spreads the ranks of the project evenly again, see
+// PostgresStorage.CompactGoods. The order of the goods stays the same, so
+// no priority changes and no good is returned or published.
+func (s *Service) Compact(ctx context.Context, projectID string) ([]models.Good, error) {
+	const op = "service.goods.Compact"
+
+	goods, err := s.storage.CompactGoods(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.reprioritized(ctx, op, projectID, goods), nil
+}
+
+// Move moves the good to the end of the target project. The cached lists
+// of both projects and the good under both its identities are dropped,
+// and the moved event carries the project the good left.
+func (s *Service) Move(ctx context.Context, id, projectID, targetProjectID string) (*models.Good, error) {
+	const op = "service.goods.Move"
+
+	fromProjectID, err := strconv.Atoi(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid project id: %w", op, err)
+	}
+
+	good, err := s.storage.MoveGood(ctx, id, projectID, targetProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	old := *good
+	old.ProjectID = fromProjectID
+
+	s.invalidate(ctx, op, []string{projectID, targetProjectID}, old, *good)
+
+	s.publish(ctx, op, models.GoodEvent{Good: *good, Type: models.EventMoved, FromProjectID: fromProjectID})
+
+	return good, nil
+}
+
+// reprioritized invalidates and publishes the goods whose priority changed.
+func (s *Service) reprioritized(ctx context.Context, op, projectID string, goods []models.Good) []models.Good {
+	s.invalidate(ctx, op, []string{projectID}, goods...)
+
+	events := make([]models.GoodEvent, 0, len(goods))
+	for _, good := range goods {
+		events = append(events, models.GoodEvent{Good: good, Type: models.EventReprioritized})
+	}
+
+	s.publish(ctx, op, events...)
+
+	return goods
+}
+
+// invalidate drops the cached copies of the goods and the cached lists of
+// the projects. Goods are cached under the version of their project, so
+// they are deleted before InvalidList bumps it: afterwards their keys are
+// out of reach, and a failed bump would leave them served. Failures are
+// only logged, see the package doc.
+func (s *Service) invalidate(ctx context.Context, op string, projectIDs []string, goods ...models.Good) {
+	log := s.logger(ctx, op)
+
+	if len(goods) > 0 {
+		if err := s.storage.InvalidGoods(ctx, goods...); err != nil {
+			log.Warn("failed to invalid cached goods", sl.Err(err))
+		}
+	}
+
+	for _, projectID := range projectIDs {
+		if err := s.storage.InvalidList(ctx, projectID); err != nil {
+			log.Warn("failed to invalid cached list", sl.Err(err), slog.String("project_id", projectID))
+		}
+	}
+}
+
+// publish sends the events to NATS on behalf of the actor of ctx. Failures
+// are only logged, the change is already stored.
+func (s *Service) publish(ctx context.Context, op string, events ...models.GoodEvent) {
+	log := s.logger(ctx, op)
+
+	for _, event := range events {
+		event.Actor = auth.Actor(ctx)
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Warn("failed to marshal data", sl.Err(err))
+		}
+
+		if err := s.producer.SendAsync(ctx, data); err != nil {
+			log.Warn("failed to send message to nats", sl.Err(err))
+		}
+	}
+}
+
+func (s *Service) logger(ctx context.Context, op string) *slog.Logger {
+	return s.log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(ctx)),
+		slog.String("actor", auth.Actor(ctx)),
+	)
+}
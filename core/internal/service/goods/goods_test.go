@@ -0,0 +1,36 @@
+package goods_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/handlers/slogdiscard"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	producer_mocks "github.com/Gonnekone/hezzl-test/core/internal/producer/mocks"
+	"github.com/Gonnekone/hezzl-test/core/internal/service/goods"
+	"github.com/Gonnekone/hezzl-test/core/internal/service/goods/mocks"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// TestGoodsAreInvalidatedBeforeTheList fails when the version of the
+// project is bumped first, the good keys under the old version would then
+// be left in the cache.
+func TestGoodsAreInvalidatedBeforeTheList(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	storage := mocks.NewMockStorage(ctrl)
+	producer := producer_mocks.NewMockProducerInterface(ctrl)
+
+	good := &models.Good{ID: 1, ProjectID: 1, Name: "Apple", Version: 2}
+
+	gomock.InOrder(
+		storage.EXPECT().UpdateGood(gomock.Any(), "1", "1", "Apple", "").Return(good, nil),
+		storage.EXPECT().InvalidGoods(gomock.Any(), *good).Return(nil),
+		storage.EXPECT().InvalidList(gomock.Any(), "1").Return(nil),
+	)
+	producer.EXPECT().SendAsync(gomock.Any(), gomock.Any()).Return(nil)
+
+	_, err := goods.New(slogdiscard.NewDiscardLogger(), storage, producer).Update(context.Background(), "1", "1", "Apple", "")
+	require.NoError(t, err)
+}
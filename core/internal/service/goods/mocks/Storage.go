@@ -0,0 +1,195 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: goods.go
+//
+// Generated by this command:
+//
+//	mockgen -source=goods.go -destination=mocks/Storage.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/Gonnekone/hezzl-test/core/internal/models"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockStorage is a mock of Storage interface.
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage.
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance.
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// CompactGoods mocks base method.
+func (m *MockStorage) CompactGoods(ctx context.Context, projectID string) ([]models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompactGoods", ctx, projectID)
+	ret0, _ := ret[0].([]models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompactGoods indicates an expected call of CompactGoods.
+func (mr *MockStorageMockRecorder) CompactGoods(ctx, projectID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompactGoods", reflect.TypeOf((*MockStorage)(nil).CompactGoods), ctx, projectID)
+}
+
+// DeleteGood mocks base method.
+func (m *MockStorage) DeleteGood(ctx context.Context, id, projectID string) (*models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGood", ctx, id, projectID)
+	ret0, _ := ret[0].(*models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteGood indicates an expected call of DeleteGood.
+func (mr *MockStorageMockRecorder) DeleteGood(ctx, id, projectID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGood", reflect.TypeOf((*MockStorage)(nil).DeleteGood), ctx, id, projectID)
+}
+
+// InvalidGoods mocks base method.
+func (m *MockStorage) InvalidGoods(ctx context.Context, goods ...models.Good) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range goods {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "InvalidGoods", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidGoods indicates an expected call of InvalidGoods.
+func (mr *MockStorageMockRecorder) InvalidGoods(ctx any, goods ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, goods...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidGoods", reflect.TypeOf((*MockStorage)(nil).InvalidGoods), varargs...)
+}
+
+// InvalidList mocks base method.
+func (m *MockStorage) InvalidList(ctx context.Context, projectID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidList", ctx, projectID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidList indicates an expected call of InvalidList.
+func (mr *MockStorageMockRecorder) InvalidList(ctx, projectID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidList", reflect.TypeOf((*MockStorage)(nil).InvalidList), ctx, projectID)
+}
+
+// MoveGood mocks base method.
+func (m *MockStorage) MoveGood(ctx context.Context, id, projectID, targetProjectID string) (*models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveGood", ctx, id, projectID, targetProjectID)
+	ret0, _ := ret[0].(*models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MoveGood indicates an expected call of MoveGood.
+func (mr *MockStorageMockRecorder) MoveGood(ctx, id, projectID, targetProjectID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveGood", reflect.TypeOf((*MockStorage)(nil).MoveGood), ctx, id, projectID, targetProjectID)
+}
+
+// ReorderGoods mocks base method.
+func (m *MockStorage) ReorderGoods(ctx context.Context, projectID string, ids []int) ([]models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReorderGoods", ctx, projectID, ids)
+	ret0, _ := ret[0].([]models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReorderGoods indicates an expected call of ReorderGoods.
+func (mr *MockStorageMockRecorder) ReorderGoods(ctx, projectID, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReorderGoods", reflect.TypeOf((*MockStorage)(nil).ReorderGoods), ctx, projectID, ids)
+}
+
+// SaveGood mocks base method.
+func (m *MockStorage) SaveGood(ctx context.Context, name, projectID string) (*models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveGood", ctx, name, projectID)
+	ret0, _ := ret[0].(*models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveGood indicates an expected call of SaveGood.
+func (mr *MockStorageMockRecorder) SaveGood(ctx, name, projectID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveGood", reflect.TypeOf((*MockStorage)(nil).SaveGood), ctx, name, projectID)
+}
+
+// UpdateGood mocks base method.
+func (m *MockStorage) UpdateGood(ctx context.Context, id, projectID, name, desc string) (*models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGood", ctx, id, projectID, name, desc)
+	ret0, _ := ret[0].(*models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateGood indicates an expected call of UpdateGood.
+func (mr *MockStorageMockRecorder) UpdateGood(ctx, id, projectID, name, desc any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGood", reflect.TypeOf((*MockStorage)(nil).UpdateGood), ctx, id, projectID, name, desc)
+}
+
+// UpdateGoodsPriority mocks base method.
+func (m *MockStorage) UpdateGoodsPriority(ctx context.Context, id, projectID string, priority int) ([]models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGoodsPriority", ctx, id, projectID, priority)
+	ret0, _ := ret[0].([]models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateGoodsPriority indicates an expected call of UpdateGoodsPriority.
+func (mr *MockStorageMockRecorder) UpdateGoodsPriority(ctx, id, projectID, priority any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGoodsPriority", reflect.TypeOf((*MockStorage)(nil).UpdateGoodsPriority), ctx, id, projectID, priority)
+}
+
+// UpdateGoodsPriorityIfVersion mocks base method.
+func (m *MockStorage) UpdateGoodsPriorityIfVersion(ctx context.Context, id, projectID string, priority, version int) ([]models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGoodsPriorityIfVersion", ctx, id, projectID, priority, version)
+	ret0, _ := ret[0].([]models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateGoodsPriorityIfVersion indicates an expected call of UpdateGoodsPriorityIfVersion.
+func (mr *MockStorageMockRecorder) UpdateGoodsPriorityIfVersion(ctx, id, projectID, priority, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGoodsPriorityIfVersion", reflect.TypeOf((*MockStorage)(nil).UpdateGoodsPriorityIfVersion), ctx, id, projectID, priority, version)
+}
@@ -0,0 +1,196 @@
+// Package clickhouse reads the history of goods the listener of
+// clickhouse-service writes to the hezzl.goods table. Core never writes
+// to ClickHouse.
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/tracing"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const historyColumns = `Id, ProjectId, Name, Description, Priority, Removed, Actor, Type, FromProjectId, EventTime`
+
+// Events of the same second are ordered by id only, the table keeps no
+// finer order.
+const (
+	projectsHistoryQuery = `
+		SELECT ` + historyColumns + `
+		FROM hezzl.goods
+		WHERE ProjectId IN ?
+		ORDER BY ProjectId, EventTime DESC, Id DESC
+		LIMIT ? BY ProjectId
+	`
+	goodsHistoryQuery = `
+		SELECT ` + historyColumns + `
+		FROM hezzl.goods
+		WHERE Id IN ?
+		ORDER BY Id, EventTime DESC
+		LIMIT ? BY Id
+	`
+)
+
+type ClickHouseStorage struct {
+	db driver.Conn
+}
+
+// New opens a connection pool. It connects lazily, so ClickHouse being
+// down only fails the history queries.
+func New(cfg config.History) (*ClickHouseStorage, error) {
+	const op = "storage.clickhouse.New"
+
+	conn, err := clickhouse.Open(&clickhouse.Options{
+		Addr: []string{cfg.Addr},
+		Auth: clickhouse.Auth{
+			Database: cfg.DB,
+			Username: cfg.User,
+			Password: cfg.Password,
+		},
+		ClientInfo: clickhouse.ClientInfo{
+			Products: []struct {
+				Name    string
+				Version string
+			}{
+				{Name: "core", Version: "0.1"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &ClickHouseStorage{db: conn}, nil
+}
+
+func (s *ClickHouseStorage) Ping(ctx context.Context) error {
+	const op = "storage.clickhouse.Ping"
+
+	if err := s.db.Ping(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *ClickHouseStorage) Close() error {
+	return s.db.Close()
+}
+
+// ProjectsHistory returns up to limit latest events of goods of each of
+// the projects, newest first. A moved good shows up in the project it
+// moved to. Projects without events are left out.
+func (s *ClickHouseStorage) ProjectsHistory(
+	ctx context.Context,
+	projectIDs []int,
+	limit int,
+) (map[int][]models.HistoryEvent, error) {
+	const op = "storage.clickhouse.ProjectsHistory"
+
+	events, err := s.history(ctx, projectsHistoryQuery, projectIDs, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res := make(map[int][]models.HistoryEvent, len(projectIDs))
+	for _, event := range events {
+		res[event.ProjectID] = append(res[event.ProjectID], event)
+	}
+
+	return res, nil
+}
+
+// GoodsHistory returns up to limit latest events of each of the goods,
+// newest first, in whichever project they happened. Goods without events
+// are left out.
+func (s *ClickHouseStorage) GoodsHistory(
+	ctx context.Context,
+	ids []int,
+	limit int,
+) (map[int][]models.HistoryEvent, error) {
+	const op = "storage.clickhouse.GoodsHistory"
+
+	events, err := s.history(ctx, goodsHistoryQuery, ids, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res := make(map[int][]models.HistoryEvent, len(ids))
+	for _, event := range events {
+		res[event.ID] = append(res[event.ID], event)
+	}
+
+	return res, nil
+}
+
+func (s *ClickHouseStorage) history(ctx context.Context, query string, ids []int, limit int) ([]models.HistoryEvent, error) {
+	if len(ids) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "SELECT hezzl.goods",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemClickhouse,
+			semconv.DBNamespace("hezzl"),
+			semconv.DBOperationName("SELECT"),
+		),
+	)
+	defer span.End()
+
+	ctx = clickhouse.Context(ctx, clickhouse.WithSpan(span.SpanContext()))
+
+	set := make([]any, len(ids))
+	for i, id := range ids {
+		set[i] = id
+	}
+
+	rows, err := s.db.Query(ctx, query, clickhouse.GroupSet{Value: set}, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.HistoryEvent
+
+	for rows.Next() {
+		var (
+			id                           uint64
+			projectID, fromProjectID     uint32
+			priority                     uint32
+			removed                      uint8
+			name, desc, actor, eventType string
+			eventTime                    time.Time
+		)
+
+		err := rows.Scan(&id, &projectID, &name, &desc, &priority, &removed, &actor, &eventType, &fromProjectID, &eventTime)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+
+		events = append(events, models.HistoryEvent{
+			GoodEvent: models.GoodEvent{
+				Good: models.Good{
+					ID:          int(id),
+					ProjectID:   int(projectID),
+					Name:        name,
+					Description: desc,
+					Priority:    int(priority),
+					Removed:     removed != 0,
+				},
+				Type:          eventType,
+				Actor:         actor,
+				FromProjectID: int(fromProjectID),
+			},
+			Time: eventTime,
+		})
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+type entry struct {
+	data       []byte
+	etag       string
+	good       models.Good
+	freshUntil time.Time
+	expiresAt  time.Time
+}
+
+// MemoryStorage is an in-process LRU cache with the same semantics as
+// redis.RedisStorage. It is meant for a single core instance or for running
+// without Redis at all.
+type MemoryStorage struct {
+	entries *lru.Cache[string, entry]
+
+	mu       sync.Mutex
+	versions map[string]int64
+
+	listSoftTTL time.Duration
+	listHardTTL time.Duration
+	goodTTL     time.Duration
+}
+
+func New(cfg config.RedisStorage) (*MemoryStorage, error) {
+	const op = "storage.memory.New"
+
+	entries, err := lru.New[string, entry](cfg.MemorySize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &MemoryStorage{
+		entries:     entries,
+		versions:    make(map[string]int64),
+		listSoftTTL: cfg.ListSoftTTL,
+		listHardTTL: cfg.ListHardTTL,
+		goodTTL:     cfg.GoodTTL,
+	}, nil
+}
+
+func (s *MemoryStorage) Close() error {
+	s.entries.Purge()
+
+	return nil
+}
+
+func (s *MemoryStorage) SaveListInCache(
+	_ context.Context,
+	projectID string,
+	version int64,
+	page list.Page,
+) error {
+	now := time.Now()
+
+	s.entries.Add(listKey(projectID, version, page.Limit, page.Offset), entry{
+		data:       page.Data,
+		etag:       page.ETag,
+		freshUntil: now.Add(s.listSoftTTL),
+		expiresAt:  now.Add(s.listHardTTL),
+	})
+
+	return nil
+}
+
+func (s *MemoryStorage) GetCachedList(
+	_ context.Context,
+	projectID string,
+	limit, offset int,
+) (*list.CachedList, error) {
+	version := s.version(projectID)
+	res := &list.CachedList{Version: version}
+
+	e, ok := s.get(listKey(projectID, version, limit, offset))
+	if !ok {
+		return res, nil
+	}
+
+	res.Data = e.data
+	res.ETag = e.etag
+	res.Stale = time.Now().After(e.freshUntil)
+
+	return res, nil
+}
+
+// InvalidList bumps the cache version of the project and of the cross-project
+// list. Outdated pages are evicted by the LRU or on their next lookup.
+func (s *MemoryStorage) InvalidList(_ context.Context, projectID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if projectID != "" {
+		s.versions[listScope(projectID)]++
+	}
+	s.versions[listScope("")]++
+
+	return nil
+}
+
+// GetCachedGood returns a cached good together with the cache version of
+// its project. Like pages, goods are cached under that version.
+func (s *MemoryStorage) GetCachedGood(
+	_ context.Context,
+	id string,
+	projectID string,
+) (*models.CachedGood, error) {
+	version := s.version(projectID)
+	res := &models.CachedGood{Version: version}
+
+	e, ok := s.get(goodKey(id, projectID, version))
+	if !ok {
+		return res, nil
+	}
+
+	good := e.good
+	res.Good = &good
+
+	return res, nil
+}
+
+func (s *MemoryStorage) SaveGoodInCache(_ context.Context, version int64, good models.Good) error {
+	key := goodKey(strconv.Itoa(good.ID), strconv.Itoa(good.ProjectID), version)
+
+	s.entries.Add(key, entry{good: good, expiresAt: time.Now().Add(s.goodTTL)})
+
+	return nil
+}
+
+func (s *MemoryStorage) InvalidGoods(_ context.Context, goods ...models.Good) error {
+	for _, good := range goods {
+		projectID := strconv.Itoa(good.ProjectID)
+		s.entries.Remove(goodKey(strconv.Itoa(good.ID), projectID, s.version(projectID)))
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) get(key string) (entry, bool) {
+	e, ok := s.entries.Get(key)
+	if !ok {
+		return entry{}, false
+	}
+
+	if time.Now().After(e.expiresAt) {
+		s.entries.Remove(key)
+
+		return entry{}, false
+	}
+
+	return e, true
+}
+
+func (s *MemoryStorage) version(projectID string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.versions[listScope(projectID)]
+}
+
+func listScope(projectID string) string {
+	if projectID == "" {
+		return "all"
+	}
+
+	return "project:" + projectID
+}
+
+func listKey(projectID string, version int64, limit, offset int) string {
+	return fmt.Sprintf("goods:%s:v%d:limit:%d-offset:%d",
+		listScope(projectID), version, limit, offset)
+}
+
+func goodKey(id, projectID string, version int64) string {
+	return fmt.Sprintf("goods:%s:v%d:good:%s", listScope(projectID), version, id)
+}
@@ -0,0 +1,49 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCache(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := memory.New(config.RedisStorage{
+		MemorySize:  16,
+		ListSoftTTL: time.Nanosecond,
+		ListHardTTL: time.Minute,
+	})
+	require.NoError(t, err)
+
+	page := list.Page{Limit: 10, Offset: 1, Data: []byte(`{}`), ETag: `"tag"`}
+
+	for _, projectID := range []string{"1", "2"} {
+		cached, err := s.GetCachedList(ctx, projectID, 10, 1)
+		require.NoError(t, err)
+		require.Nil(t, cached.Data)
+
+		require.NoError(t, s.SaveListInCache(ctx, projectID, cached.Version, page))
+	}
+
+	cached, err := s.GetCachedList(ctx, "1", 10, 1)
+	require.NoError(t, err)
+	require.NotNil(t, cached.Data)
+	require.Equal(t, page.ETag, cached.ETag)
+	require.True(t, cached.Stale, "page past its soft TTL must be stale")
+
+	require.NoError(t, s.InvalidList(ctx, "1"))
+
+	cached, err = s.GetCachedList(ctx, "1", 10, 1)
+	require.NoError(t, err)
+	require.Nil(t, cached.Data)
+
+	cached, err = s.GetCachedList(ctx, "2", 10, 1)
+	require.NoError(t, err)
+	require.NotNil(t, cached.Data)
+}
@@ -0,0 +1,47 @@
+package nocache
+
+import (
+	"context"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+)
+
+// NoCache is a cache that never holds anything, so every read goes
+// straight to Postgres.
+type NoCache struct{}
+
+func New() *NoCache {
+	return &NoCache{}
+}
+
+func (NoCache) Close() error {
+	return nil
+}
+
+func (NoCache) SaveListInCache(context.Context, string, int64, list.Page) error {
+	return nil
+}
+
+// GetCachedList always misses. It returns no version either, so the list
+// handler does not even try to save the page.
+func (NoCache) GetCachedList(context.Context, string, int, int) (*list.CachedList, error) {
+	return nil, nil
+}
+
+func (NoCache) InvalidList(context.Context, string) error {
+	return nil
+}
+
+// GetCachedGood always misses, without a version like GetCachedList.
+func (NoCache) GetCachedGood(context.Context, string, string) (*models.CachedGood, error) {
+	return nil, nil
+}
+
+func (NoCache) SaveGoodInCache(context.Context, int64, models.Good) error {
+	return nil
+}
+
+func (NoCache) InvalidGoods(context.Context, ...models.Good) error {
+	return nil
+}
@@ -2,33 +2,57 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Gonnekone/hezzl-test/core/internal/config"
 	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/metrics"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/rank"
 	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/exaring/otelpgx"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"slices"
 )
 
-const defaultPriority = 0
-
-var (
-// place for custom errors
+// PostgreSQL error codes mapped to storage errors.
+const (
+	codeUniqueViolation     = "23505"
+	codeForeignKeyViolation = "23503"
 )
 
 type PostgresStorage struct {
-	db          *pgx.Conn
-	maxPriority int
+	db *pgxpool.Pool
 }
 
 func New(cfg config.PostgresStorage) (*PostgresStorage, error) {
 	const op = "storage.postgres.New"
 
-	conn, err := pgx.Connect(context.Background(), cfg.DSN())
+	poolCfg, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("%s: parse config: %w", op, err)
+	}
+
+	poolCfg.ConnConfig.Tracer = otelpgx.NewTracer()
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &PostgresStorage{db: conn, maxPriority: defaultPriority}, nil
+	return &PostgresStorage{db: pool}, nil
+}
+
+// goodColumns selects a good of goods g. priority is the position of the
+// good in its project, removed goods included. It follows the order of
+// rank and is kept up to date by every write under the project lock.
+const goodColumns = `g.id, g.project_id, g.name, g.description, g.priority, g.removed, g.created_at, g.version`
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 }
 
 func (s *PostgresStorage) SaveGood(
@@ -38,37 +62,53 @@ func (s *PostgresStorage) SaveGood(
 ) (*models.Good, error) {
 	const op = "storage.postgres.SaveGood"
 
-	if s.maxPriority == defaultPriority {
-		err := s.fetchMaxPriority()
-		if err != nil {
-			return nil, fmt.Errorf("%s: fetch max priority: %w", op, err)
-		}
+	defer metrics.ObservePostgres(op)()
+
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
 	}
+	//nolint: errcheck
+	defer tx.Rollback(ctx)
 
-	s.maxPriority++
+	// The lock on the project orders every write to its order, so each
+	// insert takes its own rank and priority after the last one.
+	var projectRow int
+	err = tx.QueryRow(ctx, `SELECT id FROM projects WHERE id = $1 FOR NO KEY UPDATE`, projectID).Scan(&projectRow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, storageerr.ErrProjectNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: lock project: %w", op, err)
+	}
+
+	// A new good goes last in its project.
+	last, count, err := lastGood(ctx, tx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
 	query := `
-		INSERT INTO goods(name, project_id, priority)
-		VALUES ($1, $2, $3)
-		RETURNING id, project_id, name, description, priority, removed, created_at
+		INSERT INTO goods(name, project_id, rank, priority)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
 	`
 
-	var good models.Good
-	err := s.db.QueryRow(ctx, query, name, projectID, s.maxPriority).Scan(
-		&good.ID,
-		&good.ProjectID,
-		&good.Name,
-		&good.Description,
-		&good.Priority,
-		&good.Removed,
-		&good.CreatedAt,
-	)
+	var id int
+	if err := tx.QueryRow(ctx, query, name, projectID, rank.Between(last, ""), count+1).Scan(&id); err != nil {
+		return nil, fmt.Errorf("%s: insert good: %w", op, mapErr(err))
+	}
+
+	good, err := getGood(ctx, tx, id, projectID)
 	if err != nil {
-		s.maxPriority--
-		return nil, fmt.Errorf("%s: scan good: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &good, nil
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return good, nil
 }
 
 func (s *PostgresStorage) UpdateGood(
@@ -80,6 +120,8 @@ func (s *PostgresStorage) UpdateGood(
 ) (*models.Good, error) {
 	const op = "storage.postgres.UpdateGood"
 
+	defer metrics.ObservePostgres(op)()
+
 	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
@@ -93,7 +135,7 @@ func (s *PostgresStorage) UpdateGood(
 		return nil, fmt.Errorf("%s: lock row: %w", op, err)
 	}
 
-	query := `UPDATE goods SET name = $1`
+	query := `UPDATE goods SET name = $1, version = version + 1`
 	args := []any{name}
 	argIdx := 2
 
@@ -106,29 +148,27 @@ func (s *PostgresStorage) UpdateGood(
 	query += fmt.Sprintf(" WHERE id = $%d AND project_id = $%d", argIdx, argIdx+1)
 	args = append(args, id, projectID)
 
-	query += ` RETURNING id, project_id, name, description, priority, removed, created_at`
-
-	var res models.Good
-	err = tx.QueryRow(ctx, query, args...).Scan(
-		&res.ID,
-		&res.ProjectID,
-		&res.Name,
-		&res.Description,
-		&res.Priority,
-		&res.Removed,
-		&res.CreatedAt,
-	)
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: update good: %w", op, mapErr(err))
+	}
+
+	res, err := getGood(ctx, tx, id, projectID)
 	if err != nil {
-		return nil, fmt.Errorf("%s: scan good: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("%s: commit: %w", op, err)
 	}
 
-	return &res, nil
+	return res, nil
 }
 
+// UpdateGoodsPriority moves the good to position priority of its project,
+// or last if the project has fewer goods. priority must be at least 1.
+// The moved good is returned first, followed by the goods it passed,
+// whose priorities shift by one, ordered by priority. Only the moved good
+// gets a new version.
 func (s *PostgresStorage) UpdateGoodsPriority(
 	ctx context.Context,
 	id string,
@@ -137,6 +177,41 @@ func (s *PostgresStorage) UpdateGoodsPriority(
 ) ([]models.Good, error) {
 	const op = "storage.postgres.UpdateGoodsPriority"
 
+	defer metrics.ObservePostgres(op)()
+
+	return s.updateGoodsPriority(ctx, op, id, projectID, priority, anyVersion)
+}
+
+// UpdateGoodsPriorityIfVersion moves the good like UpdateGoodsPriority, but
+// only while its version is still version. Otherwise nothing changes and
+// storageerr.ErrVersionConflict is returned. The row lock orders
+// concurrent moves, so of two moves made at the same version the first
+// to commit wins.
+func (s *PostgresStorage) UpdateGoodsPriorityIfVersion(
+	ctx context.Context,
+	id string,
+	projectID string,
+	priority int,
+	version int,
+) ([]models.Good, error) {
+	const op = "storage.postgres.UpdateGoodsPriorityIfVersion"
+
+	defer metrics.ObservePostgres(op)()
+
+	return s.updateGoodsPriority(ctx, op, id, projectID, priority, version)
+}
+
+// anyVersion makes updateGoodsPriority skip the version check.
+const anyVersion = 0
+
+func (s *PostgresStorage) updateGoodsPriority(
+	ctx context.Context,
+	op string,
+	id string,
+	projectID string,
+	priority int,
+	version int,
+) ([]models.Good, error) {
 	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
@@ -144,70 +219,367 @@ func (s *PostgresStorage) UpdateGoodsPriority(
 	//nolint: errcheck
 	defer tx.Rollback(ctx)
 
-	//nolint: goconst
-	lockQuery := `SELECT id FROM goods WHERE id = $1 AND project_id = $2 FOR UPDATE`
-	if _, err := tx.Exec(ctx, lockQuery, id, projectID); err != nil {
-		return nil, fmt.Errorf("%s: lock row: %w", op, err)
+	// Moves shift the priorities of other goods, so they take the project
+	// lock like every other write to its order.
+	var projectRow int
+	err = tx.QueryRow(ctx, `SELECT id FROM projects WHERE id = $1 FOR NO KEY UPDATE`, projectID).Scan(&projectRow)
+	if err != nil {
+		return nil, fmt.Errorf("%s: lock project: %w", op, mapErr(err))
+	}
+
+	lockQuery := `SELECT version, priority FROM goods WHERE id = $1 AND project_id = $2 FOR UPDATE`
+
+	var current, from int
+	if err := tx.QueryRow(ctx, lockQuery, id, projectID).Scan(&current, &from); err != nil {
+		return nil, fmt.Errorf("%s: lock row: %w", op, mapErr(err))
+	}
+
+	if version != anyVersion && current != version {
+		return nil, fmt.Errorf("%s: good is at version %d, not %d: %w", op, current, version, storageerr.ErrVersionConflict)
+	}
+
+	_, count, err := lastGood(ctx, tx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	to := min(priority, count)
+
+	// The good goes between the goods that end up right before and right
+	// after it once it is taken out of the order.
+	neighboursQuery := `
+		SELECT rank FROM goods
+		WHERE project_id = $1 AND id <> $2
+		ORDER BY priority, id
+		OFFSET $3 LIMIT 2
+	`
+
+	rows, err := tx.Query(ctx, neighboursQuery, projectID, id, max(to-2, 0))
+	if err != nil {
+		return nil, fmt.Errorf("%s: neighbours: %w", op, err)
 	}
 
+	neighbours, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: neighbours: %w", op, err)
+	}
+
+	prev, next := moveBounds(to, neighbours)
+
+	// The goods between the old and the new position close the gap the
+	// good leaves and open the one it takes.
+	shiftQuery := `
+		UPDATE goods g
+		SET priority = g.priority + CASE WHEN $3::int < $4::int THEN 1 ELSE -1 END
+		WHERE g.project_id = $1 AND g.id <> $2
+		  AND g.priority BETWEEN LEAST($3::int, $4::int) AND GREATEST($3::int, $4::int)
+		RETURNING ` + goodColumns
+
+	rows, err = tx.Query(ctx, shiftQuery, projectID, id, to, from)
+	if err != nil {
+		return nil, fmt.Errorf("%s: shift goods: %w", op, err)
+	}
+
+	shifted, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Good, error) {
+		good, err := scanGood(row)
+		if err != nil {
+			return models.Good{}, err
+		}
+
+		return *good, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: shift goods: %w", op, err)
+	}
+
+	slices.SortFunc(shifted, func(a, b models.Good) int {
+		return a.Priority - b.Priority
+	})
+
 	query := `
-		UPDATE goods SET priority = $1 WHERE id = $2 AND project_id = $3
-		RETURNING id, project_id, name, description, priority, removed, created_at
+		UPDATE goods SET rank = $1, priority = $2, version = version + 1
+		WHERE id = $3 AND project_id = $4
 	`
+	if _, err := tx.Exec(ctx, query, rank.Between(prev, next), to, id, projectID); err != nil {
+		return nil, fmt.Errorf("%s: update rank: %w", op, err)
+	}
 
-	var good models.Good
-	err = tx.QueryRow(ctx, query, priority, id, projectID).Scan(
-		&good.ID,
-		&good.ProjectID,
-		&good.Name,
-		&good.Description,
-		&good.Priority,
-		&good.Removed,
-		&good.CreatedAt,
+	good, err := getGood(ctx, tx, id, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return slices.Concat([]models.Good{*good}, shifted), nil
+}
+
+// moveBounds returns the ranks a good moved to position to goes between,
+// given the ranks of the goods at positions to-1 and to once the good is
+// taken out of the order, or the one at to alone when to is 1. An empty
+// rank is an open end.
+func moveBounds(to int, neighbours []string) (prev, next string) {
+	switch {
+	case to == 1:
+		if len(neighbours) > 0 {
+			next = neighbours[0]
+		}
+	case len(neighbours) == 1:
+		prev = neighbours[0]
+	case len(neighbours) > 1:
+		prev, next = neighbours[0], neighbours[1]
+	}
+
+	return prev, next
+}
+
+// lastGood returns the rank of the last good of the project, empty if it
+// has none, and how many goods it has, removed ones included.
+func lastGood(ctx context.Context, q querier, projectID any) (string, int, error) {
+	query := `SELECT COALESCE(MAX(rank), ''), COUNT(*) FROM goods WHERE project_id = $1`
+
+	var (
+		last  string
+		count int
 	)
+	if err := q.QueryRow(ctx, query, projectID).Scan(&last, &count); err != nil {
+		return "", 0, fmt.Errorf("last good: %w", err)
+	}
+
+	return last, count, nil
+}
+
+// ReorderGoods gives the goods of the project priorities 1..N in the order
+// of ids, which must list every good of the project that is not removed.
+// Removed goods follow in their current order. Every rank of the project
+// is rewritten, but only the goods whose priority changed get a new
+// version and are returned, ordered by priority. Like CompactGoods it
+// keeps the project row locked until the commit.
+func (s *PostgresStorage) ReorderGoods(
+	ctx context.Context,
+	projectID string,
+	ids []int,
+) ([]models.Good, error) {
+	const op = "storage.postgres.ReorderGoods"
+
+	defer metrics.ObservePostgres(op)()
+
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("%s: update current: %w", op, err)
+		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
 	}
+	//nolint: errcheck
+	defer tx.Rollback(ctx)
 
-	var res []models.Good
-	res = append(res, good)
+	var projectRow int
+	err = tx.QueryRow(ctx, `SELECT id FROM projects WHERE id = $1 FOR UPDATE`, projectID).Scan(&projectRow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, storageerr.ErrProjectNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: lock project: %w", op, err)
+	}
 
-	query = `
-		UPDATE goods
-		SET priority = priority + 1
-		WHERE priority >= $1 AND id != $2 AND project_id = $3
-		RETURNING id, project_id, name, description, priority, removed, created_at
+	lockQuery := `
+		SELECT id, removed FROM goods
+		WHERE project_id = $1
+		ORDER BY priority, id
+		FOR UPDATE
 	`
 
-	rows, err := tx.Query(ctx, query, priority, id, projectID)
+	rows, err := tx.Query(ctx, lockQuery, projectID)
 	if err != nil {
-		return nil, fmt.Errorf("%s: reprioritize: %w", op, err)
+		return nil, fmt.Errorf("%s: lock rows: %w", op, err)
+	}
+
+	listed := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = false
 	}
 
+	var current, removed []int
+
 	for rows.Next() {
-		var g models.Good
-		if err := rows.Scan(
-			&g.ID,
-			&g.ProjectID,
-			&g.Name,
-			&g.Description,
-			&g.Priority,
-			&g.Removed,
-			&g.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("%s: scan reprioritized: %w", op, err)
+		var (
+			id        int
+			isRemoved bool
+		)
+		if err := rows.Scan(&id, &isRemoved); err != nil {
+			rows.Close()
+
+			return nil, fmt.Errorf("%s: scan good: %w", op, err)
+		}
+
+		current = append(current, id)
+
+		if isRemoved {
+			removed = append(removed, id)
+
+			continue
+		}
+
+		if _, ok := listed[id]; !ok {
+			rows.Close()
+
+			return nil, fmt.Errorf("%s: good %d is not listed: %w", op, id, storageerr.ErrIncompleteOrder)
+		}
+
+		listed[id] = true
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: lock rows: %w", op, err)
+	}
+
+	for id, found := range listed {
+		if !found {
+			return nil, fmt.Errorf("%s: good %d is not in the project: %w", op, id, storageerr.ErrIncompleteOrder)
 		}
-		res = append(res, g)
+	}
+
+	res, err := applyOrder(ctx, tx, projectID, current, slices.Concat(ids, removed))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return res, nil
+}
+
+// CompactGoods spreads the ranks of the project evenly again, so the rank
+// a move gives a good does not grow long. The ranks are rewritten in the
+// order of priority, which CompactGoods keeps, so no good is returned.
+// The result has the shape of ReorderGoods for the service to handle both
+// alike. The project row stays locked until the commit, so goods are
+// neither added to it nor moved meanwhile.
+func (s *PostgresStorage) CompactGoods(ctx context.Context, projectID string) ([]models.Good, error) {
+	const op = "storage.postgres.CompactGoods"
+
+	defer metrics.ObservePostgres(op)()
+
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	//nolint: errcheck
+	defer tx.Rollback(ctx)
+
+	// Inserts, moves between projects and moves within the project lock
+	// the project too, so they wait for this lock.
+	var id int
+	err = tx.QueryRow(ctx, `SELECT id FROM projects WHERE id = $1 FOR UPDATE`, projectID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, storageerr.ErrProjectNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: lock project: %w", op, err)
+	}
+
+	lockQuery := `
+		SELECT id FROM goods
+		WHERE project_id = $1
+		ORDER BY priority, id
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(ctx, lockQuery, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: lock rows: %w", op, err)
+	}
+
+	order, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("%s: lock rows: %w", op, err)
+	}
+
+	res, err := applyOrder(ctx, tx, projectID, order, order)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("%s: commit: %w", op, err)
 	}
 
-	if s.maxPriority < priority {
-		s.maxPriority = priority
-	} else {
-		s.maxPriority++
+	return res, nil
+}
+
+// ListProjectsToCompact returns the projects CompactGoods should run on:
+// the ones with ranks longer than rank.MaxLength or shared by two goods.
+func (s *PostgresStorage) ListProjectsToCompact(ctx context.Context) ([]int, error) {
+	const op = "storage.postgres.ListProjectsToCompact"
+
+	defer metrics.ObservePostgres(op)()
+
+	query := `
+		SELECT project_id FROM goods
+		GROUP BY project_id
+		HAVING MAX(length(rank)) > $1
+			OR COUNT(DISTINCT rank) <> COUNT(*)
+		ORDER BY project_id
+	`
+
+	rows, err := s.db.Query(ctx, query, rank.MaxLength)
+	if err != nil {
+		return nil, fmt.Errorf("%s: list projects: %w", op, err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("%s: list projects: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// applyOrder gives the goods of the project evenly spread ranks in order
+// within tx. The goods whose position differs from the one in current get
+// a new version and are returned ordered by priority.
+func applyOrder(ctx context.Context, tx pgx.Tx, projectID string, current, order []int) ([]models.Good, error) {
+	var moved []int
+	for i, id := range order {
+		if current[i] != id {
+			moved = append(moved, id)
+		}
+	}
+
+	query := `
+		UPDATE goods g
+		SET rank = o.rank, priority = o.priority,
+			version = g.version + CASE WHEN g.id = ANY($4) THEN 1 ELSE 0 END
+		FROM unnest($2::int[], $3::text[]) WITH ORDINALITY AS o(id, rank, priority)
+		WHERE g.project_id = $1 AND g.id = o.id
+	`
+
+	if _, err := tx.Exec(ctx, query, projectID, order, rank.Spread(len(order)), moved); err != nil {
+		return nil, fmt.Errorf("apply order: %w", err)
+	}
+
+	query = `SELECT ` + goodColumns + ` FROM goods g` + ` WHERE g.project_id = $1 AND g.id = ANY($2) ORDER BY g.priority`
+
+	rows, err := tx.Query(ctx, query, projectID, moved)
+	if err != nil {
+		return nil, fmt.Errorf("get reordered: %w", err)
+	}
+	defer rows.Close()
+
+	var res []models.Good
+	for rows.Next() {
+		g, err := scanGood(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan reordered: %w", err)
+		}
+		res = append(res, *g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get reordered: %w", err)
 	}
 
 	return res, nil
@@ -220,6 +592,8 @@ func (s *PostgresStorage) DeleteGood(
 ) (*models.Good, error) {
 	const op = "storage.postgres.DeleteGood"
 
+	defer metrics.ObservePostgres(op)()
+
 	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
@@ -235,68 +609,170 @@ func (s *PostgresStorage) DeleteGood(
 
 	query := `
 		UPDATE goods
-		SET removed = TRUE
+		SET removed = TRUE, version = version + 1
 		WHERE id = $1 AND project_id = $2
-		RETURNING id, project_id, name, description, priority, removed, created_at
 	`
+	if _, err := tx.Exec(ctx, query, id, projectID); err != nil {
+		return nil, fmt.Errorf("%s: delete good: %w", op, mapErr(err))
+	}
 
-	var good models.Good
-	err = s.db.QueryRow(ctx, query, id, projectID).Scan(
-		&good.ID,
-		&good.ProjectID,
-		&good.Name,
-		&good.Description,
-		&good.Priority,
-		&good.Removed,
-		&good.CreatedAt,
+	good, err := getGood(ctx, tx, id, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return good, nil
+}
+
+// MoveGood moves the good to the end of the target project. The goods
+// after it in the source project move up by one, keeping their versions.
+// The good keeps its id, so (id, projectID) is its old identity, which is
+// recorded in goods_moves. Both projects are locked like in SaveGood, in the order
+// of their ids, so opposite moves do not deadlock. A missing project,
+// either one, is ErrProjectNotFound, a missing or removed good
+// ErrNotFound.
+func (s *PostgresStorage) MoveGood(
+	ctx context.Context,
+	id string,
+	projectID string,
+	targetProjectID string,
+) (*models.Good, error) {
+	const op = "storage.postgres.MoveGood"
+
+	defer metrics.ObservePostgres(op)()
+
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	//nolint: errcheck
+	defer tx.Rollback(ctx)
+
+	projectsQuery := `
+		SELECT id = $1::int, id = $2::int FROM projects
+		WHERE id IN ($1::int, $2::int)
+		ORDER BY id
+		FOR NO KEY UPDATE
+	`
+
+	rows, err := tx.Query(ctx, projectsQuery, projectID, targetProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: lock projects: %w", op, err)
+	}
+
+	var isSource, isTarget, sourceFound, targetFound bool
+
+	_, err = pgx.ForEachRow(rows, []any{&isSource, &isTarget}, func() error {
+		sourceFound = sourceFound || isSource
+		targetFound = targetFound || isTarget
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: lock projects: %w", op, err)
+	}
+
+	if !sourceFound {
+		return nil, fmt.Errorf("%s: project %s: %w", op, projectID, storageerr.ErrProjectNotFound)
+	}
+
+	if !targetFound {
+		return nil, fmt.Errorf("%s: project %s: %w", op, targetProjectID, storageerr.ErrProjectNotFound)
+	}
+
+	lockQuery := `SELECT removed, priority FROM goods WHERE id = $1 AND project_id = $2 FOR UPDATE`
+
+	var (
+		removed  bool
+		priority int
 	)
+	if err := tx.QueryRow(ctx, lockQuery, id, projectID).Scan(&removed, &priority); err != nil {
+		return nil, fmt.Errorf("%s: lock row: %w", op, mapErr(err))
+	}
+
+	// A removed good stays where it was removed.
+	if removed {
+		return nil, fmt.Errorf("%s: good is removed: %w", op, storageerr.ErrNotFound)
+	}
+
+	last, count, err := lastGood(ctx, tx, targetProjectID)
 	if err != nil {
-		return nil, fmt.Errorf("%s: delete good: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+		UPDATE goods
+		SET project_id = $1, rank = $2, priority = $3, version = version + 1
+		WHERE id = $4 AND project_id = $5
+	`
+	if _, err := tx.Exec(ctx, query, targetProjectID, rank.Between(last, ""), count+1, id, projectID); err != nil {
+		return nil, fmt.Errorf("%s: move good: %w", op, mapErr(err))
+	}
+
+	query = `UPDATE goods SET priority = priority - 1 WHERE project_id = $1 AND priority > $2`
+	if _, err := tx.Exec(ctx, query, projectID, priority); err != nil {
+		return nil, fmt.Errorf("%s: close gap: %w", op, err)
+	}
+
+	query = `
+		INSERT INTO goods_moves(good_id, from_project_id, to_project_id)
+		VALUES ($1, $2, $3)
+	`
+	if _, err := tx.Exec(ctx, query, id, projectID, targetProjectID); err != nil {
+		return nil, fmt.Errorf("%s: record move: %w", op, err)
+	}
+
+	good, err := getGood(ctx, tx, id, targetProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("%s: commit: %w", op, err)
 	}
 
-	return &good, nil
+	return good, nil
 }
 
 func (s *PostgresStorage) ListGoods(
 	ctx context.Context,
+	projectID string,
 	limit int,
 	offset int,
 ) (*list.GoodListResponse, error) {
 	const op = "storage.postgres.ListGoods"
 
-	query := `
-		SELECT * FROM goods
-		ORDER BY id ASC
-		LIMIT $1 OFFSET $2 
-	`
+	defer metrics.ObservePostgres(op)()
+
+	query := `SELECT ` + goodColumns + ` FROM goods g`
+	args := []any{limit, offset}
+
+	if projectID != "" {
+		query += ` WHERE g.project_id = $3`
+		args = append(args, projectID)
+	}
+
+	query += ` ORDER BY g.id ASC LIMIT $1 OFFSET $2`
 
 	var res list.GoodListResponse
-	rows, err := s.db.Query(ctx, query, limit, offset)
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("%s: list goods: %w", op, err)
 	}
 
 	var total, removed int
 	for rows.Next() {
-		var good models.Good
-		if err := rows.Scan(
-			&good.ID,
-			&good.ProjectID,
-			&good.Name,
-			&good.Description,
-			&good.Priority,
-			&good.Removed,
-			&good.CreatedAt,
-		); err != nil {
+		good, err := scanGood(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s: scan good %w", op, err)
 		}
 
 		if !good.Removed {
-			res.Goods = append(res.Goods, good)
+			res.Goods = append(res.Goods, *good)
 		} else {
 			removed++
 		}
@@ -321,13 +797,31 @@ func (s *PostgresStorage) GetGood(
 ) (*models.Good, error) {
 	const op = "storage.postgres.GetGood"
 
-	query := `
-		SELECT * FROM goods
-		WHERE id = $1 AND project_id = $2
-	`
+	defer metrics.ObservePostgres(op)()
 
+	good, err := getGood(ctx, s.db, id, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return good, nil
+}
+
+func getGood(ctx context.Context, q querier, id, projectID any) (*models.Good, error) {
+	query := `SELECT ` + goodColumns + ` FROM goods g` + ` WHERE g.id = $1 AND g.project_id = $2`
+
+	good, err := scanGood(q.QueryRow(ctx, query, id, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("get good: %w", mapErr(err))
+	}
+
+	return good, nil
+}
+
+// scanGood reads a row of goodColumns.
+func scanGood(row pgx.Row) (*models.Good, error) {
 	var good models.Good
-	if err := s.db.QueryRow(ctx, query, id, projectID).Scan(
+	if err := row.Scan(
 		&good.ID,
 		&good.ProjectID,
 		&good.Name,
@@ -335,32 +829,311 @@ func (s *PostgresStorage) GetGood(
 		&good.Priority,
 		&good.Removed,
 		&good.CreatedAt,
+		&good.Version,
 	); err != nil {
-		return nil, fmt.Errorf("%s: get good: %w", op, err)
+		return nil, err
 	}
 
 	return &good, nil
 }
 
-func (s *PostgresStorage) Close() {
-	s.db.Close(context.Background())
+// GetProjects returns the projects with the given ids that exist, in no
+// particular order.
+func (s *PostgresStorage) GetProjects(ctx context.Context, ids []int) ([]models.Project, error) {
+	const op = "storage.postgres.GetProjects"
+
+	defer metrics.ObservePostgres(op)()
+
+	query := `
+		SELECT id, name, created_at FROM projects
+		WHERE id = ANY($1)
+	`
+
+	rows, err := s.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: get projects: %w", op, err)
+	}
+	defer rows.Close()
+
+	projects := make([]models.Project, 0, len(ids))
+	for rows.Next() {
+		var project models.Project
+		if err := rows.Scan(&project.ID, &project.Name, &project.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: scan project: %w", op, err)
+		}
+
+		projects = append(projects, project)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: get projects: %w", op, err)
+	}
+
+	return projects, nil
 }
 
-func (s *PostgresStorage) fetchMaxPriority() error {
+// ListProjectGoods returns a page of goods for each of the projects in two
+// queries, however many projects there are. Projects without matching
+// goods get an empty page.
+func (s *PostgresStorage) ListProjectGoods(
+	ctx context.Context,
+	projectIDs []int,
+	filter models.GoodFilter,
+) (map[int]*models.GoodPage, error) {
+	const op = "storage.postgres.ListProjectGoods"
+
+	defer metrics.ObservePostgres(op)()
+
+	pages := make(map[int]*models.GoodPage, len(projectIDs))
+	for _, id := range projectIDs {
+		pages[id] = &models.GoodPage{Goods: []models.Good{}}
+	}
+
+	countQuery := `
+		SELECT project_id, COUNT(*) FROM goods
+		WHERE project_id = ANY($1)
+		  AND ($2 OR NOT removed)
+		  AND strpos(lower(name), lower($3)) > 0
+		GROUP BY project_id
+	`
+
+	rows, err := s.db.Query(ctx, countQuery, projectIDs, filter.IncludeRemoved, filter.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: count goods: %w", op, err)
+	}
+
+	for rows.Next() {
+		var projectID, total int
+		if err := rows.Scan(&projectID, &total); err != nil {
+			rows.Close()
+
+			return nil, fmt.Errorf("%s: scan count: %w", op, err)
+		}
+
+		pages[projectID].Total = total
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: count goods: %w", op, err)
+	}
+
+	// One row more than requested tells whether there is a next page.
+	pageQuery := `
+		SELECT id, project_id, name, description, priority, removed, created_at, version
+		FROM (
+			SELECT g.*, ROW_NUMBER() OVER (PARTITION BY project_id ORDER BY priority, id) AS rn
+			FROM goods g
+			WHERE g.project_id = ANY($1)
+			  AND ($2 OR NOT removed)
+			  AND strpos(lower(name), lower($3)) > 0
+			  AND ($4::int IS NULL OR (priority, id) > ($4, $5))
+		) page
+		WHERE rn <= $6
+		ORDER BY project_id, priority, id
+	`
+
+	var afterPriority, afterID *int
+	if filter.After != nil {
+		afterPriority, afterID = &filter.After.Priority, &filter.After.ID
+	}
+
+	rows, err = s.db.Query(ctx, pageQuery,
+		projectIDs,
+		filter.IncludeRemoved,
+		filter.Name,
+		afterPriority,
+		afterID,
+		filter.First+1,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: list goods: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		good, err := scanGood(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan good: %w", op, err)
+		}
+
+		page := pages[good.ProjectID]
+		if len(page.Goods) == filter.First {
+			page.HasNext = true
+
+			continue
+		}
+
+		page.Goods = append(page.Goods, *good)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: list goods: %w", op, err)
+	}
+
+	return pages, nil
+}
+
+func (s *PostgresStorage) SaveAPIKey(
+	ctx context.Context,
+	name string,
+	keyHash string,
+	projects []models.ProjectScope,
+) (*models.APIKey, error) {
+	const op = "storage.postgres.SaveAPIKey"
+
+	defer metrics.ObservePostgres(op)()
+
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	//nolint: errcheck
+	defer tx.Rollback(ctx)
+
 	query := `
-		SELECT MAX(priority) FROM goods
+		INSERT INTO api_keys(name, key_hash)
+		VALUES ($1, $2)
+		RETURNING id, name, created_at
 	`
 
-	var maxPriority *int
-	err := s.db.QueryRow(context.Background(), query).Scan(&maxPriority)
-	if maxPriority == nil {
-		return nil
+	var key models.APIKey
+	err = tx.QueryRow(ctx, query, name, keyHash).Scan(&key.ID, &key.Name, &key.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: insert key: %w", op, mapErr(err))
+	}
+
+	query = `
+		INSERT INTO api_key_projects(api_key_id, project_id, scope)
+		VALUES ($1, $2, $3)
+	`
+
+	for _, p := range projects {
+		if _, err := tx.Exec(ctx, query, key.ID, p.ProjectID, p.Scope); err != nil {
+			return nil, fmt.Errorf("%s: insert project %d: %w", op, p.ProjectID, mapErr(err))
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
 	}
 
+	key.Projects = projects
+
+	return &key, nil
+}
+
+// GetAPIKeyByHash returns a key that has not been revoked.
+func (s *PostgresStorage) GetAPIKeyByHash(
+	ctx context.Context,
+	keyHash string,
+) (*models.APIKey, error) {
+	const op = "storage.postgres.GetAPIKeyByHash"
+
+	defer metrics.ObservePostgres(op)()
+
+	query := `
+		SELECT k.id, k.name, k.created_at, p.project_id, p.scope
+		FROM api_keys k
+		LEFT JOIN api_key_projects p ON p.api_key_id = k.id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+	`
+
+	rows, err := s.db.Query(ctx, query, keyHash)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("%s: get key: %w", op, err)
+	}
+	defer rows.Close()
+
+	var key *models.APIKey
+	for rows.Next() {
+		var (
+			k         models.APIKey
+			projectID *int
+			scope     *string
+		)
+
+		if err := rows.Scan(&k.ID, &k.Name, &k.CreatedAt, &projectID, &scope); err != nil {
+			return nil, fmt.Errorf("%s: scan key: %w", op, err)
+		}
+
+		if key == nil {
+			key = &k
+		}
+
+		if projectID != nil && scope != nil {
+			key.Projects = append(key.Projects, models.ProjectScope{
+				ProjectID: *projectID,
+				Scope:     *scope,
+			})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: read rows: %w", op, err)
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("%s: get key: %w", op, storageerr.ErrNotFound)
+	}
+
+	return key, nil
+}
+
+func (s *PostgresStorage) RevokeAPIKey(
+	ctx context.Context,
+	id string,
+) (*models.APIKey, error) {
+	const op = "storage.postgres.RevokeAPIKey"
+
+	defer metrics.ObservePostgres(op)()
+
+	query := `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING id, name, created_at, revoked_at
+	`
+
+	var key models.APIKey
+	err := s.db.QueryRow(ctx, query, id).Scan(&key.ID, &key.Name, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: revoke key: %w", op, mapErr(err))
+	}
+
+	return &key, nil
+}
+
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	const op = "storage.postgres.Ping"
+
+	if err := s.db.Ping(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) Close() {
+	s.db.Close()
+}
+
+// mapErr wraps errors the callers can handle into storage errors, keeping
+// the original error in the chain for logs.
+func mapErr(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", storageerr.ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case codeUniqueViolation:
+			return fmt.Errorf("%w: %w", storageerr.ErrConflict, err)
+		case codeForeignKeyViolation:
+			return fmt.Errorf("%w: %w", storageerr.ErrProjectNotFound, err)
+		}
 	}
 
-	s.maxPriority = *maxPriority
 	return err
 }
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveBounds(t *testing.T) {
+	cases := []struct {
+		name       string
+		to         int
+		neighbours []string
+		prev, next string
+	}{
+		{name: "only good", to: 1},
+		{name: "first", to: 1, neighbours: []string{"a", "b"}, next: "a"},
+		{name: "first of two", to: 1, neighbours: []string{"a"}, next: "a"},
+		{name: "middle", to: 3, neighbours: []string{"b", "c"}, prev: "b", next: "c"},
+		{name: "last", to: 4, neighbours: []string{"c"}, prev: "c"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prev, next := moveBounds(tc.to, tc.neighbours)
+
+			require.Equal(t, tc.prev, prev)
+			require.Equal(t, tc.next, next)
+		})
+	}
+}
@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"github.com/Gonnekone/hezzl-test/core/internal/config"
 	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	"strconv"
 	"time"
 )
 
@@ -15,106 +19,440 @@ var (
 // place for custom errors
 )
 
+const (
+	listDataField       = "data"
+	listETagField       = "etag"
+	listFreshUntilField = "fresh_until"
+)
+
+// tokenBucketScript refills the bucket of KEYS[1] for the time passed since
+// the last call and takes one token out of it. ARGV[1] is the bucket
+// capacity, ARGV[2] the time in ms to refill one token. Redis time is used,
+// so every core instance shares the same clock. Returns whether the request
+// is allowed, the tokens left, the ms until the next token and the ms until
+// the bucket is full again.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / interval)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * interval)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity * interval))
+
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) * interval)}
+`)
+
 type RedisStorage struct {
 	client *redis.Client
+
+	listSoftTTL time.Duration
+	listHardTTL time.Duration
+	goodTTL     time.Duration
 }
 
 func (s *RedisStorage) Close() error {
 	return s.client.Close()
 }
 
-func New(cfg config.RedisStorage) (*RedisStorage, error) {
-	const op = "storage.redis.New"
+func (s *RedisStorage) Ping(ctx context.Context) error {
+	const op = "storage.redis.Ping"
 
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// New creates a Redis backed cache. It does not require Redis to be
+// reachable: the client reconnects on its own, and callers treat cache
+// errors as misses. Use Ping to check the connection.
+func New(cfg config.RedisStorage) *RedisStorage {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:       cfg.Addr,
-		Password:   cfg.Password,
-		DB:         cfg.DB,
-		MaxRetries: cfg.MaxRetries,
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		MaxRetries:   cfg.MaxRetries,
+		DialTimeout:  cfg.Timeout,
+		ReadTimeout:  cfg.Timeout,
+		WriteTimeout: cfg.Timeout,
 	})
-	if err := rdb.Ping(context.Background()).Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
 
-	return &RedisStorage{client: rdb}, nil
+	// Only fails on invalid options, none are passed.
+	redisotel.InstrumentTracing(rdb) //nolint: errcheck
+
+	return &RedisStorage{
+		client:      rdb,
+		listSoftTTL: cfg.ListSoftTTL,
+		listHardTTL: cfg.ListHardTTL,
+		goodTTL:     cfg.GoodTTL,
+	}
 }
 
 func (s *RedisStorage) SaveListInCache(
 	ctx context.Context,
-	list list.GoodListResponse,
+	projectID string,
+	version int64,
+	page list.Page,
 ) error {
 	const op = "storage.redis.SaveList"
 
-	key := fmt.Sprintf("limit:%d-offset:%d", list.Meta.Limit, list.Meta.Offset)
+	key := listKey(projectID, version, page.Limit, page.Offset)
 
-	listJSON, err := json.Marshal(list)
-	if err != nil {
-		return fmt.Errorf("%s: failed to marshal list: %w", op, err)
-	}
+	freshUntil := time.Now().Add(s.listSoftTTL).UnixMilli()
 
-	err = s.client.Set(ctx, key, listJSON, time.Minute).Err()
-	if err != nil {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key,
+		listDataField, page.Data,
+		listETagField, page.ETag,
+		listFreshUntilField, freshUntil,
+	)
+	pipe.Expire(ctx, key, s.listHardTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("%s: failed to save list to redis: %w", op, err)
 	}
 
 	return nil
 }
 
+// GetCachedList returns a cached page together with the current cache version
+// of the project. On a cache miss Data is nil, and the returned Version should
+// be passed to SaveListInCache, so a page read before a concurrent write is
+// stored under an already outdated version and never served. A page older
+// than the soft TTL is returned with Stale set.
 func (s *RedisStorage) GetCachedList(
 	ctx context.Context,
+	projectID string,
 	limit, offset int,
-) ([]byte, error) {
+) (*list.CachedList, error) {
 	const op = "storage.redis.GetList"
 
-	key := fmt.Sprintf("limit:%d-offset:%d", limit, offset)
+	version, err := s.version(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res := &list.CachedList{Version: version}
+
+	key := listKey(projectID, version, limit, offset)
 
-	data, err := s.client.Get(ctx, key).Bytes()
+	fields, err := s.client.HMGet(ctx, key, listDataField, listETagField, listFreshUntilField).Result()
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			//nolint: err113
-			return nil, fmt.Errorf("%s: no data found for limit %d and offset %d",
-				op, limit, offset)
-		}
 		return nil, fmt.Errorf("%s: failed to get list from redis: %w", op, err)
 	}
 
-	//var response list.GoodListResponse
-	//if err := json.Unmarshal(data, &response); err != nil {
-	//	return nil, fmt.Errorf("%s: failed to unmarshal list: %w", op, err)
-	//}
-	//
-	//return &response, nil
+	data, ok := fields[0].(string)
+	if !ok {
+		return res, nil
+	}
 
-	//if len(data) == 0 {
-	//	return nil, fmt.Errorf("%s: no data found for limit %d and offset %d", op, limit, offset)
-	//}
+	res.Data = []byte(data)
+	res.ETag, _ = fields[1].(string)
 
-	return data, nil
+	freshUntil, _ := fields[2].(string)
+	if ms, err := strconv.ParseInt(freshUntil, 10, 64); err != nil || time.Now().UnixMilli() > ms {
+		res.Stale = true
+	}
+
+	return res, nil
 }
 
-// В идеале хранить просто список товаров, но так как по отдельности
-// мы к ним на чтение не обращаемся, логика удаления отдельных товаров
-// становится слишком сложной, врядли будет возможно учесть все корнер кейсы,
-// так что проще удалить весь кеш, тем более мы храним всего минуту
-func (s *RedisStorage) InvalidList(ctx context.Context) error {
+// InvalidList bumps the cache version of the project and of the cross-project
+// list, which makes every page cached under previous versions unreachable.
+// Stale pages are not deleted and simply expire after the hard TTL.
+func (s *RedisStorage) InvalidList(ctx context.Context, projectID string) error {
 	const op = "storage.redis.InvalidList"
 
-	pattern := "limit:*-offset:*"
-	iter := s.client.Scan(ctx, 0, pattern, 0).Iterator()
+	pipe := s.client.TxPipeline()
 
-	pipe := s.client.Pipeline()
+	if projectID != "" {
+		pipe.Incr(ctx, versionKey(projectID))
+	}
+	pipe.Incr(ctx, versionKey(""))
 
-	for iter.Next(ctx) {
-		pipe.Del(ctx, iter.Val())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("%s: bump cache version: %w", op, err)
 	}
 
-	if err := iter.Err(); err != nil {
-		return fmt.Errorf("%s: scan error: %w", op, err)
+	return nil
+}
+
+// GetCachedGood returns a cached good together with the current cache
+// version of its project. Goods are cached under that version, since
+// moving one good shifts the priorities of others, so InvalidList drops
+// them too. On a cache miss Good is nil, and like with GetCachedList the
+// returned Version should be passed to SaveGoodInCache.
+func (s *RedisStorage) GetCachedGood(
+	ctx context.Context,
+	id string,
+	projectID string,
+) (*models.CachedGood, error) {
+	const op = "storage.redis.GetCachedGood"
+
+	version, err := s.version(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if _, err := pipe.Exec(ctx); err != nil {
+	res := &models.CachedGood{Version: version}
+
+	data, err := s.client.Get(ctx, goodKey(id, projectID, version)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return res, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get good from redis: %w", op, err)
+	}
+
+	var good models.Good
+	if err := json.Unmarshal(data, &good); err != nil {
+		return nil, fmt.Errorf("%s: failed to unmarshal good: %w", op, err)
+	}
+
+	res.Good = &good
+
+	return res, nil
+}
+
+// SaveGoodInCache caches the good under the given cache version of its
+// project, so a good read before a concurrent write is never served.
+func (s *RedisStorage) SaveGoodInCache(ctx context.Context, version int64, good models.Good) error {
+	const op = "storage.redis.SaveGoodInCache"
+
+	goodJSON, err := json.Marshal(good)
+	if err != nil {
+		return fmt.Errorf("%s: failed to marshal good: %w", op, err)
+	}
+
+	key := goodKey(strconv.Itoa(good.ID), strconv.Itoa(good.ProjectID), version)
+
+	if err := s.client.Set(ctx, key, goodJSON, s.goodTTL).Err(); err != nil {
+		return fmt.Errorf("%s: failed to save good to redis: %w", op, err)
+	}
+
+	return nil
+}
+
+// InvalidGoods drops cached copies of the given goods under the current
+// cache version of their project, so it must run before InvalidList.
+func (s *RedisStorage) InvalidGoods(ctx context.Context, goods ...models.Good) error {
+	const op = "storage.redis.InvalidGoods"
+
+	if len(goods) == 0 {
+		return nil
+	}
+
+	versions := make(map[string]int64)
+	keys := make([]string, 0, len(goods))
+
+	for _, good := range goods {
+		projectID := strconv.Itoa(good.ProjectID)
+
+		version, ok := versions[projectID]
+		if !ok {
+			var err error
+			if version, err = s.version(ctx, projectID); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			versions[projectID] = version
+		}
+
+		keys = append(keys, goodKey(strconv.Itoa(good.ID), projectID, version))
+	}
+
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
 		return fmt.Errorf("%s: delete keys error: %w", op, err)
 	}
 
 	return nil
 }
+
+// Allow takes a token from the bucket of the key, see tokenBucketScript.
+func (s *RedisStorage) Allow(
+	ctx context.Context,
+	key string,
+	limit config.Limit,
+) (*models.RateLimitResult, error) {
+	const op = "storage.redis.Allow"
+
+	interval := limit.Window.Milliseconds() / int64(limit.Requests)
+	if interval < 1 {
+		interval = 1
+	}
+
+	res, err := tokenBucketScript.Run(ctx, s.client, []string{"ratelimit:" + key}, limit.Requests, interval).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("%s: run script: %w", op, err)
+	}
+
+	//nolint: mnd
+	return &models.RateLimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		Reset:      time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
+
+// reserveScript sets KEYS[1] to ARGV[1] with a ttl of ARGV[2] ms unless
+// it is already set. Returns the value already stored, or false.
+var reserveScript = redis.NewScript(`
+local stored = redis.call('GET', KEYS[1])
+if stored then
+	return stored
+end
+
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+
+return false
+`)
+
+func (s *RedisStorage) Reserve(
+	ctx context.Context,
+	key string,
+	rec models.IdempotencyRecord,
+	ttl time.Duration,
+) (*models.IdempotencyRecord, error) {
+	const op = "storage.redis.Reserve"
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: marshal record: %w", op, err)
+	}
+
+	stored, err := reserveScript.Run(ctx, s.client, []string{idempotencyKey(key)}, data, ttl.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: run script: %w", op, err)
+	}
+
+	var res models.IdempotencyRecord
+	if err := json.Unmarshal([]byte(stored), &res); err != nil {
+		return nil, fmt.Errorf("%s: unmarshal record: %w", op, err)
+	}
+
+	return &res, nil
+}
+
+func (s *RedisStorage) Complete(
+	ctx context.Context,
+	key string,
+	rec models.IdempotencyRecord,
+	ttl time.Duration,
+) error {
+	const op = "storage.redis.Complete"
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("%s: marshal record: %w", op, err)
+	}
+
+	if err := s.client.Set(ctx, idempotencyKey(key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *RedisStorage) Release(ctx context.Context, key string) error {
+	const op = "storage.redis.Release"
+
+	if err := s.client.Del(ctx, idempotencyKey(key)).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *RedisStorage) SaveTicket(ctx context.Context, ticketHash string, principal []byte, ttl time.Duration) error {
+	const op = "storage.redis.SaveTicket"
+
+	if err := s.client.Set(ctx, ticketKey(ticketHash), principal, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TakeTicket reads and deletes the ticket in one command, so two requests
+// cannot both redeem it.
+func (s *RedisStorage) TakeTicket(ctx context.Context, ticketHash string) ([]byte, error) {
+	const op = "storage.redis.TakeTicket"
+
+	principal, err := s.client.GetDel(ctx, ticketKey(ticketHash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("%s: %w", op, storageerr.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return principal, nil
+}
+
+// version returns the cache version of the project, 0 until the first
+// InvalidList.
+func (s *RedisStorage) version(ctx context.Context, projectID string) (int64, error) {
+	version, err := s.client.Get(ctx, versionKey(projectID)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, fmt.Errorf("failed to get cache version: %w", err)
+	}
+
+	return version, nil
+}
+
+func idempotencyKey(key string) string {
+	return "idempotency:" + key
+}
+
+func ticketKey(ticketHash string) string {
+	return "ticket:" + ticketHash
+}
+
+// listScope returns the key namespace of a list: either a single project
+// or all projects at once when projectID is empty.
+func listScope(projectID string) string {
+	if projectID == "" {
+		return "all"
+	}
+
+	return "project:" + projectID
+}
+
+func versionKey(projectID string) string {
+	return fmt.Sprintf("goods:%s:version", listScope(projectID))
+}
+
+func listKey(projectID string, version int64, limit, offset int) string {
+	return fmt.Sprintf("goods:%s:v%d:limit:%d-offset:%d",
+		listScope(projectID), version, limit, offset)
+}
+
+func goodKey(id, projectID string, version int64) string {
+	return fmt.Sprintf("goods:%s:v%d:good:%s", listScope(projectID), version, id)
+}
@@ -0,0 +1,233 @@
+package redis_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/redis"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/storageerr"
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	benchProjects   = 100
+	benchWriteRatio = 0.1
+	benchLimit      = 10
+	benchOffset     = 1
+)
+
+func newStorage(t testing.TB) (*redis.RedisStorage, *goredis.Client) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+
+	s := redis.New(config.RedisStorage{
+		Addr:        mr.Addr(),
+		ListSoftTTL: time.Minute,
+		ListHardTTL: time.Minute,
+		GoodTTL:     time.Minute,
+	})
+	t.Cleanup(func() { s.Close() }) //nolint: errcheck
+
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() }) //nolint: errcheck
+
+	return s, client
+}
+
+func page() list.Page {
+	return list.Page{Limit: benchLimit, Offset: benchOffset, Data: []byte(`{}`), ETag: `"tag"`}
+}
+
+func TestInvalidListIsScopedToProject(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStorage(t)
+
+	for _, projectID := range []string{"1", "2", ""} {
+		cached, err := s.GetCachedList(ctx, projectID, benchLimit, benchOffset)
+		require.NoError(t, err)
+		require.Nil(t, cached.Data)
+
+		require.NoError(t, s.SaveListInCache(ctx, projectID, cached.Version, page()))
+	}
+
+	require.NoError(t, s.InvalidList(ctx, "1"))
+
+	cached, err := s.GetCachedList(ctx, "1", benchLimit, benchOffset)
+	require.NoError(t, err)
+	require.Nil(t, cached.Data, "page of the written project must be invalidated")
+
+	cached, err = s.GetCachedList(ctx, "", benchLimit, benchOffset)
+	require.NoError(t, err)
+	require.Nil(t, cached.Data, "cross-project page must be invalidated")
+
+	cached, err = s.GetCachedList(ctx, "2", benchLimit, benchOffset)
+	require.NoError(t, err)
+	require.NotNil(t, cached.Data, "page of another project must survive")
+	require.Equal(t, page().ETag, cached.ETag)
+}
+
+func TestSaveWithOutdatedVersionIsNotServed(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStorage(t)
+
+	cached, err := s.GetCachedList(ctx, "1", benchLimit, benchOffset)
+	require.NoError(t, err)
+
+	// a write lands between the cache miss and saving the page read from Postgres
+	require.NoError(t, s.InvalidList(ctx, "1"))
+	require.NoError(t, s.SaveListInCache(ctx, "1", cached.Version, page()))
+
+	cached, err = s.GetCachedList(ctx, "1", benchLimit, benchOffset)
+	require.NoError(t, err)
+	require.Nil(t, cached.Data)
+}
+
+// BenchmarkListCacheHitRate compares the hit rate of per-project cache
+// versions with the previous strategy of deleting every cached page on any
+// write. Reads and writes are spread uniformly across benchProjects projects.
+func BenchmarkListCacheHitRate(b *testing.B) {
+	strategies := []struct {
+		name       string
+		invalidate func(ctx context.Context, s *redis.RedisStorage, client *goredis.Client, projectID string) error
+	}{
+		{
+			name: "scan-and-delete",
+			invalidate: func(ctx context.Context, _ *redis.RedisStorage, client *goredis.Client, _ string) error {
+				iter := client.Scan(ctx, 0, "goods:*:limit:*", 0).Iterator()
+				for iter.Next(ctx) {
+					if err := client.Del(ctx, iter.Val()).Err(); err != nil {
+						return err
+					}
+				}
+
+				return iter.Err()
+			},
+		},
+		{
+			name: "project-versions",
+			invalidate: func(ctx context.Context, s *redis.RedisStorage, _ *goredis.Client, projectID string) error {
+				return s.InvalidList(ctx, projectID)
+			},
+		},
+	}
+
+	for _, st := range strategies {
+		b.Run(st.name, func(b *testing.B) {
+			ctx := context.Background()
+			s, client := newStorage(b)
+			rnd := rand.New(rand.NewPCG(1, 2)) //nolint: gosec
+
+			var reads, hits int
+
+			b.ResetTimer()
+
+			for range b.N {
+				projectID := strconv.Itoa(rnd.IntN(benchProjects) + 1)
+
+				if rnd.Float64() < benchWriteRatio {
+					require.NoError(b, st.invalidate(ctx, s, client, projectID))
+					continue
+				}
+
+				reads++
+
+				cached, err := s.GetCachedList(ctx, projectID, benchLimit, benchOffset)
+				require.NoError(b, err)
+
+				if cached.Data != nil {
+					hits++
+					continue
+				}
+
+				require.NoError(b, s.SaveListInCache(ctx, projectID, cached.Version, page()))
+			}
+
+			if reads > 0 {
+				b.ReportMetric(float64(hits)/float64(reads)*100, "hit-%")
+			}
+		})
+	}
+}
+
+func TestGoodCache(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStorage(t)
+
+	cached, err := s.GetCachedGood(ctx, "1", "2")
+	require.NoError(t, err)
+	require.Nil(t, cached.Good)
+
+	good := models.Good{ID: 1, ProjectID: 2, Name: "Apple"}
+	require.NoError(t, s.SaveGoodInCache(ctx, cached.Version, good))
+
+	cached, err = s.GetCachedGood(ctx, "1", "2")
+	require.NoError(t, err)
+	require.Equal(t, good.Name, cached.Good.Name)
+
+	require.NoError(t, s.InvalidGoods(ctx, good))
+
+	cached, err = s.GetCachedGood(ctx, "1", "2")
+	require.NoError(t, err)
+	require.Nil(t, cached.Good)
+}
+
+func TestStaleGoodIsNotServed(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStorage(t)
+
+	cached, err := s.GetCachedGood(ctx, "1", "2")
+	require.NoError(t, err)
+
+	// An update commits and invalidates between the Postgres read and the
+	// save of the good it returned.
+	require.NoError(t, s.InvalidList(ctx, "2"))
+	require.NoError(t, s.InvalidGoods(ctx, models.Good{ID: 1, ProjectID: 2}))
+
+	require.NoError(t, s.SaveGoodInCache(ctx, cached.Version, models.Good{ID: 1, ProjectID: 2, Name: "Old"}))
+
+	cached, err = s.GetCachedGood(ctx, "1", "2")
+	require.NoError(t, err)
+	require.Nil(t, cached.Good)
+}
+
+func TestInvalidListDropsCachedGoods(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStorage(t)
+
+	// Moving a good to another project shifts the priorities of the goods
+	// it leaves behind, which are not invalidated one by one.
+	require.NoError(t, s.SaveGoodInCache(ctx, 0, models.Good{ID: 1, ProjectID: 2, Priority: 3}))
+	require.NoError(t, s.SaveGoodInCache(ctx, 0, models.Good{ID: 4, ProjectID: 5, Priority: 3}))
+	require.NoError(t, s.InvalidList(ctx, "2"))
+
+	cached, err := s.GetCachedGood(ctx, "1", "2")
+	require.NoError(t, err)
+	require.Nil(t, cached.Good)
+
+	cached, err = s.GetCachedGood(ctx, "4", "5")
+	require.NoError(t, err)
+	require.NotNil(t, cached.Good)
+}
+
+func TestTicketIsTakenOnce(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newStorage(t)
+
+	require.NoError(t, s.SaveTicket(ctx, "hash", []byte(`{"Actor":"api_key:1"}`), time.Minute))
+
+	principal, err := s.TakeTicket(ctx, "hash")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Actor":"api_key:1"}`, string(principal))
+
+	_, err = s.TakeTicket(ctx, "hash")
+	require.ErrorIs(t, err, storageerr.ErrNotFound)
+}
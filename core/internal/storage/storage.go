@@ -1,32 +1,89 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
 	"github.com/Gonnekone/hezzl-test/core/internal/config"
+	"github.com/Gonnekone/hezzl-test/core/internal/http-server/handlers/list"
+	"github.com/Gonnekone/hezzl-test/core/internal/lib/logger/sl"
+	"github.com/Gonnekone/hezzl-test/core/internal/models"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/memory"
+	"github.com/Gonnekone/hezzl-test/core/internal/storage/nocache"
 	"github.com/Gonnekone/hezzl-test/core/internal/storage/postgres"
 	"github.com/Gonnekone/hezzl-test/core/internal/storage/redis"
 )
 
+const pingTimeout = 2 * time.Second
+
+// Cache is implemented by redis.RedisStorage, memory.MemoryStorage
+// and nocache.NoCache.
+type Cache interface {
+	GetCachedList(ctx context.Context, projectID string, limit, offset int) (*list.CachedList, error)
+	SaveListInCache(ctx context.Context, projectID string, version int64, page list.Page) error
+	InvalidList(ctx context.Context, projectID string) error
+
+	GetCachedGood(ctx context.Context, id string, projectID string) (*models.CachedGood, error)
+	SaveGoodInCache(ctx context.Context, version int64, good models.Good) error
+	InvalidGoods(ctx context.Context, goods ...models.Good) error
+
+	Close() error
+}
+
 type Storage struct {
 	*postgres.PostgresStorage
-	*redis.RedisStorage
+	Cache
 }
 
 func New(
+	log *slog.Logger,
 	pCfg config.PostgresStorage,
 	rCfg config.RedisStorage,
 ) (*Storage, error) {
+	const op = "storage.New"
+
 	postgresStorage, err := postgres.New(pCfg)
 	if err != nil {
 		return nil, err
 	}
 
-	redisStorage, err := redis.New(rCfg)
-	if err != nil {
-		return nil, err
+	var cache Cache
+
+	switch rCfg.Mode {
+	case config.CacheModeRedis:
+		redisStorage := redis.New(rCfg)
+
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		defer cancel()
+
+		// Redis being down must not keep core from serving, reads fall back
+		// to Postgres until the client reconnects.
+		if err := redisStorage.Ping(ctx); err != nil {
+			log.Warn("redis is unavailable, serving from postgres until it is back", sl.Err(err))
+		}
+
+		cache = redisStorage
+
+	case config.CacheModeMemory:
+		cache, err = memory.New(rCfg)
+		if err != nil {
+			return nil, err
+		}
+
+	case config.CacheModeNone:
+		cache = nocache.New()
+
+	default:
+		//nolint: err113
+		return nil, fmt.Errorf("%s: unknown cache mode %q", op, rCfg.Mode)
 	}
 
+	log.Info("cache configured", slog.String("mode", rCfg.Mode))
+
 	return &Storage{
 		PostgresStorage: postgresStorage,
-		RedisStorage:    redisStorage,
+		Cache:           cache,
 	}, nil
 }
@@ -0,0 +1,22 @@
+// Package storageerr holds the errors storage implementations return for
+// expected failures. Handlers match them with errors.Is instead of looking
+// at driver errors.
+package storageerr
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a row with the same unique key exists.
+	ErrConflict = errors.New("conflict")
+	// ErrProjectNotFound is returned when a row refers to a project that
+	// does not exist.
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrVersionConflict is returned when a row was changed after the
+	// version the caller expected.
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrIncompleteOrder is returned when a new order of goods misses a
+	// good of the project or lists one from elsewhere.
+	ErrIncompleteOrder = errors.New("incomplete order")
+)
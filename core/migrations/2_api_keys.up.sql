@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id         SERIAL PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    key_hash   CHAR(64)     NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS api_key_projects
+(
+    api_key_id INT         NOT NULL REFERENCES api_keys (id) ON DELETE CASCADE,
+    project_id INT         NOT NULL REFERENCES projects (id),
+    scope      VARCHAR(16) NOT NULL CHECK (scope IN ('read', 'write')),
+
+    PRIMARY KEY (api_key_id, project_id)
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_goods_project_priority;
@@ -0,0 +1,16 @@
+ALTER TABLE goods ADD COLUMN IF NOT EXISTS priority INT;
+
+UPDATE goods g
+SET priority = o.position
+FROM (
+    SELECT id, project_id, ROW_NUMBER() OVER (PARTITION BY project_id ORDER BY rank, id) AS position
+    FROM goods
+) o
+WHERE g.id = o.id AND g.project_id = o.project_id;
+
+ALTER TABLE goods ALTER COLUMN priority SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_goods_project_rank;
+CREATE INDEX IF NOT EXISTS idx_goods_project_priority ON goods (project_id, priority, id);
+
+ALTER TABLE goods DROP COLUMN IF EXISTS rank;
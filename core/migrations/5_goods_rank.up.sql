@@ -0,0 +1,18 @@
+ALTER TABLE goods ADD COLUMN IF NOT EXISTS rank TEXT COLLATE "C";
+
+-- Hex digits are base 36 digits too, so the positions written as fixed
+-- width hex keep their order as ranks.
+UPDATE goods g
+SET rank = rtrim(lpad(to_hex(o.position), 6, '0'), '0')
+FROM (
+    SELECT id, project_id, ROW_NUMBER() OVER (PARTITION BY project_id ORDER BY priority, id) AS position
+    FROM goods
+) o
+WHERE g.id = o.id AND g.project_id = o.project_id;
+
+ALTER TABLE goods ALTER COLUMN rank SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_goods_project_priority;
+CREATE INDEX IF NOT EXISTS idx_goods_project_rank ON goods (project_id, rank, id);
+
+ALTER TABLE goods DROP COLUMN IF EXISTS priority;
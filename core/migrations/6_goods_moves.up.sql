@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS goods_moves
+(
+    id              SERIAL PRIMARY KEY,
+    good_id         INT NOT NULL,
+    from_project_id INT NOT NULL REFERENCES projects (id),
+    to_project_id   INT NOT NULL REFERENCES projects (id),
+    moved_at        TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_goods_moves_good ON goods_moves (good_id, moved_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_goods_project_priority;
+
+ALTER TABLE goods DROP COLUMN IF EXISTS priority;
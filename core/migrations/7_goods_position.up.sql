@@ -0,0 +1,15 @@
+-- priority is kept next to rank, so reads do not number the goods of a
+-- project on every query. Writes update both under the project lock.
+ALTER TABLE goods ADD COLUMN IF NOT EXISTS priority INT;
+
+UPDATE goods g
+SET priority = o.position
+FROM (
+    SELECT id, project_id, ROW_NUMBER() OVER (PARTITION BY project_id ORDER BY rank, id) AS position
+    FROM goods
+) o
+WHERE g.id = o.id AND g.project_id = o.project_id;
+
+ALTER TABLE goods ALTER COLUMN priority SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_goods_project_priority ON goods (project_id, priority, id);